@@ -8,7 +8,9 @@ import (
 	"os/signal"
 	"time"
 
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
 	"github.com/Ubivius/microservice-friendslist/pkg/database"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
 	"github.com/Ubivius/microservice-friendslist/pkg/handlers"
 	"github.com/Ubivius/microservice-friendslist/pkg/router"
 	"github.com/Ubivius/pkg-telemetry/metrics"
@@ -32,12 +34,52 @@ func main() {
 	// Starting metrics exporter
 	metrics.StartPrometheusExporterWithName("friendslist")
 
+	// Relationship types declared by the game, on top of the builtin ones
+	err := data.LoadRelationshipTypesFromEnv()
+	if err != nil {
+		log.Error(err, "Failed to load relationship types")
+		os.Exit(1)
+	}
+
+	// Event broker init, relationship changes are published to the other services
+	broker, err := events.NewPublisherFromEnv()
+	if err != nil {
+		log.Error(err, "Failed to connect to the event broker")
+		os.Exit(1)
+	}
+	publisher := &events.FanOut{}
+	publisher.Add(broker)
+
 	// Database init
-	db := database.NewMongoRelationships()
+	db := database.NewMongoRelationships(publisher)
+
+	// Webhooks receive the same events as the broker
+	webhookDispatcher := database.NewWebhookDispatcher(db)
+	publisher.Add(webhookDispatcher)
+
+	// Friendship events are saved in the activity feeds
+	publisher.Add(database.NewFeedRecorder(db))
+
+	// Session invites bring friends closer, their affinity sorts the friends lists
+	publisher.Add(database.NewAffinityRecorder(db))
+
+	// Background workers, stopped on shutdown
+	workersContext, stopWorkers := context.WithCancel(context.Background())
+	database.StartInviteSweeper(workersContext, db)
+	database.StartOutboxRelay(workersContext, db)
+	database.StartWebhookDispatcher(workersContext, webhookDispatcher)
 
 	// Creating handlers
 	relationshipHandler := handlers.NewRelationshipsHandler(db)
 
+	// Invite events of every replica are pushed to the clients of the stream
+	if subscriber, ok := broker.(events.Subscriber); ok {
+		unsubscribe := relationshipHandler.SubscribeStream(subscriber)
+		defer unsubscribe()
+	} else {
+		log.Info("Event broker doesn't support subscriptions, the stream won't receive events")
+	}
+
 	// Router setup
 	r := router.New(relationshipHandler)
 
@@ -64,9 +106,18 @@ func main() {
 
 	log.Info("Received terminate, beginning graceful shutdown", "received_signal", receivedSignal.String())
 
+	// Background workers shutdown
+	stopWorkers()
+
 	// DB connection shutdown
 	db.CloseDB()
 
+	// Event broker shutdown
+	err = publisher.Close()
+	if err != nil {
+		log.Error(err, "Error closing event broker connection")
+	}
+
 	// Context cancelling
 	timeoutContext, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
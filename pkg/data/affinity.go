@@ -0,0 +1,66 @@
+package data
+
+import (
+	"time"
+
+	"github.com/go-playground/validator"
+)
+
+// AffinitySignalType is the kind of interaction between users counted in their affinity
+type AffinitySignalType string
+
+// types of the interactions counted in the affinity
+const (
+	AffinityMatch         AffinitySignalType = "Match"         // the users played a match together
+	AffinityMessage       AffinitySignalType = "Message"       // the sender sent a message to the other users
+	AffinitySessionInvite AffinitySignalType = "SessionInvite" // a session invite was sent or accepted
+)
+
+// AffinitySignal is an interaction between users, counted in the affinity of every pair of friends it concerns
+type AffinitySignal struct {
+	ID         string // identifies the interaction, a signal received again isn't counted twice
+	Type       AffinitySignalType
+	UserIDs    []string
+	SenderID   string // when set, only the pairs of the sender with the users are counted, like for a message
+	OccurredAt time.Time
+}
+
+// Affinity is how much two friends interact, each interaction losing half its weight every AFFINITY_HALF_LIFE
+type Affinity struct {
+	ID        string    `bson:"_id"` // user IDs of both friends
+	UserIDs   []string  `bson:"user_ids"`
+	Score     float64   `bson:"score"`      // score at UpdatedOn
+	UpdatedOn time.Time `bson:"updated_on"` // time of the newest interaction
+	Version   int       `bson:"version"`    // incremented on every change, concurrent signals are counted one after the other
+}
+
+// CountedSignal is an interaction counted in the affinity of two friends, remembered for AFFINITY_SIGNAL_RETENTION to ignore it when it is received again
+type CountedSignal struct {
+	ID        string    `bson:"_id"` // affinity and signal IDs
+	UserIDs   []string  `bson:"user_ids"`
+	CountedOn time.Time `bson:"counted_on"`
+}
+
+// ChatEventType is the kind of conversation event published by microservice-text-chat
+type ChatEventType string
+
+// types of the conversation events handled by the service
+const (
+	MessageSent ChatEventType = "MessageSent" // the sender sent a message to the other users of the conversation
+)
+
+// ChatEvent is a conversation event received from microservice-text-chat
+type ChatEvent struct {
+	ID             string        `json:"id" validate:"required,max=128"`
+	Type           ChatEventType `json:"type" validate:"required,oneof=MessageSent"`
+	ConversationID string        `json:"conversation_id"`
+	SenderID       string        `json:"sender_id" validate:"required"`
+	RecipientIDs   []string      `json:"recipient_ids" validate:"required,min=1,max=100,unique,dive,required"`
+	OccurredAt     time.Time     `json:"occurred_at"`
+}
+
+// ValidateChatEvent a chat event with json validation
+func (chatEvent *ChatEvent) ValidateChatEvent() error {
+	validate := validator.New()
+	return validate.Struct(chatEvent)
+}
@@ -0,0 +1,44 @@
+package data
+
+import "strconv"
+
+// RelationshipExportHeader is the header row of a CSV relationship export
+// The nickname, note and favourite columns are the preferences of the exporting user
+var RelationshipExportHeader = []string{
+	"id",
+	"user_1_id",
+	"user_1_relationship_type",
+	"user_2_id",
+	"user_2_relationship_type",
+	"conversation_id",
+	"created_on",
+	"updated_on",
+	"nickname",
+	"note",
+	"favourite",
+}
+
+// ExportRecord returns the relationship as a CSV row matching RelationshipExportHeader, as exported by the user
+func (relationship *Relationship) ExportRecord(userID string) []string {
+	preferences := relationship.PreferencesOf(userID)
+	return []string{
+		relationship.ID,
+		relationship.User1.UserID,
+		string(relationship.User1.RelationshipType),
+		relationship.User2.UserID,
+		string(relationship.User2.RelationshipType),
+		relationship.ConversationID,
+		relationship.CreatedOn,
+		relationship.UpdatedOn,
+		preferences.Nickname,
+		preferences.Note,
+		strconv.FormatBool(preferences.Favourite),
+	}
+}
+
+// ExportedBy returns a copy of the relationship without the preferences of the other user
+func (relationship *Relationship) ExportedBy(userID string) *Relationship {
+	exported := *relationship
+	exported.OtherUser(userID).RelationshipPreferences = RelationshipPreferences{}
+	return &exported
+}
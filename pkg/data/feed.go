@@ -0,0 +1,45 @@
+package data
+
+import (
+	"time"
+)
+
+// FeedItemType is the kind of activity shown in the feed of a user
+type FeedItemType string
+
+// activities of a feed
+const (
+	FeedFriendAdded    FeedItemType = "FriendAdded"    // the actor, a friend of the user, became friends with the subject
+	FeedInviteAccepted FeedItemType = "InviteAccepted" // the actor accepted the friend request of the user, the subject
+)
+
+// FeedItem is an activity in the feed of a user, saved when the relationship event happens
+type FeedItem struct {
+	ID        string        `json:"id" bson:"_id"`
+	UserID    string        `json:"-" bson:"user_id"` // owner of the feed
+	Type      FeedItemType  `json:"type" bson:"type"`
+	ActorID   string        `json:"actor_id" bson:"actor_id"`
+	SubjectID string        `json:"subject_id" bson:"subject_id"`
+	Actor     *DetailedUser `json:"actor,omitempty" bson:"-"`
+	Subject   *DetailedUser `json:"subject,omitempty" bson:"-"`
+	EventID   string        `json:"event_id" bson:"event_id"`
+	CreatedOn time.Time     `json:"created_on" bson:"created_on"`
+	ExpiresAt time.Time     `json:"-" bson:"expires_at"`
+}
+
+// FeedItems is a collection of FeedItem
+type FeedItems []*FeedItem
+
+// Feed is a page of the activity feed of a user, newest first
+// Items hidden from the user are left out, a page can hold fewer items than its limit
+type Feed struct {
+	UserID     string    `json:"user_id"`
+	Items      FeedItems `json:"items"`
+	NextOffset int       `json:"next_offset,omitempty"` // offset of the next page, missing on the last page
+}
+
+// DefaultFeedPageSize is the number of items returned by a feed when no limit is requested
+const DefaultFeedPageSize = 20
+
+// MaxFeedPageSize is the maximum number of items returned by a feed
+const MaxFeedPageSize = 100
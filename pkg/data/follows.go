@@ -0,0 +1,71 @@
+package data
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrorFollowNotFound : Follow specific error
+var ErrorFollowNotFound = fmt.Errorf("Follow not found")
+
+// ErrorFollowExist : Follow specific error
+var ErrorFollowExist = fmt.Errorf("the user already follows this user")
+
+// ErrorUserBlocked : Block specific error
+var ErrorUserBlocked = fmt.Errorf("one of the users blocked the other")
+
+// DefaultFollowPageSize is the number of users returned by a follower or following list when no limit is requested
+const DefaultFollowPageSize = 50
+
+// MaxFollowPageSize is the maximum number of users returned by a follower or following list
+const MaxFollowPageSize = 200
+
+// Follow is a one-way relationship that doesn't need to be accepted, used to follow streamers and creators
+// Follows are stored apart from the other relationships so that two users can be friends and follow each other
+// The follower sees the followed user as Following and the followed user sees the follower as Follower
+type Follow struct {
+	ID         string    `json:"id" bson:"_id"`
+	FollowerID string    `json:"follower_id" bson:"follower_id"`
+	FollowedID string    `json:"followed_id" bson:"followed_id"`
+	CreatedOn  string    `json:"created_on" bson:"created_on"`
+	FollowedAt time.Time `json:"-" bson:"followed_at"` // time of the follow, the lists are sorted on it rather than on the CreatedOn text
+}
+
+// Follows is a collection of Follow
+type Follows []*Follow
+
+// FollowCounts are the number of followers and followed users of a user
+// They are kept as counters so that users with very large follower sets don't need to be counted
+type FollowCounts struct {
+	UserID    string `json:"user_id" bson:"_id"`
+	Followers int64  `json:"followers" bson:"followers"`
+	Following int64  `json:"following" bson:"following"`
+}
+
+// FollowList is a page of the followers or followed users of a user
+type FollowList struct {
+	UserID        string                 `json:"user_id"`
+	Total         int64                  `json:"total"`
+	Relationships *DetailedRelationships `json:"relationships"`
+}
+
+// Relationship returns the follow as a relationship between the follower and the followed user
+func (follow *Follow) Relationship() *Relationship {
+	return &Relationship{
+		ID:        follow.ID,
+		User1:     User{UserID: follow.FollowerID, RelationshipType: Following},
+		User2:     User{UserID: follow.FollowedID, RelationshipType: Follower},
+		CreatedOn: follow.CreatedOn,
+		UpdatedOn: follow.CreatedOn,
+	}
+}
+
+// IsFollow returns true when the relationship is a follow, exported with the other relationships
+func (relationship *Relationship) IsFollow() bool {
+	return relationship.User1.RelationshipType == Following && relationship.User2.RelationshipType == Follower
+}
+
+// IsBlocked returns true when one of the users of the relationship blocked the other
+func (relationship *Relationship) IsBlocked() bool {
+	return relationship.User1.RelationshipType == Blocked || relationship.User2.RelationshipType == Blocked
+}
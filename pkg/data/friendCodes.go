@@ -0,0 +1,92 @@
+package data
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/go-playground/validator"
+)
+
+// ErrorFriendCodeNotFound : Friend code specific error
+var ErrorFriendCodeNotFound = fmt.Errorf("Friend code not found")
+
+// friendCodeAlphabet leaves out the characters that are easily confused, like 0 and O or 1 and I
+const friendCodeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// friendCodeLength is the number of characters of a friend code, without the separator
+const friendCodeLength = 8
+
+// FriendCode lets players add a user without knowing their user ID
+// Single use or expiring codes are used as invite links
+type FriendCode struct {
+	Code       string     `json:"code" bson:"_id"`
+	UserID     string     `json:"user_id" bson:"user_id"`
+	SingleUse  bool       `json:"single_use" bson:"single_use"`
+	AutoAccept bool       `json:"auto_accept" bson:"auto_accept"` // redeeming creates a friendship instead of a friend request
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" bson:"expires_at"`
+	CreatedOn  string     `json:"created_on" bson:"created_on"`
+}
+
+// FriendCodes is a collection of FriendCode
+type FriendCodes []*FriendCode
+
+// FriendCodeRequest defines the options of a new friend code
+type FriendCodeRequest struct {
+	SingleUse  bool `json:"single_use"`
+	AutoAccept bool `json:"auto_accept"`
+	ExpiresIn  int  `json:"expires_in" validate:"gte=0"` // seconds before the code expires, 0 for a code that never expires
+}
+
+// ValidateFriendCodeRequest a friend code request with json validation
+func (friendCodeRequest *FriendCodeRequest) ValidateFriendCodeRequest() error {
+	validate := validator.New()
+	return validate.Struct(friendCodeRequest)
+}
+
+// NewFriendCode returns a friend code for the user with the requested options
+func (friendCodeRequest *FriendCodeRequest) NewFriendCode(userID string) *FriendCode {
+	friendCode := &FriendCode{
+		UserID:     userID,
+		SingleUse:  friendCodeRequest.SingleUse,
+		AutoAccept: friendCodeRequest.AutoAccept,
+	}
+	if friendCodeRequest.ExpiresIn > 0 {
+		expiresAt := time.Now().UTC().Add(time.Duration(friendCodeRequest.ExpiresIn) * time.Second)
+		friendCode.ExpiresAt = &expiresAt
+	}
+	return friendCode
+}
+
+// IsExpired returns true when the friend code can't be redeemed anymore
+func (friendCode *FriendCode) IsExpired(now time.Time) bool {
+	return friendCode.ExpiresAt != nil && !friendCode.ExpiresAt.After(now)
+}
+
+// Relationship returns the relationship created when the user redeems the friend code
+func (friendCode *FriendCode) Relationship(userID string) *Relationship {
+	if friendCode.AutoAccept {
+		return &Relationship{
+			User1: User{UserID: userID, RelationshipType: Friend},
+			User2: User{UserID: friendCode.UserID, RelationshipType: Friend},
+		}
+	}
+	return NewInvite(userID, friendCode.UserID)
+}
+
+// GenerateFriendCode returns a random, human-friendly code like 7KQ4-MX2B
+func GenerateFriendCode() (string, error) {
+	code := make([]byte, 0, friendCodeLength+1)
+	for i := 0; i < friendCodeLength; i++ {
+		if i == friendCodeLength/2 {
+			code = append(code, '-')
+		}
+		index, err := rand.Int(rand.Reader, big.NewInt(int64(len(friendCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code = append(code, friendCodeAlphabet[index.Int64()])
+	}
+	return string(code), nil
+}
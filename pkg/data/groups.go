@@ -0,0 +1,42 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/go-playground/validator"
+)
+
+// ErrorFriendGroupNotFound : Friend group specific error
+var ErrorFriendGroupNotFound = fmt.Errorf("Friend group not found")
+
+// ErrorInvalidGroupMember : Friend group specific error
+var ErrorInvalidGroupMember = fmt.Errorf("Only friendships of the group owner can be added to a friend group")
+
+// FriendGroup is a named list of friends, like "Raid team" or "IRL", only visible to its owner
+type FriendGroup struct {
+	ID              string   `json:"id" bson:"_id"`
+	UserID          string   `json:"user_id" bson:"user_id"`
+	Name            string   `json:"name" bson:"name" validate:"required,max=64"`
+	RelationshipIDs []string `json:"relationship_ids" bson:"relationship_ids" validate:"unique,dive,required"`
+	CreatedOn       string   `json:"created_on" bson:"created_on"`
+	UpdatedOn       string   `json:"updated_on" bson:"updated_on"`
+}
+
+// FriendGroups is a collection of FriendGroup
+type FriendGroups []*FriendGroup
+
+// ValidateFriendGroup a friend group with json validation
+func (friendGroup *FriendGroup) ValidateFriendGroup() error {
+	validate := validator.New()
+	return validate.Struct(friendGroup)
+}
+
+// Contains returns true when the relationship is a member of the friend group
+func (friendGroup *FriendGroup) Contains(relationshipID string) bool {
+	for _, id := range friendGroup.RelationshipIDs {
+		if id == relationshipID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/go-playground/validator"
+)
+
+// ErrorAmbiguousUser : User lookup specific error
+var ErrorAmbiguousUser = fmt.Errorf("several users match the lookup")
+
+// ErrorInvalidInviteRequest : Invalid InviteRequest specific error
+var ErrorInvalidInviteRequest = fmt.Errorf("exactly one of user_id, username or email is required")
+
+// InviteRequest defines the structure of a friend request sent to a user found by user ID, username or email
+type InviteRequest struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email" validate:"omitempty,email"`
+}
+
+// UserLookup defines the search of a user in microservice-user
+type UserLookup struct {
+	Username string
+	Email    string
+}
+
+// ValidateInviteRequest an invite request with json validation
+func (inviteRequest *InviteRequest) ValidateInviteRequest() error {
+	provided := 0
+	for _, field := range []string{inviteRequest.UserID, inviteRequest.Username, inviteRequest.Email} {
+		if field != "" {
+			provided++
+		}
+	}
+	if provided != 1 {
+		return ErrorInvalidInviteRequest
+	}
+
+	validate := validator.New()
+	return validate.Struct(inviteRequest)
+}
+
+// Lookup returns the search resolving the receiver of the invite, nil when the user ID is already known
+func (inviteRequest *InviteRequest) Lookup() *UserLookup {
+	if inviteRequest.UserID != "" {
+		return nil
+	}
+	return &UserLookup{Username: inviteRequest.Username, Email: inviteRequest.Email}
+}
+
+// NewInvite returns the pending relationship of a friend request between two users
+func NewInvite(senderID string, receiverID string) *Relationship {
+	return &Relationship{
+		User1: User{UserID: senderID, RelationshipType: PendingOutgoing},
+		User2: User{UserID: receiverID, RelationshipType: PendingIncoming},
+	}
+}
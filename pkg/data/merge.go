@@ -0,0 +1,55 @@
+package data
+
+import (
+	"github.com/go-playground/validator"
+)
+
+// MergeRequest defines the structure of an account merge request
+type MergeRequest struct {
+	SourceUserID string `json:"source_user_id" validate:"required"`
+	TargetUserID string `json:"target_user_id" validate:"required"`
+}
+
+// MergeResult summarizes what an account merge did to the relationships, follows and friend groups of the source user
+// The settings and the friend code of the source user aren't merged, the target user keeps their own
+type MergeResult struct {
+	SourceUserID   string `json:"source_user_id"`
+	TargetUserID   string `json:"target_user_id"`
+	Moved          int    `json:"moved"`           // relationships rewritten to the target user
+	Replaced       int    `json:"replaced"`        // relationships of the target user replaced by a stronger one from the source user
+	Dropped        int    `json:"dropped"`         // relationships of the source user discarded
+	MovedFollows   int    `json:"moved_follows"`   // follows of the source user moved to the target user
+	DroppedFollows int    `json:"dropped_follows"` // follows of the source user the target user already had, between both accounts or with a blocked user
+	MovedGroups    int    `json:"moved_groups"`    // friend groups of the source user moved to the target user
+}
+
+// ValidateMergeRequest a merge request with json validation
+func (mergeRequest *MergeRequest) ValidateMergeRequest() error {
+	validate := validator.New()
+	return validate.Struct(mergeRequest)
+}
+
+// Precedence ranks the relationship types when two relationships collide during an account merge
+// Builtin types rank Blocked > Friend > Pending > None, unregistered types rank 0
+func (relationshipType RelationshipType) Precedence() int {
+	definition, ok := relationshipTypes.Lookup(relationshipType)
+	if !ok {
+		return 0
+	}
+	return definition.Precedence
+}
+
+// Precedence of a relationship is the highest precedence of its two sides
+func (relationship *Relationship) Precedence() int {
+	precedence := relationship.User1.RelationshipType.Precedence()
+	if precedence2 := relationship.User2.RelationshipType.Precedence(); precedence2 > precedence {
+		precedence = precedence2
+	}
+	return precedence
+}
+
+// KeepsOver returns true when the relationship wins a merge collision against the other relationship
+// Ties are won by the other relationship so that the target user's existing relationships are kept
+func (relationship *Relationship) KeepsOver(other *Relationship) bool {
+	return relationship.Precedence() > other.Precedence()
+}
@@ -0,0 +1,47 @@
+package data
+
+import (
+	"github.com/go-playground/validator"
+)
+
+// reasons a pair of users of a party isn't made friends
+const (
+	SkippedBlocked        = "blocked"         // one of the users blocked the other
+	SkippedAlreadyFriends = "already_friends" // the users are already friends
+	SkippedFriendLimit    = "friend_limit"    // one of the users reached their friend limit
+	SkippedNotAllowed     = "not_allowed"     // the current relationship of the users can't become a friendship
+	SkippedFrozen         = "frozen"          // one of the users is banned
+	SkippedFailed         = "failed"          // the friendship couldn't be saved, the pair can be sent again
+)
+
+// PartyFriendshipRequest asks to make every user of a party friends with each other, sent by trusted services like game events
+type PartyFriendshipRequest struct {
+	UserIDs []string `json:"user_ids" validate:"required,min=2,max=50,unique,dive,required"`
+}
+
+// SkippedPair is a pair of users of a party who weren't made friends
+type SkippedPair struct {
+	UserID1 string `json:"user_id_1"`
+	UserID2 string `json:"user_id_2"`
+	Reason  string `json:"reason"`
+}
+
+// PartyFriendshipResult summarizes the friendships made between the users of a party
+type PartyFriendshipResult struct {
+	Friendships Relationships  `json:"friendships"` // relationships created or turned into friendships
+	Skipped     []*SkippedPair `json:"skipped"`
+}
+
+// ValidatePartyFriendshipRequest a party friendship request with json validation
+func (partyRequest *PartyFriendshipRequest) ValidatePartyFriendshipRequest() error {
+	validate := validator.New()
+	return validate.Struct(partyRequest)
+}
+
+// NewFriendship returns a friendship between two users
+func NewFriendship(userID1 string, userID2 string) *Relationship {
+	return &Relationship{
+		User1: User{UserID: userID1, RelationshipType: Friend},
+		User2: User{UserID: userID2, RelationshipType: Friend},
+	}
+}
@@ -0,0 +1,49 @@
+package data
+
+import (
+	"time"
+
+	"github.com/go-playground/validator"
+)
+
+// statuses of a user, shown in the friends lists
+const (
+	PresenceOnline  = "Online"
+	PresenceInGame  = "InGame"
+	PresenceAway    = "Away"
+	PresenceOffline = "Offline" // also the status of users without a recent presence
+)
+
+// PresenceStatuses are the statuses a user can have
+var PresenceStatuses = []string{PresenceOnline, PresenceInGame, PresenceAway, PresenceOffline}
+
+// IsPresenceStatus reports whether status is one of the presence statuses
+func IsPresenceStatus(status string) bool {
+	for _, presenceStatus := range PresenceStatuses {
+		if presenceStatus == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Presence is the last status received for a user, the user is offline once it expires
+type Presence struct {
+	UserID    string    `json:"user_id" bson:"_id" validate:"required"`
+	Status    string    `json:"status" bson:"status" validate:"required,oneof=Online InGame Away Offline"`
+	UpdatedOn time.Time `json:"updated_on" bson:"updated_on"` // when the status changed, older updates are ignored
+	ExpiresAt time.Time `json:"-" bson:"expires_at"`
+}
+
+// FriendsPresence is the friends list of a user filtered by status, with the number of friends in each status
+type FriendsPresence struct {
+	UserID  string                `json:"user_id"`
+	Counts  map[string]int        `json:"counts"`
+	Friends DetailedRelationships `json:"friends"`
+}
+
+// ValidatePresence a presence with json validation
+func (presence *Presence) ValidatePresence() error {
+	validate := validator.New()
+	return validate.Struct(presence)
+}
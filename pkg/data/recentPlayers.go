@@ -0,0 +1,59 @@
+package data
+
+import (
+	"time"
+
+	"github.com/go-playground/validator"
+)
+
+// MatchRoster is the list of the users who played a match together, sent by the game servers
+type MatchRoster struct {
+	MatchID  string    `json:"match_id" validate:"required,max=128"`
+	UserIDs  []string  `json:"user_ids" validate:"required,min=2,max=100,unique,dive,required"`
+	PlayedAt time.Time `json:"played_at"`
+}
+
+// RecentPlayer is a user who recently played with the user, with the number of matches they played together
+type RecentPlayer struct {
+	ID           string        `json:"-" bson:"_id"`
+	UserID       string        `json:"-" bson:"user_id"`
+	PlayerID     string        `json:"player_id" bson:"player_id"`
+	Player       *DetailedUser `json:"player,omitempty" bson:"-"`
+	Matches      int           `json:"matches" bson:"matches"` // matches played together while the co-player was kept
+	LastPlayedOn time.Time     `json:"last_played_on" bson:"last_played_on"`
+	MatchIDs     []string      `json:"-" bson:"match_ids"` // last matches counted, a roster sent again isn't counted twice
+	ExpiresAt    time.Time     `json:"-" bson:"expires_at"`
+}
+
+// RecentPlayers is a collection of RecentPlayer
+type RecentPlayers []*RecentPlayer
+
+// FriendSuggestion is a user the user may know, ranked by the matches they played together and their mutual friends
+type FriendSuggestion struct {
+	UserID          string        `json:"user_id"`
+	User            *DetailedUser `json:"user,omitempty"`
+	Score           int           `json:"score"`
+	MutualFriends   int           `json:"mutual_friends"`
+	MatchesTogether int           `json:"matches_together"`
+}
+
+// FriendSuggestions is a collection of FriendSuggestion
+type FriendSuggestions []*FriendSuggestion
+
+// DefaultRecentPlayersPageSize is the number of co-players returned when no limit is requested
+const DefaultRecentPlayersPageSize = 20
+
+// MaxRecentPlayersPageSize is the maximum number of co-players returned
+const MaxRecentPlayersPageSize = 100
+
+// DefaultSuggestionsSize is the number of friend suggestions returned when no limit is requested
+const DefaultSuggestionsSize = 10
+
+// MaxSuggestionsSize is the maximum number of friend suggestions returned
+const MaxSuggestionsSize = 50
+
+// ValidateMatchRoster a match roster with json validation
+func (matchRoster *MatchRoster) ValidateMatchRoster() error {
+	validate := validator.New()
+	return validate.Struct(matchRoster)
+}
@@ -0,0 +1,180 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-playground/validator"
+)
+
+// ErrorInvalidCounterpart : Invalid Relationship specific error
+var ErrorInvalidCounterpart = fmt.Errorf("the relationship types of the two users can't be combined")
+
+// ErrorInvalidTransition : Invalid Relationship specific error
+var ErrorInvalidTransition = fmt.Errorf("a user can't change to this relationship type from their current one")
+
+// RelationshipTypeDefinition declares a relationship type and the rules it follows
+type RelationshipTypeDefinition struct {
+	Name         RelationshipType   `json:"name" validate:"required"`
+	Description  string             `json:"description"`
+	Counterparts []RelationshipType `json:"counterparts" validate:"required,min=1"` // types the other user of the relationship can have
+	Transitions  []RelationshipType `json:"transitions,omitempty"`                  // types the user can change to on an update, any type when empty
+	Precedence   int                `json:"precedence"`                             // rank of the type when two relationships collide during an account merge
+}
+
+// RelationshipTypeRegistry holds the relationship types known by the service
+type RelationshipTypeRegistry struct {
+	definitions map[RelationshipType]*RelationshipTypeDefinition
+	names       []RelationshipType // declaration order
+}
+
+// BuiltinRelationshipTypes are the relationship types the service relies on, they are always registered
+var BuiltinRelationshipTypes = []*RelationshipTypeDefinition{
+	{Name: None, Description: "user has no intrinsic relationship", Counterparts: []RelationshipType{None, Blocked}},
+	{Name: Friend, Description: "user is a friend", Counterparts: []RelationshipType{Friend}, Transitions: []RelationshipType{None, Blocked}, Precedence: 2},
+	{Name: Blocked, Description: "user blocked the other user", Counterparts: []RelationshipType{None, Blocked}, Transitions: []RelationshipType{None}, Precedence: 3},
+	{Name: PendingIncoming, Description: "user has a pending incoming friend request to connected user", Counterparts: []RelationshipType{PendingOutgoing}, Transitions: []RelationshipType{Friend, None, Blocked}, Precedence: 1},
+	{Name: PendingOutgoing, Description: "current user has a pending outgoing friend request to user", Counterparts: []RelationshipType{PendingIncoming}, Transitions: []RelationshipType{Friend, None, Blocked}, Precedence: 1},
+}
+
+// relationshipTypes is the registry used by validation, it is only replaced at startup
+var relationshipTypes = mustNewRelationshipTypeRegistry()
+
+// NewRelationshipTypeRegistry returns a registry with the builtin relationship types and the additional definitions
+// Every counterpart and transition must be a registered type, and counterparts must be declared on both types
+func NewRelationshipTypeRegistry(definitions ...*RelationshipTypeDefinition) (*RelationshipTypeRegistry, error) {
+	registry := &RelationshipTypeRegistry{definitions: map[RelationshipType]*RelationshipTypeDefinition{}}
+	validate := validator.New()
+
+	for _, definition := range append(append([]*RelationshipTypeDefinition{}, BuiltinRelationshipTypes...), definitions...) {
+		err := validate.Struct(definition)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := registry.definitions[definition.Name]; exists {
+			return nil, fmt.Errorf("relationship type %s is declared twice", definition.Name)
+		}
+		registry.definitions[definition.Name] = definition
+		registry.names = append(registry.names, definition.Name)
+	}
+
+	for _, definition := range registry.definitions {
+		for _, counterpart := range definition.Counterparts {
+			counterpartDefinition, ok := registry.definitions[counterpart]
+			if !ok {
+				return nil, fmt.Errorf("relationship type %s has the unknown counterpart %s", definition.Name, counterpart)
+			}
+			if !containsRelationshipType(counterpartDefinition.Counterparts, definition.Name) {
+				return nil, fmt.Errorf("relationship type %s is a counterpart of %s but not the other way around", counterpart, definition.Name)
+			}
+		}
+		for _, transition := range definition.Transitions {
+			if _, ok := registry.definitions[transition]; !ok {
+				return nil, fmt.Errorf("relationship type %s has the unknown transition %s", definition.Name, transition)
+			}
+		}
+	}
+	return registry, nil
+}
+
+func mustNewRelationshipTypeRegistry() *RelationshipTypeRegistry {
+	registry, err := NewRelationshipTypeRegistry()
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
+// LoadRelationshipTypes reads additional relationship type definitions from a JSON array
+func LoadRelationshipTypes(reader io.Reader) (*RelationshipTypeRegistry, error) {
+	definitions := []*RelationshipTypeDefinition{}
+	err := json.NewDecoder(reader).Decode(&definitions)
+	if err != nil {
+		return nil, err
+	}
+	return NewRelationshipTypeRegistry(definitions...)
+}
+
+// LoadRelationshipTypesFromEnv registers the relationship types declared in the file at RELATIONSHIP_TYPES_FILE
+// Only the builtin types are registered when the variable isn't set
+func LoadRelationshipTypesFromEnv() error {
+	path := os.Getenv("RELATIONSHIP_TYPES_FILE")
+	if path == "" {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	registry, err := LoadRelationshipTypes(file)
+	if err != nil {
+		return fmt.Errorf("invalid relationship types in %s: %w", path, err)
+	}
+	SetRelationshipTypes(registry)
+	return nil
+}
+
+// RelationshipTypes returns the registry of the relationship types known by the service
+func RelationshipTypes() *RelationshipTypeRegistry {
+	return relationshipTypes
+}
+
+// SetRelationshipTypes replaces the registry of the relationship types, it must only be called at startup
+func SetRelationshipTypes(registry *RelationshipTypeRegistry) {
+	relationshipTypes = registry
+}
+
+// Definitions returns the registered relationship types in declaration order
+func (registry *RelationshipTypeRegistry) Definitions() []*RelationshipTypeDefinition {
+	definitions := make([]*RelationshipTypeDefinition, 0, len(registry.names))
+	for _, name := range registry.names {
+		definitions = append(definitions, registry.definitions[name])
+	}
+	return definitions
+}
+
+// Lookup returns the definition of a relationship type, or false when the type isn't registered
+func (registry *RelationshipTypeRegistry) Lookup(relationshipType RelationshipType) (*RelationshipTypeDefinition, bool) {
+	definition, ok := registry.definitions[relationshipType]
+	return definition, ok
+}
+
+// AreCounterparts returns true when two users of a relationship can have these types
+func (registry *RelationshipTypeRegistry) AreCounterparts(relationshipType1 RelationshipType, relationshipType2 RelationshipType) bool {
+	definition, ok := registry.definitions[relationshipType1]
+	return ok && containsRelationshipType(definition.Counterparts, relationshipType2)
+}
+
+// CanTransition returns true when a user can change from a relationship type to another one
+func (registry *RelationshipTypeRegistry) CanTransition(from RelationshipType, to RelationshipType) bool {
+	definition, ok := registry.definitions[from]
+	if !ok || from == to || len(definition.Transitions) == 0 {
+		return true
+	}
+	return containsRelationshipType(definition.Transitions, to)
+}
+
+// ValidateTransition checks that each user kept in the relationship can change from their previous type to the new one
+func (relationship *Relationship) ValidateTransition(previous *Relationship) error {
+	for _, side := range []User{relationship.User1, relationship.User2} {
+		previousSide := previous.Side(side.UserID)
+		if previousSide != nil && !relationshipTypes.CanTransition(previousSide.RelationshipType, side.RelationshipType) {
+			return ErrorInvalidTransition
+		}
+	}
+	return nil
+}
+
+func containsRelationshipType(relationshipTypes []RelationshipType, relationshipType RelationshipType) bool {
+	for _, candidate := range relationshipTypes {
+		if candidate == relationshipType {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package data
 
 import (
 	"fmt"
+	"time"
 )
 
 // ErrorRelationshipNotFound : Relationship specific errors
@@ -16,6 +17,42 @@ var ErrorRelationshipExist = fmt.Errorf("a relationship with these two users alr
 // ErrorUserNotFound : User specific errors
 var ErrorUserNotFound = fmt.Errorf("UserID doesn't exist")
 
+// ErrorInviteQuotaExceeded : Friend request quota specific error
+var ErrorInviteQuotaExceeded = fmt.Errorf("friend request quota exceeded")
+
+// InviteQuotaError is returned when a user sends more friend requests than allowed
+type InviteQuotaError struct {
+	Limit      string        // name of the exceeded limit
+	RetryAfter time.Duration // time before the user can send a friend request again
+}
+
+func (quotaError *InviteQuotaError) Error() string {
+	return fmt.Sprintf("%s: %s limit reached, retry after %s", ErrorInviteQuotaExceeded, quotaError.Limit, quotaError.RetryAfter)
+}
+
+// Is makes errors.Is match ErrorInviteQuotaExceeded
+func (quotaError *InviteQuotaError) Is(target error) bool {
+	return target == ErrorInviteQuotaExceeded
+}
+
+// ErrorFriendLimitReached : Friend limit specific error
+var ErrorFriendLimitReached = fmt.Errorf("maximum number of friends reached")
+
+// FriendLimitError is returned when a relationship would give a user more friends than allowed
+type FriendLimitError struct {
+	UserID string
+	Limit  int
+}
+
+func (limitError *FriendLimitError) Error() string {
+	return fmt.Sprintf("%s: user %s can't have more than %d friends", ErrorFriendLimitReached, limitError.UserID, limitError.Limit)
+}
+
+// Is makes errors.Is match ErrorFriendLimitReached
+func (limitError *FriendLimitError) Is(target error) bool {
+	return target == ErrorFriendLimitReached
+}
+
 // RelationshipType of a relationship
 type RelationshipType string
 
@@ -26,22 +63,133 @@ const (
 	Blocked         RelationshipType = "Blocked"         // user is blocked
 	PendingIncoming	RelationshipType = "PendingIncoming" // user has a pending incoming friend request to connected user
 	PendingOutgoing	RelationshipType = "PendingOutgoing" // current user has a pending outgoing friend request to user
+	Follower        RelationshipType = "Follower"        // user follows current user, see Follow
+	Following       RelationshipType = "Following"       // current user follows user, see Follow
 )
 
 // Relationship defines the structure for an API relationship.
 type Relationship struct {
-	ID             string `json:"id" bson:"_id"`
-	User1          User   `json:"user_1" bson:"user_1"`
-	User2          User   `json:"user_2" bson:"user_2"`
-	ConversationID string `json:"conversation_id" bson:"conversation_id"`
-	CreatedOn      string `json:"created_on" bson:"created_on"`
-	UpdatedOn      string `json:"updated_on" bson:"updated_on"`
+	ID             string     `json:"id" bson:"_id"`
+	User1          User       `json:"user_1" bson:"user_1"`
+	User2          User       `json:"user_2" bson:"user_2"`
+	ConversationID string     `json:"conversation_id" bson:"conversation_id"`
+	CreatedOn      string     `json:"created_on" bson:"created_on"`
+	UpdatedOn      string     `json:"updated_on" bson:"updated_on"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" bson:"expires_at"` // only set on pending relationships
+}
+
+// IsPending returns true when the relationship is a friend request waiting for an answer
+func (relationship *Relationship) IsPending() bool {
+	return relationship.User1.RelationshipType.isPending() || relationship.User2.RelationshipType.isPending()
+}
+
+// IsExpired returns true when the relationship is a pending friend request past its expiry
+func (relationship *Relationship) IsExpired(now time.Time) bool {
+	return relationship.IsPending() && relationship.ExpiresAt != nil && !relationship.ExpiresAt.After(now)
+}
+
+func (relationshipType RelationshipType) isPending() bool {
+	return relationshipType == PendingIncoming || relationshipType == PendingOutgoing
+}
+
+// Sender returns the user who sent the friend request, or an empty string if the relationship isn't pending
+func (relationship *Relationship) Sender() string {
+	switch {
+	case relationship.User1.RelationshipType == PendingOutgoing:
+		return relationship.User1.UserID
+	case relationship.User2.RelationshipType == PendingOutgoing:
+		return relationship.User2.UserID
+	}
+	return ""
+}
+
+// Crosses returns true when the relationship and the other one are friend requests sent by each user to the other
+func (relationship *Relationship) Crosses(other *Relationship) bool {
+	sender, otherSender := relationship.Sender(), other.Sender()
+	return sender != "" && otherSender != "" && sender != otherSender &&
+		relationship.Side(otherSender) != nil && other.Side(sender) != nil
+}
+
+// Accept turns a friend request into a friendship
+func (relationship *Relationship) Accept() {
+	relationship.User1.RelationshipType = Friend
+	relationship.User2.RelationshipType = Friend
+	relationship.ExpiresAt = nil
+}
+
+// Side returns the side of the relationship belonging to the specified user, or nil if the user isn't part of it
+func (relationship *Relationship) Side(userID string) *User {
+	switch userID {
+	case relationship.User1.UserID:
+		return &relationship.User1
+	case relationship.User2.UserID:
+		return &relationship.User2
+	}
+	return nil
+}
+
+// IsBlockedBy returns true when the specified user blocked the other user of the relationship
+// Like the pending types, the Blocked type is set on the side of the user who made the action
+func (relationship *Relationship) IsBlockedBy(userID string) bool {
+	side := relationship.Side(userID)
+	return side != nil && side.RelationshipType == Blocked
+}
+
+// IsFriendship returns true when both users accepted the relationship
+func (relationship *Relationship) IsFriendship() bool {
+	return relationship.User1.RelationshipType == Friend && relationship.User2.RelationshipType == Friend
+}
+
+// KeepPreferences copies the preferences of each user from a previous version of the relationship
+// Preferences are only edited by their user, a user leaving the relationship loses them
+func (relationship *Relationship) KeepPreferences(previous *Relationship) {
+	for _, side := range []*User{&relationship.User1, &relationship.User2} {
+		side.RelationshipPreferences = previous.PreferencesOf(side.UserID)
+	}
+}
+
+// ClearPreferences removes the preferences of both users, like on a new relationship
+func (relationship *Relationship) ClearPreferences() {
+	relationship.User1.RelationshipPreferences = RelationshipPreferences{}
+	relationship.User2.RelationshipPreferences = RelationshipPreferences{}
+}
+
+// ClearPreferencesOf removes the preferences of a user, before the relationship is shown to the other user
+func (relationship *Relationship) ClearPreferencesOf(userID string) {
+	if side := relationship.Side(userID); side != nil {
+		side.RelationshipPreferences = RelationshipPreferences{}
+	}
+}
+
+// PreferencesOf returns the preferences of the user on the relationship
+func (relationship *Relationship) PreferencesOf(userID string) RelationshipPreferences {
+	if side := relationship.Side(userID); side != nil {
+		return side.RelationshipPreferences
+	}
+	return RelationshipPreferences{}
+}
+
+// OtherUser returns the side of the relationship that isn't the specified user
+func (relationship *Relationship) OtherUser(userID string) *User {
+	if relationship.User1.UserID == userID {
+		return &relationship.User2
+	}
+	return &relationship.User1
 }
 
 // User in a relationship
 type User struct {
-	UserID           string           `json:"user_id" bson:"user_id" validate:"required"`
-	RelationshipType RelationshipType `json:"relationship_type" bson:"relationship_type" validate:"required,isRelationshipType"`
+	UserID                  string           `json:"user_id" bson:"user_id" validate:"required"`
+	RelationshipType        RelationshipType `json:"relationship_type" bson:"relationship_type" validate:"required,isRelationshipType"`
+	RelationshipPreferences `bson:",inline"`
+}
+
+// RelationshipPreferences are the private settings of a user on one of their relationships
+// Only the user can edit them and they are never shown to the other user
+type RelationshipPreferences struct {
+	Nickname  string `json:"nickname,omitempty" bson:"nickname,omitempty" validate:"max=32"`
+	Note      string `json:"note,omitempty" bson:"note,omitempty" validate:"max=500"`
+	Favourite bool   `json:"favourite,omitempty" bson:"favourite,omitempty"` // favourites are listed first with sort=favourite
 }
 
 // Detailed Relationship defines the structure for an API relationship with detailed user.
@@ -51,6 +199,10 @@ type DetailedRelationship struct {
 	ConversationID string       `json:"conversation_id" bson:"conversation_id"`
 	CreatedOn      string       `json:"created_on" bson:"created_on"`
 	UpdatedOn      string       `json:"updated_on" bson:"created_on"`
+	ExpiresAt      *time.Time   `json:"expires_at,omitempty" bson:"expires_at"`
+	// Preferences of the user whose list this relationship is part of
+	RelationshipPreferences `bson:",inline"`
+	Affinity                float64 `json:"affinity,omitempty" bson:"-"` // only set on the lists sorted by affinity
 }
 
 // Detailed User in a relationship
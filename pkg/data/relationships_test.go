@@ -1,6 +1,10 @@
 package data
 
-import "testing"
+import (
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestChecksValidation(t *testing.T) {
 	relationship := &Relationship{
@@ -29,3 +33,183 @@ func TestInvalidRelationshipType(t *testing.T) {
 		t.Errorf("A relationship type of value %s passed but RelationshipType need to be between %s and %s", relationship.User1.RelationshipType, None, PendingOutgoing)
 	}
 }
+
+func TestMergePrecedence(t *testing.T) {
+	blocked := &Relationship{
+		User1: User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: Blocked},
+		User2: User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: None},
+	}
+	friend := &Relationship{
+		User1: User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: Friend},
+		User2: User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: Friend},
+	}
+	pending := &Relationship{
+		User1: User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: PendingOutgoing},
+		User2: User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: PendingIncoming},
+	}
+
+	if !blocked.KeepsOver(friend) || !friend.KeepsOver(pending) || !blocked.KeepsOver(pending) {
+		t.Error("Expected precedence Blocked > Friend > Pending")
+	}
+	if friend.KeepsOver(friend) {
+		t.Error("Expected ties to keep the existing relationship")
+	}
+}
+
+func TestPendingRelationshipExpiration(t *testing.T) {
+	expiresAt := time.Now().UTC().Add(-time.Minute)
+	relationship := &Relationship{
+		User1:     User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: PendingOutgoing},
+		User2:     User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: PendingIncoming},
+		ExpiresAt: &expiresAt,
+	}
+
+	if !relationship.IsExpired(time.Now().UTC()) {
+		t.Error("Expected a pending relationship past its expiry to be expired")
+	}
+
+	relationship.User1.RelationshipType = Friend
+	relationship.User2.RelationshipType = Friend
+	if relationship.IsExpired(time.Now().UTC()) {
+		t.Error("Expected a relationship that isn't pending to never expire")
+	}
+}
+
+func TestGenerateFriendCode(t *testing.T) {
+	code, err := GenerateFriendCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(code) != 9 || code[4] != '-' {
+		t.Errorf("Friend code %s doesn't have the XXXX-XXXX format", code)
+	}
+	for _, character := range strings.ReplaceAll(code, "-", "") {
+		if !strings.ContainsRune(friendCodeAlphabet, character) {
+			t.Errorf("Friend code %s contains the ambiguous character %c", code, character)
+		}
+	}
+}
+
+func TestInvalidInvitePolicy(t *testing.T) {
+	settings := &UserSettings{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", InvitePolicy: "guild"}
+
+	err := settings.ValidateUserSettings()
+
+	if err == nil {
+		t.Errorf("An invite policy of value %s passed validation", settings.InvitePolicy)
+	}
+}
+
+func TestFriendGroupWithDuplicateMembers(t *testing.T) {
+	friendGroup := &FriendGroup{
+		Name:            "Raid team",
+		RelationshipIDs: []string{"c5825d3e-8a77-11eb-8dcd-0242ac130003", "c5825d3e-8a77-11eb-8dcd-0242ac130003"},
+	}
+
+	err := friendGroup.ValidateFriendGroup()
+
+	if err == nil {
+		t.Error("A friend group listing the same relationship twice passed validation")
+	}
+}
+
+func TestUpdatedRelationshipKeepsPreferences(t *testing.T) {
+	previous := &Relationship{
+		User1: User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: PendingOutgoing, RelationshipPreferences: RelationshipPreferences{Nickname: "Bob"}},
+		User2: User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: PendingIncoming, RelationshipPreferences: RelationshipPreferences{Favourite: true}},
+	}
+	relationship := &Relationship{
+		User1: User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: Friend},
+		User2: User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: Friend, RelationshipPreferences: RelationshipPreferences{Note: "Edited by the other user"}},
+	}
+
+	relationship.KeepPreferences(previous)
+
+	if !relationship.User1.Favourite || relationship.User2.Nickname != "Bob" || relationship.User2.Note != "" {
+		t.Errorf("Expected each user to keep their own preferences but got %+v", relationship)
+	}
+}
+
+func TestRelationshipTypeRegistry(t *testing.T) {
+	registry, err := LoadRelationshipTypes(strings.NewReader(`[
+		{"name": "Rival", "counterparts": ["Rival"]},
+		{"name": "Mentor", "counterparts": ["Mentee"], "transitions": ["None"]},
+		{"name": "Mentee", "counterparts": ["Mentor"]}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetRelationshipTypes(registry)
+	defer SetRelationshipTypes(mustNewRelationshipTypeRegistry())
+
+	relationship := &Relationship{
+		User1: User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: "Mentor"},
+		User2: User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: "Mentee"},
+	}
+	err = relationship.ValidateRelationship()
+	if err != nil {
+		t.Errorf("A relationship with declared types failed validation : %s", err)
+	}
+
+	relationship.User2.RelationshipType = "Rival"
+	err = relationship.ValidateRelationship()
+	if err != ErrorInvalidCounterpart {
+		t.Errorf("Expected Mentor and Rival not to be counterparts but got : %v", err)
+	}
+}
+
+func TestInvalidRelationshipTypeDeclarations(t *testing.T) {
+	declarations := []string{
+		`[{"name": "Mentor", "counterparts": ["Mentee"]}]`,
+		`[{"name": "Mentor", "counterparts": ["Rival"]}, {"name": "Rival", "counterparts": ["Rival"]}]`,
+		`[{"name": "Friend", "counterparts": ["Friend"]}]`,
+	}
+
+	for _, declaration := range declarations {
+		_, err := LoadRelationshipTypes(strings.NewReader(declaration))
+		if err == nil {
+			t.Errorf("Relationship types %s passed validation", declaration)
+		}
+	}
+}
+
+func TestBlockedUserCantBecomeFriend(t *testing.T) {
+	previous := &Relationship{
+		User1: User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: Blocked},
+		User2: User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: None},
+	}
+	relationship := &Relationship{
+		User1: User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: Friend},
+		User2: User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: Friend},
+	}
+
+	err := relationship.ValidateTransition(previous)
+
+	if err != ErrorInvalidTransition {
+		t.Errorf("Expected the transition from Blocked to Friend to be refused but got : %v", err)
+	}
+}
+
+func TestCrossingFriendRequests(t *testing.T) {
+	request := NewInvite("a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44")
+
+	if request.Crosses(NewInvite("a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44")) {
+		t.Error("A friend request sent twice by the same user doesn't cross")
+	}
+	if !request.Crosses(NewInvite("e2382ea2-b5fa-4506-aa9d-d338aa52af44", "a2181017-5c53-422b-b6bc-036b27c04fc8")) {
+		t.Error("Expected the friend requests sent by each user to cross")
+	}
+}
+
+func TestUnknownAutoAcceptRule(t *testing.T) {
+	settings := DefaultUserSettings("a2181017-5c53-422b-b6bc-036b27c04fc8")
+	settings.AutoAccept = []AutoAcceptRule{AutoAcceptGuildMates, "everyone"}
+
+	err := settings.ValidateUserSettings()
+
+	if err == nil {
+		t.Error("Settings with an unknown auto accept rule passed validation")
+	}
+}
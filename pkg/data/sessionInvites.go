@@ -0,0 +1,59 @@
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-playground/validator"
+)
+
+// ErrorSessionInviteNotFound : Session invite specific error
+var ErrorSessionInviteNotFound = fmt.Errorf("Session invite not found")
+
+// ErrorSessionInviteExist : Session invite specific error
+var ErrorSessionInviteExist = fmt.Errorf("the user already has a pending invite to this session")
+
+// ErrorSessionInviteAnswered : Session invite specific error
+var ErrorSessionInviteAnswered = fmt.Errorf("the session invite was already answered")
+
+// ErrorSessionInviteExpired : Session invite specific error
+var ErrorSessionInviteExpired = fmt.Errorf("the session invite expired")
+
+// ErrorNotFriends : Session invite specific error
+var ErrorNotFriends = fmt.Errorf("session invites can only be sent to friends")
+
+// SessionInviteStatus is the answer of the invitee to a session invite
+type SessionInviteStatus string
+
+// statuses of a session invite
+const (
+	SessionInvitePending  SessionInviteStatus = "pending"
+	SessionInviteAccepted SessionInviteStatus = "accepted"
+	SessionInviteDeclined SessionInviteStatus = "declined"
+)
+
+// SessionInvite is an invite of a friend to join the game session of the host, like a match
+type SessionInvite struct {
+	ID        string              `json:"id" bson:"_id"`
+	SessionID string              `json:"session_id" bson:"session_id" validate:"required,max=128"`
+	HostID    string              `json:"host_id" bson:"host_id"`
+	InviteeID string              `json:"invitee_id" bson:"invitee_id" validate:"required"`
+	Status    SessionInviteStatus `json:"status" bson:"status"`
+	ExpiresAt time.Time           `json:"expires_at" bson:"expires_at"`
+	CreatedOn time.Time           `json:"created_on" bson:"created_on"`
+	UpdatedOn time.Time           `json:"updated_on" bson:"updated_on"`
+}
+
+// SessionInvites is a collection of SessionInvite
+type SessionInvites []*SessionInvite
+
+// IsExpired returns true when the session invite can no longer be answered
+func (sessionInvite *SessionInvite) IsExpired(now time.Time) bool {
+	return !now.Before(sessionInvite.ExpiresAt)
+}
+
+// ValidateSessionInvite a session invite with json validation
+func (sessionInvite *SessionInvite) ValidateSessionInvite() error {
+	validate := validator.New()
+	return validate.Struct(sessionInvite)
+}
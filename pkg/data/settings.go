@@ -0,0 +1,116 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/go-playground/validator"
+)
+
+// ErrorInvitesNotAllowed : Privacy specific error
+var ErrorInvitesNotAllowed = fmt.Errorf("the user doesn't accept friend requests from this user")
+
+// InvitePolicy defines who may send friend requests to a user
+type InvitePolicy string
+
+// invite policies of a user
+const (
+	InvitesFromEveryone         InvitePolicy = "everyone"           // anyone can send a friend request
+	InvitesFromFriendsOfFriends InvitePolicy = "friends_of_friends" // only users with a mutual friend can send a friend request
+	InvitesFromNobody           InvitePolicy = "nobody"             // friend requests are refused
+)
+
+// AutoAcceptRule defines friend requests accepted without waiting for the user
+type AutoAcceptRule string
+
+// auto accept rules of a user
+const (
+	AutoAcceptFriendsOfFriends AutoAcceptRule = "friends_of_friends" // requests from users with a mutual friend
+	AutoAcceptGuildMates       AutoAcceptRule = "guild_mates"        // requests from users of the same guild
+)
+
+// FriendsListView defines what another user sees of a friends list
+type FriendsListView string
+
+// views of a friends list
+const (
+	FriendsListFull   FriendsListView = "full"   // every friend
+	FriendsListMutual FriendsListView = "mutual" // only the friends shared with the viewer
+	FriendsListCount  FriendsListView = "count"  // only the number of friends
+	FriendsListHidden FriendsListView = "hidden" // nothing, the request is refused
+)
+
+// UserSettings defines the privacy settings of a user
+type UserSettings struct {
+	UserID             string           `json:"user_id" bson:"_id"`
+	InvitePolicy       InvitePolicy     `json:"invite_policy" bson:"invite_policy" validate:"required,isInvitePolicy"`
+	FriendsListVisible bool             `json:"friends_list_visible" bson:"friends_list_visible"`                                                     // other users can see the friends list
+	FriendsView        FriendsListView  `json:"friends_view,omitempty" bson:"friends_view" validate:"omitempty,oneof=full mutual count hidden"`       // what friends of the user see, full by default
+	StrangersView      FriendsListView  `json:"strangers_view,omitempty" bson:"strangers_view" validate:"omitempty,oneof=count hidden"`               // what other users see, count by default
+	AutoAccept         []AutoAcceptRule `json:"auto_accept,omitempty" bson:"auto_accept" validate:"unique,dive,oneof=friends_of_friends guild_mates"` // friend requests accepted right away, when any rule matches
+	UpdatedOn          string           `json:"updated_on" bson:"updated_on"`
+}
+
+// FriendsCount is the friends list of a user as seen by a viewer only allowed to count the friends
+type FriendsCount struct {
+	UserID string `json:"user_id"`
+	Count  int    `json:"count"`
+}
+
+// DefaultUserSettings returns the settings of a user who never changed them
+func DefaultUserSettings(userID string) *UserSettings {
+	return &UserSettings{
+		UserID:             userID,
+		InvitePolicy:       InvitesFromEveryone,
+		FriendsListVisible: true,
+		FriendsView:        FriendsListFull,
+		StrangersView:      FriendsListCount,
+	}
+}
+
+// FriendsListViewFor returns what a viewer sees of the friends list, depending on whether the viewer is a friend of the user
+func (settings *UserSettings) FriendsListViewFor(isFriend bool) FriendsListView {
+	if !settings.FriendsListVisible {
+		return FriendsListHidden
+	}
+	if isFriend {
+		if settings.FriendsView == "" {
+			return FriendsListFull
+		}
+		return settings.FriendsView
+	}
+	if settings.StrangersView == "" {
+		return FriendsListCount
+	}
+	return settings.StrangersView
+}
+
+// AutoAccepts returns true when the user accepts friend requests matching the rule without answering them
+func (settings *UserSettings) AutoAccepts(rule AutoAcceptRule) bool {
+	for _, autoAcceptRule := range settings.AutoAccept {
+		if autoAcceptRule == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateUserSettings user settings with json validation
+func (settings *UserSettings) ValidateUserSettings() error {
+	validate := validator.New()
+
+	err := validate.RegisterValidation("isInvitePolicy", validateIsInvitePolicy)
+	if err != nil {
+		panic(err)
+	}
+
+	return validate.Struct(settings)
+}
+
+// validates the invite policy is valid
+func validateIsInvitePolicy(fieldLevel validator.FieldLevel) bool {
+	switch InvitePolicy(fieldLevel.Field().String()) {
+	case InvitesFromEveryone, InvitesFromFriendsOfFriends, InvitesFromNobody:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-playground/validator"
+)
+
+// ErrorUserFrozen : User specific error
+var ErrorUserFrozen = fmt.Errorf("the user is banned, their relationships are frozen")
+
+// UserEventType is the kind of account change published by microservice-user
+type UserEventType string
+
+// types of the account events handled by the service
+const (
+	UserDeleted  UserEventType = "UserDeleted"  // the account was deleted, its relationships are deleted
+	UserBanned   UserEventType = "UserBanned"   // the account was banned, its relationships are frozen
+	UserUnbanned UserEventType = "UserUnbanned" // the ban was lifted, its relationships are back
+	UserUpdated  UserEventType = "UserUpdated"  // the profile changed, like the username
+)
+
+// UserEvent is an account change received from microservice-user
+type UserEvent struct {
+	ID         string        `json:"id" validate:"required"`
+	Type       UserEventType `json:"type" validate:"required,oneof=UserDeleted UserBanned UserUnbanned UserUpdated"`
+	UserID     string        `json:"user_id" validate:"required"`
+	OccurredAt time.Time     `json:"occurred_at"`
+}
+
+// ValidateUserEvent a user event with json validation
+func (userEvent *UserEvent) ValidateUserEvent() error {
+	validate := validator.New()
+	return validate.Struct(userEvent)
+}
@@ -18,16 +18,25 @@ func (relationship *Relationship) ValidateRelationship() error {
 		panic(ErrorInvalidRelationshipType)
 	}
 	
-	return validate.Struct(relationship)
+	err = validate.Struct(relationship)
+	if err != nil {
+		return err
+	}
+
+	if !relationshipTypes.AreCounterparts(relationship.User1.RelationshipType, relationship.User2.RelationshipType) {
+		return ErrorInvalidCounterpart
+	}
+	return nil
 }
 
-// validates the relationship type is valid
-func validateIsRelationshipType(fieldLevel validator.FieldLevel) bool {
-	relationshipType := fieldLevel.Field().String()
+// ValidatePreferences relationship preferences with json validation
+func (preferences *RelationshipPreferences) ValidatePreferences() error {
+	validate := validator.New()
+	return validate.Struct(preferences)
+}
 
-	switch relationshipType {
-    case string(None), string(Friend), string(Blocked), string(PendingIncoming), string(PendingOutgoing):
-        return true
-    }
-	return false
+// validates the relationship type is registered
+func validateIsRelationshipType(fieldLevel validator.FieldLevel) bool {
+	_, ok := relationshipTypes.Lookup(RelationshipType(fieldLevel.Field().String()))
+	return ok
 }
@@ -0,0 +1,105 @@
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator"
+)
+
+// ErrorWebhookNotFound : Webhook specific error
+var ErrorWebhookNotFound = fmt.Errorf("Webhook not found")
+
+// WebhookDeliveryStatus is the state of the delivery of an event to a webhook
+type WebhookDeliveryStatus string
+
+// statuses of a webhook delivery, delivered events are removed
+const (
+	WebhookDeliveryPending WebhookDeliveryStatus = "pending" // waiting for its next attempt
+	WebhookDeliveryDead    WebhookDeliveryStatus = "dead"    // every attempt failed, waiting to be replayed
+)
+
+// Webhook is an HTTP endpoint of a game backend receiving the relationship events
+type Webhook struct {
+	ID         string   `json:"id" bson:"_id"`
+	URL        string   `json:"url" bson:"url" validate:"required,url"`
+	EventTypes []string `json:"event_types" bson:"event_types" validate:"unique,dive,required"` // every event when empty
+	Secret     string   `json:"secret,omitempty" bson:"secret" validate:"required,min=16"`      // signs the payloads, never returned
+	CreatedOn  string   `json:"created_on" bson:"created_on"`
+	UpdatedOn  string   `json:"updated_on" bson:"updated_on"`
+}
+
+// Webhooks is a collection of Webhook
+type Webhooks []*Webhook
+
+// WebhookDelivery is an event waiting to be sent to a webhook
+type WebhookDelivery struct {
+	ID             string                `json:"id" bson:"_id"`
+	WebhookID      string                `json:"webhook_id" bson:"webhook_id"`
+	EventID        string                `json:"event_id" bson:"event_id"`
+	EventType      string                `json:"event_type" bson:"event_type"`
+	Payload        string                `json:"payload" bson:"payload"` // event as sent, in JSON
+	Status         WebhookDeliveryStatus `json:"status" bson:"status"`
+	Attempts       int                   `json:"attempts" bson:"attempts"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at" bson:"next_attempt_at"`
+	LastError      string                `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	LastStatusCode int                   `json:"last_status_code,omitempty" bson:"last_status_code,omitempty"`
+	CreatedOn      time.Time             `json:"created_on" bson:"created_on"`
+}
+
+// WebhookDeliveries is a collection of WebhookDelivery
+type WebhookDeliveries []*WebhookDelivery
+
+// ReplayRequest selects the dead deliveries of a webhook to send again, every dead delivery when empty
+type ReplayRequest struct {
+	DeliveryIDs []string `json:"delivery_ids" validate:"unique,dive,required"`
+}
+
+// ReplayResult is the number of deliveries sent again
+type ReplayResult struct {
+	Replayed int64 `json:"replayed"`
+}
+
+// ValidateWebhook a webhook with json validation
+func (webhook *Webhook) ValidateWebhook() error {
+	validate := validator.New()
+	return validate.Struct(webhook)
+}
+
+// ValidateReplayRequest a replay request with json validation
+func (replayRequest *ReplayRequest) ValidateReplayRequest() error {
+	validate := validator.New()
+	return validate.Struct(replayRequest)
+}
+
+// Accepts returns true when the webhook receives the events of this type
+func (webhook *Webhook) Accepts(eventType string) bool {
+	if len(webhook.EventTypes) == 0 {
+		return true
+	}
+	for _, accepted := range webhook.EventTypes {
+		if accepted == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted returns a copy of the webhook without its secret
+func (webhook *Webhook) Redacted() *Webhook {
+	redacted := *webhook
+	redacted.Secret = ""
+	return &redacted
+}
+
+// SignWebhookPayload returns the signature of a payload sent at timestamp, the hex encoded HMAC-SHA256 of "<timestamp>.<payload>"
+func SignWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
+)
+
+// errorAffinityConflict : Affinity specific error
+var errorAffinityConflict = fmt.Errorf("affinity changed by concurrent signals")
+
+// weight of each interaction in the affinity, before it decays
+var affinityWeights = map[data.AffinitySignalType]float64{
+	data.AffinityMatch:         3,
+	data.AffinitySessionInvite: 2,
+	data.AffinityMessage:       0.25,
+}
+
+// affinityRetries bounds the attempts to count a signal while other signals change the same affinity
+const affinityRetries = 5
+
+// affinityHalfLife is the time an interaction takes to lose half its weight, 0 disables the decay
+func affinityHalfLife() time.Duration {
+	return envDuration("AFFINITY_HALF_LIFE", 7*24*time.Hour)
+}
+
+// affinitySignalRetention is how long the counted signals are remembered, a signal received again within it isn't counted twice
+func affinitySignalRetention() time.Duration {
+	return envDuration("AFFINITY_SIGNAL_RETENTION", 24*time.Hour)
+}
+
+// affinityID identifies the affinity of two users, the same whatever their order
+func affinityID(userID1 string, userID2 string) string {
+	if userID2 < userID1 {
+		userID1, userID2 = userID2, userID1
+	}
+	return userID1 + ":" + userID2
+}
+
+// newAffinity returns the affinity of two friends who haven't interacted yet
+func newAffinity(userID1 string, userID2 string) *data.Affinity {
+	userIDs := []string{userID1, userID2}
+	sort.Strings(userIDs)
+	return &data.Affinity{ID: affinityID(userID1, userID2), UserIDs: userIDs}
+}
+
+// newCountedSignal returns the record of a signal counted in the affinity of two friends
+func newCountedSignal(userID1 string, userID2 string, signal *data.AffinitySignal) *data.CountedSignal {
+	userIDs := []string{userID1, userID2}
+	sort.Strings(userIDs)
+	return &data.CountedSignal{ID: affinityID(userID1, userID2) + ":" + signal.ID, UserIDs: userIDs, CountedOn: time.Now().UTC()}
+}
+
+// decayAffinity returns what is left of a score after elapsed time
+func decayAffinity(score float64, elapsed time.Duration, halfLife time.Duration) float64 {
+	if halfLife <= 0 || elapsed <= 0 {
+		return score
+	}
+	return score * math.Pow(0.5, float64(elapsed)/float64(halfLife))
+}
+
+// currentAffinity returns the score of an affinity at now, rounded for display
+func currentAffinity(affinity *data.Affinity, now time.Time, halfLife time.Duration) float64 {
+	score := decayAffinity(affinity.Score, now.Sub(affinity.UpdatedOn), halfLife)
+	return math.Round(score*1000) / 1000
+}
+
+// addAffinitySignal counts a signal in an affinity, the signals already counted are ignored by the database
+// Signals received out of order decay from the time they occurred, the score doesn't depend on their order
+func addAffinitySignal(affinity *data.Affinity, signal *data.AffinitySignal, halfLife time.Duration) {
+	weight := affinityWeights[signal.Type]
+	if signal.OccurredAt.After(affinity.UpdatedOn) {
+		affinity.Score = decayAffinity(affinity.Score, signal.OccurredAt.Sub(affinity.UpdatedOn), halfLife) + weight
+		affinity.UpdatedOn = signal.OccurredAt
+	} else {
+		affinity.Score += decayAffinity(weight, affinity.UpdatedOn.Sub(signal.OccurredAt), halfLife)
+	}
+	affinity.Version++
+}
+
+// affinityPairs returns the pairs of friends a signal is counted for
+func affinityPairs(ctx context.Context, db RelationshipDB, signal *data.AffinitySignal) ([][2]string, error) {
+	users := map[string]bool{}
+	for _, userID := range signal.UserIDs {
+		users[userID] = true
+	}
+	senders := signal.UserIDs
+	if signal.SenderID != "" {
+		senders = []string{signal.SenderID}
+	}
+
+	counted := map[string]bool{}
+	pairs := [][2]string{}
+	for _, senderID := range senders {
+		friendIDs, err := db.GetFriendIDsByUserID(ctx, senderID)
+		if err != nil {
+			return nil, err
+		}
+		for _, friendID := range friendIDs {
+			id := affinityID(senderID, friendID)
+			if !users[friendID] || counted[id] {
+				continue
+			}
+			counted[id] = true
+			pairs = append(pairs, [2]string{senderID, friendID})
+		}
+	}
+	return pairs, nil
+}
+
+// AffinityRecorder counts the session invites of the relationship events in the affinity of the friends, and forgets the affinity of a friendship that ended
+// It is a publisher, registered next to the event broker
+type AffinityRecorder struct {
+	db RelationshipDB
+}
+
+// NewAffinityRecorder returns a recorder saving the affinities in db
+func NewAffinityRecorder(db RelationshipDB) *AffinityRecorder {
+	return &AffinityRecorder{db: db}
+}
+
+// Publish counts a sent or accepted session invite, and deletes the affinity of friends who are no longer friends
+func (recorder *AffinityRecorder) Publish(ctx context.Context, event *events.Event) error {
+	switch event.Type {
+	case events.SessionInviteSent, events.SessionInviteAccepted:
+		occurredAt := event.OccurredAt
+		if occurredAt.IsZero() {
+			occurredAt = time.Now().UTC()
+		}
+		return recorder.db.AddAffinitySignal(ctx, &data.AffinitySignal{
+			ID:         event.ID,
+			Type:       data.AffinitySessionInvite,
+			UserIDs:    []string{event.UserID, event.OtherUserID},
+			OccurredAt: occurredAt,
+		})
+	case events.FriendRemoved:
+		return recorder.db.DeleteAffinity(ctx, event.UserID, event.OtherUserID)
+	}
+	return nil
+}
+
+// Close has nothing to release, the affinities are saved in the database
+func (recorder *AffinityRecorder) Close() error {
+	return nil
+}
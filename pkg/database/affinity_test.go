@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
+)
+
+func TestAddAffinitySignalDecays(t *testing.T) {
+	now := time.Now().UTC()
+	affinity := newAffinity("1e2f3a4b-8a7e-11eb-8dcd-0242ac130003", "2f3a4b5c-8a7e-11eb-8dcd-0242ac130003")
+	match := &data.AffinitySignal{ID: "match:1", Type: data.AffinityMatch, OccurredAt: now}
+	addAffinitySignal(affinity, match, time.Hour)
+
+	// The match lost half its weight after an hour
+	score := currentAffinity(affinity, now.Add(time.Hour), time.Hour)
+	if score != affinityWeights[data.AffinityMatch]/2 {
+		t.Errorf("Expected half the weight of a match but got %f", score)
+	}
+
+	// A signal received late decays from the time it occurred
+	late := &data.AffinitySignal{ID: "match:0", Type: data.AffinityMatch, OccurredAt: now.Add(-time.Hour)}
+	addAffinitySignal(affinity, late, time.Hour)
+	expected := affinityWeights[data.AffinityMatch] * 1.5
+	if math.Abs(currentAffinity(affinity, now, time.Hour)-expected) > 0.001 || !affinity.UpdatedOn.Equal(now) {
+		t.Errorf("Expected an affinity of %f but got %f", expected, currentAffinity(affinity, now, time.Hour))
+	}
+}
+
+func TestAddAffinitySignalCountsLateSignalsOnce(t *testing.T) {
+	os.Setenv("AFFINITY_HALF_LIFE", "1h")
+	defer os.Unsetenv("AFFINITY_HALF_LIFE")
+
+	ctx := context.Background()
+	userIDs := []string{"3a4b5c6d-8a85-11eb-8dcd-0242ac130003", "4b5c6d7e-8a85-11eb-8dcd-0242ac130003"}
+	db := NewMockRelationships(events.NewMemoryBroker())
+	err := db.AddRelationship(ctx, data.NewFriendship(userIDs[0], userIDs[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	affinity := func() float64 {
+		scores, err := db.GetAffinities(ctx, userIDs[0], userIDs[1:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return scores[userIDs[1]]
+	}
+
+	now := time.Now().UTC()
+	first := &data.AffinitySignal{ID: "message:0", Type: data.AffinityMessage, UserIDs: userIDs, SenderID: userIDs[0], OccurredAt: now}
+	for i := 0; i <= 50; i++ {
+		message := &data.AffinitySignal{ID: fmt.Sprintf("message:%d", i), Type: data.AffinityMessage, UserIDs: userIDs, SenderID: userIDs[0], OccurredAt: now}
+		err = db.AddAffinitySignal(ctx, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A signal received again is recognized however many signals were counted since
+	score := affinity()
+	err = db.AddAffinitySignal(ctx, first)
+	if err != nil || affinity() != score {
+		t.Errorf("Expected the first message to be counted once, got %f instead of %f", affinity(), score)
+	}
+
+	// A match received hours late, like a roster sent at the end of a long match, still counts from the time it started
+	match := &data.AffinitySignal{ID: "match:long", Type: data.AffinityMatch, UserIDs: userIDs, OccurredAt: now.Add(-3 * time.Hour)}
+	err = db.AddAffinitySignal(ctx, match)
+	expected := score + affinityWeights[data.AffinityMatch]/8
+	if err != nil || math.Abs(affinity()-expected) > 0.002 {
+		t.Errorf("Expected an affinity of %f with the late match but got %f", expected, affinity())
+	}
+}
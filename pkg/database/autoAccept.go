@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+)
+
+// autoAcceptGraph is the part of a database needed to evaluate the auto accept rules of the users
+type autoAcceptGraph interface {
+	socialGraph
+	getGuildID(ctx context.Context, userID string) (string, error)
+}
+
+// autoAcceptInvite turns a friend request into a friendship when one of the auto accept rules of its receiver matches the sender
+// The request stays pending when the friendship would put one of the users over their friend limit
+func autoAcceptInvite(ctx context.Context, graph autoAcceptGraph, policy FriendLimitPolicy, relationship *data.Relationship, countFriends func(userID string) (int64, error)) error {
+	accepted, err := autoAccepts(ctx, graph, relationship)
+	if err != nil || !accepted {
+		return err
+	}
+
+	friendship := *relationship
+	friendship.Accept()
+	err = checkFriendLimits(ctx, policy, &friendship, countFriends)
+	var limitError *data.FriendLimitError
+	if errors.As(err, &limitError) {
+		log.Info("Friend request not auto accepted, friend limit reached", "user_id", limitError.UserID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Info("Friend request auto accepted", "sender_id", relationship.Sender())
+	*relationship = friendship
+	return nil
+}
+
+// autoAccepts returns true when the receiver of a friend request has an auto accept rule matching its sender
+func autoAccepts(ctx context.Context, graph autoAcceptGraph, relationship *data.Relationship) (bool, error) {
+	senderID := relationship.Sender()
+	if senderID == "" {
+		return false, nil
+	}
+	receiverID := relationship.OtherUser(senderID).UserID
+
+	settings, err := graph.GetUserSettings(ctx, receiverID)
+	if err != nil {
+		return false, err
+	}
+
+	if settings.AutoAccepts(data.AutoAcceptFriendsOfFriends) {
+		mutual, err := hasMutualFriend(ctx, graph, senderID, receiverID)
+		if err != nil || mutual {
+			return mutual, err
+		}
+	}
+
+	if settings.AutoAccepts(data.AutoAcceptGuildMates) {
+		return areGuildMates(ctx, graph, senderID, receiverID)
+	}
+	return false, nil
+}
+
+// areGuildMates returns true when two users are members of the same guild
+func areGuildMates(ctx context.Context, graph autoAcceptGraph, userID1 string, userID2 string) (bool, error) {
+	guildID1, err := graph.getGuildID(ctx, userID1)
+	if err != nil || guildID1 == "" {
+		return false, err
+	}
+	guildID2, err := graph.getGuildID(ctx, userID2)
+	if err != nil {
+		return false, err
+	}
+	return guildID1 == guildID2, nil
+}
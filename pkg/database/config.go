@@ -0,0 +1,65 @@
+package database
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+)
+
+// envDuration reads a duration such as "720h" from an environment variable
+// The default value is used when the variable is missing or invalid
+func envDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		log.Error(err, "Invalid duration in environment variable, using default value", "key", key, "value", value, "default", defaultValue.String())
+		return defaultValue
+	}
+	return duration
+}
+
+// envInt reads a positive integer from an environment variable
+// The default value is used when the variable is missing or invalid
+func envInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		log.Error(err, "Invalid integer in environment variable, using default value", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return number
+}
+
+// inviteTTL is the time a friend request stays pending before it expires
+// An INVITE_TTL of 0 disables the expiration of friend requests
+func inviteTTL() time.Duration {
+	return envDuration("INVITE_TTL", 30*24*time.Hour)
+}
+
+// inviteExpiry returns the expiry of a relationship stored now, nil when it doesn't expire
+func inviteExpiry(relationship *data.Relationship, ttl time.Duration) *time.Time {
+	if ttl == 0 || !relationship.IsPending() {
+		return nil
+	}
+	expiresAt := time.Now().UTC().Add(ttl)
+	return &expiresAt
+}
+
+// updatedInviteExpiry returns the expiry of a relationship replacing previous
+// A friend request keeps the expiry it got when it was sent, an update doesn't extend it
+func updatedInviteExpiry(relationship *data.Relationship, previous *data.Relationship, ttl time.Duration) *time.Time {
+	if relationship.IsPending() && previous.IsPending() && relationship.Sender() == previous.Sender() {
+		return previous.ExpiresAt
+	}
+	return inviteExpiry(relationship, ttl)
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
+)
+
+func TestUpdateRelationshipKeepsInviteExpiry(t *testing.T) {
+	db := NewMockRelationships(events.NewMemoryBroker())
+	relationship := &data.Relationship{
+		User1: data.User{UserID: "5c6d7e8f-8a7e-11eb-8dcd-0242ac130003", RelationshipType: data.PendingOutgoing},
+		User2: data.User{UserID: "6d7e8f9a-8a7e-11eb-8dcd-0242ac130003", RelationshipType: data.PendingIncoming},
+	}
+	err := db.AddRelationship(context.Background(), relationship)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if relationship.ExpiresAt == nil {
+		t.Fatal("Expected the friend request to expire")
+	}
+	expiresAt := *relationship.ExpiresAt
+
+	// An update of the pending friend request doesn't extend it
+	updated := *relationship
+	updated.ExpiresAt = nil
+	err = db.UpdateRelationship(context.Background(), &updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.ExpiresAt == nil || !updated.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Expected the expiry %s to be kept but got %v", expiresAt, updated.ExpiresAt)
+	}
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/Ubivius/microservice-friendslist/pkg/data"
 )
@@ -10,11 +11,92 @@ import (
 type RelationshipDB interface {
 	GetFriendsListByUserID(ctx context.Context, userID string) (*data.DetailedRelationships, error)
 	GetInvitesListByUserID(ctx context.Context, userID string) (*data.DetailedRelationships, error)
+	GetRelationshipsByUserIDAndType(ctx context.Context, userID string, relationshipType data.RelationshipType) (*data.DetailedRelationships, error)
+	GetFriendIDsByUserID(ctx context.Context, userID string) ([]string, error)
+	GetBlockerIDsByUserID(ctx context.Context, userID string) ([]string, error)
+	GetRelationshipByUserIDs(ctx context.Context, userID1 string, userID2 string) (*data.Relationship, error)
 	UpdateRelationship(ctx context.Context, relationship *data.Relationship) error
+	// AddRelationship stores a new relationship
+	// A friend request crossing a pending request of the other user accepts it instead, relationship is then replaced by the friendship
+	// A friend request matching an auto accept rule of its receiver is stored as a friendship
 	AddRelationship(ctx context.Context, relationship *data.Relationship) error
+	UpdateRelationshipPreferences(ctx context.Context, id string, userID string, preferences *data.RelationshipPreferences) error
 	DeleteRelationship(ctx context.Context, id string) error
+	DeleteExpiredInvites(ctx context.Context) (int64, error)
+	// RelayOutbox publishes the events saved with the relationship changes, and returns how many were published
+	RelayOutbox(ctx context.Context) (int, error)
+	// ExportRelationshipsByUserID hands every relationship of the user to export, the follows included as Follower and Following relationships
+	ExportRelationshipsByUserID(ctx context.Context, userID string, export func(*data.Relationship) error) error
+	AddPartyFriendships(ctx context.Context, userIDs []string) (*data.PartyFriendshipResult, error)
+	MergeUserRelationships(ctx context.Context, sourceUserID string, targetUserID string) (*data.MergeResult, error)
+	AddFriendCode(ctx context.Context, friendCode *data.FriendCode) error
+	GetFriendCodesByUserID(ctx context.Context, userID string) (data.FriendCodes, error)
+	GetFriendCode(ctx context.Context, code string) (*data.FriendCode, error)
+	DeleteFriendCode(ctx context.Context, code string) error
+	RedeemFriendCode(ctx context.Context, code string, userID string) (*data.Relationship, error)
+	AddFriendGroup(ctx context.Context, friendGroup *data.FriendGroup) error
+	GetFriendGroupsByUserID(ctx context.Context, userID string) (data.FriendGroups, error)
+	GetFriendGroup(ctx context.Context, id string) (*data.FriendGroup, error)
+	UpdateFriendGroup(ctx context.Context, friendGroup *data.FriendGroup) error
+	DeleteFriendGroup(ctx context.Context, id string) error
+	AddFollow(ctx context.Context, followerID string, followedID string) (*data.Follow, error)
+	DeleteFollow(ctx context.Context, followerID string, followedID string) error
+	GetFollowersByUserID(ctx context.Context, userID string, offset int, limit int) (*data.DetailedRelationships, error)
+	GetFollowingByUserID(ctx context.Context, userID string, offset int, limit int) (*data.DetailedRelationships, error)
+	GetFollowCounts(ctx context.Context, userID string) (*data.FollowCounts, error)
+	AddWebhook(ctx context.Context, webhook *data.Webhook) error
+	GetWebhooks(ctx context.Context) (data.Webhooks, error)
+	GetWebhook(ctx context.Context, id string) (*data.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *data.Webhook) error
+	DeleteWebhook(ctx context.Context, id string) error
+	AddWebhookDeliveries(ctx context.Context, deliveries data.WebhookDeliveries) error
+	// ClaimWebhookDeliveries returns the pending deliveries due for an attempt, hiding them from the other replicas while they are sent
+	ClaimWebhookDeliveries(ctx context.Context, limit int) (data.WebhookDeliveries, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery *data.WebhookDelivery) error
+	DeleteWebhookDelivery(ctx context.Context, id string) error
+	GetDeadWebhookDeliveries(ctx context.Context, webhookID string) (data.WebhookDeliveries, error)
+	// ReplayWebhookDeliveries sends the dead deliveries of a webhook again, every one of them when deliveryIDs is empty
+	ReplayWebhookDeliveries(ctx context.Context, webhookID string, deliveryIDs []string) (int64, error)
+	// DeleteUserRelationships deletes everything the service knows about a deleted account, and returns how many relationships were deleted
+	DeleteUserRelationships(ctx context.Context, userID string) (int64, error)
+	// SetUserFrozen freezes the relationships of a banned user, they are hidden and can't change until the user is unfrozen
+	// A ban or unban that occurred before the last one applied to the user is ignored
+	SetUserFrozen(ctx context.Context, userID string, frozen bool, occurredAt time.Time) error
+	InvalidateUserDetails(ctx context.Context, userID string) error
+	// SetPresence stores the status of a user, unless a newer status was already received
+	SetPresence(ctx context.Context, presence *data.Presence) error
+	// GetPresenceStatuses returns the statuses of the users, users without a recent status are missing
+	GetPresenceStatuses(ctx context.Context, userIDs []string) (map[string]string, error)
+	// AddFeedItems saves activities in the feeds of their users, skipping the saved ones, and keeps the newest items of each feed
+	AddFeedItems(ctx context.Context, items data.FeedItems, keep int) error
+	// GetFeedByUserID returns a page of the feed of a user, newest first
+	GetFeedByUserID(ctx context.Context, userID string, offset int, limit int) (data.FeedItems, error)
+	// DeleteFeedItemsBetween deletes the activities about two users, once they are no longer friends
+	DeleteFeedItemsBetween(ctx context.Context, userID1 string, userID2 string) error
+	// AddSessionInvite invites a friend of the host to a game session, the invite expires after SESSION_INVITE_TTL
+	AddSessionInvite(ctx context.Context, sessionInvite *data.SessionInvite) error
+	GetSessionInvite(ctx context.Context, id string) (*data.SessionInvite, error)
+	// GetSessionInvitesByUserID returns the session invites the user can still answer, newest first
+	GetSessionInvitesByUserID(ctx context.Context, userID string) (data.SessionInvites, error)
+	// AnswerSessionInvite accepts or declines a session invite of the user
+	AnswerSessionInvite(ctx context.Context, id string, userID string, accept bool) (*data.SessionInvite, error)
+	// AddMatchRoster counts a match played together for every pair of its users, a roster sent again isn't counted twice
+	AddMatchRoster(ctx context.Context, matchRoster *data.MatchRoster) error
+	// GetRecentPlayersByUserID returns a page of the users who recently played with the user, last played first
+	// Banned co-players are left out of the page, which can then hold fewer than limit co-players
+	GetRecentPlayersByUserID(ctx context.Context, userID string, offset int, limit int) (data.RecentPlayers, error)
+	GetFriendSuggestions(ctx context.Context, userID string, limit int) (data.FriendSuggestions, error)
+	// AddAffinitySignal counts an interaction in the affinity of every pair of friends it concerns, a signal received again isn't counted twice
+	// A signal older than the signal window before the newest interaction of a pair isn't counted for that pair
+	AddAffinitySignal(ctx context.Context, signal *data.AffinitySignal) error
+	// GetAffinities returns the current affinity of the user with each friend, friends who never interacted with the user are missing
+	GetAffinities(ctx context.Context, userID string, friendIDs []string) (map[string]float64, error)
+	DeleteAffinity(ctx context.Context, userID1 string, userID2 string) error
+	GetUserSettings(ctx context.Context, userID string) (*data.UserSettings, error)
+	UpdateUserSettings(ctx context.Context, settings *data.UserSettings) error
 	GetUserDetails(userID string, relations data.Relationships) (*data.DetailedRelationships, error)
 	GetUserByID(userID string) (*data.DetailedUser, error)
+	FindUsers(ctx context.Context, lookup *data.UserLookup) ([]*data.DetailedUser, error)
 	Connect() error
 	PingDB() error
 	CloseDB()
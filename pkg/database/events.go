@@ -0,0 +1,22 @@
+package database
+
+import (
+	"context"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
+)
+
+// publishChange publishes the events of a saved relationship change, previous is nil for a new relationship and current is nil for a deleted one
+// The change is already saved, a failure to publish is only logged
+func publishChange(ctx context.Context, publisher events.Publisher, previous *data.Relationship, current *data.Relationship) {
+	publishEvents(ctx, publisher, events.FromChange(previous, current))
+}
+
+// publishEvents publishes the events of a saved change, a failure to publish is only logged
+func publishEvents(ctx context.Context, publisher events.Publisher, changeEvents events.Events) {
+	err := events.PublishAll(ctx, publisher, changeEvents)
+	if err != nil {
+		log.Error(err, "Error publishing relationship events")
+	}
+}
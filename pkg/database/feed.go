@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
+)
+
+// FeedRecorder saves the activities of the relationship events in the feeds of the users they concern
+// It is a publisher, registered next to the event broker
+type FeedRecorder struct {
+	db        RelationshipDB
+	retention time.Duration
+	maxItems  int
+}
+
+// NewFeedRecorder reads the time activities are kept from FEED_RETENTION, and the number of activities kept in a feed from FEED_MAX_ITEMS
+func NewFeedRecorder(db RelationshipDB) *FeedRecorder {
+	maxItems := envInt("FEED_MAX_ITEMS", 100)
+	if maxItems == 0 {
+		maxItems = 1
+	}
+	return &FeedRecorder{
+		db:        db,
+		retention: envDuration("FEED_RETENTION", 30*24*time.Hour),
+		maxItems:  maxItems,
+	}
+}
+
+// Publish saves the activities of a new friendship, and removes the activities of a friendship that ended
+func (recorder *FeedRecorder) Publish(ctx context.Context, event *events.Event) error {
+	switch event.Type {
+	case events.InviteAccepted, events.FriendAdded:
+		items, err := recorder.friendshipItems(ctx, event)
+		if err != nil {
+			return err
+		}
+		return recorder.db.AddFeedItems(ctx, items, recorder.maxItems)
+	case events.FriendRemoved:
+		return recorder.db.DeleteFeedItemsBetween(ctx, event.UserID, event.OtherUserID)
+	}
+	return nil
+}
+
+// Close has nothing to release, the activities are saved in the database
+func (recorder *FeedRecorder) Close() error {
+	return nil
+}
+
+// friendshipItems returns the activities of a new friendship
+// The sender of an accepted friend request is told, and the friends of both users see they became friends
+func (recorder *FeedRecorder) friendshipItems(ctx context.Context, event *events.Event) (data.FeedItems, error) {
+	createdOn := event.OccurredAt
+	if createdOn.IsZero() {
+		createdOn = time.Now().UTC()
+	}
+	newItem := func(userID string, itemType data.FeedItemType, actorID string, subjectID string) *data.FeedItem {
+		// Derived from the event, an event published again saves the same items
+		return &data.FeedItem{
+			ID:        event.ID + ":" + userID,
+			UserID:    userID,
+			Type:      itemType,
+			ActorID:   actorID,
+			SubjectID: subjectID,
+			EventID:   event.ID,
+			CreatedOn: createdOn,
+			ExpiresAt: createdOn.Add(recorder.retention),
+		}
+	}
+
+	items := data.FeedItems{}
+	if event.Type == events.InviteAccepted {
+		// The user of the event accepted the friend request of the other user
+		items = append(items, newItem(event.OtherUserID, data.FeedInviteAccepted, event.UserID, event.OtherUserID))
+	}
+
+	// A mutual friend of both users sees the friendship once
+	notified := map[string]bool{event.UserID: true, event.OtherUserID: true}
+	for _, pair := range [][2]string{{event.UserID, event.OtherUserID}, {event.OtherUserID, event.UserID}} {
+		friendIDs, err := recorder.db.GetFriendIDsByUserID(ctx, pair[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, friendID := range friendIDs {
+			if notified[friendID] {
+				continue
+			}
+			notified[friendID] = true
+			items = append(items, newItem(friendID, data.FeedFriendAdded, pair[0], pair[1]))
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
+)
+
+func TestFeedRecorderKeepsNewestItems(t *testing.T) {
+	os.Setenv("FEED_MAX_ITEMS", "2")
+	defer os.Unsetenv("FEED_MAX_ITEMS")
+
+	db := NewMockRelationships(events.NewMemoryBroker())
+	recorder := NewFeedRecorder(db)
+	now := time.Now().UTC()
+	for i, accepterID := range []string{"1e2f3a4b-8a7e-11eb-8dcd-0242ac130003", "2f3a4b5c-8a7e-11eb-8dcd-0242ac130003", "3a4b5c6d-8a7e-11eb-8dcd-0242ac130003"} {
+		err := recorder.Publish(context.Background(), &events.Event{
+			ID:          accepterID,
+			Type:        events.InviteAccepted,
+			UserID:      accepterID,
+			OtherUserID: "4b5c6d7e-8a7e-11eb-8dcd-0242ac130003",
+			OccurredAt:  now.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items, err := db.GetFeedByUserID(context.Background(), "4b5c6d7e-8a7e-11eb-8dcd-0242ac130003", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].ActorID != "3a4b5c6d-8a7e-11eb-8dcd-0242ac130003" || items[1].ActorID != "2f3a4b5c-8a7e-11eb-8dcd-0242ac130003" {
+		t.Errorf("Expected the two newest items to be kept, got %d items", len(items))
+	}
+}
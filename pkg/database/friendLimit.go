@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+)
+
+// FriendLimitPolicy resolves the maximum number of friends of a user, 0 meaning no limit
+type FriendLimitPolicy interface {
+	FriendLimit(ctx context.Context, userID string) (int, error)
+}
+
+// StaticFriendLimit gives the same limit to every user
+type StaticFriendLimit struct {
+	Limit int
+}
+
+func (policy *StaticFriendLimit) FriendLimit(ctx context.Context, userID string) (int, error) {
+	return policy.Limit, nil
+}
+
+// UserServiceFriendLimit looks up the account of the user in microservice-user
+// and gives a higher limit to premium accounts
+type UserServiceFriendLimit struct {
+	Limit        int
+	PremiumLimit int
+}
+
+func (policy *UserServiceFriendLimit) FriendLimit(ctx context.Context, userID string) (int, error) {
+	getUserByIDPath := data.MicroserviceUserPath + "/users/" + userID
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, getUserByIDPath, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status code %d from microservice-user", resp.StatusCode)
+	}
+
+	account := struct {
+		Premium bool `json:"premium"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&account)
+	if err != nil {
+		return 0, err
+	}
+
+	if account.Premium {
+		return policy.PremiumLimit, nil
+	}
+	return policy.Limit, nil
+}
+
+// friendLimitPolicyFromEnv selects the friend limit policy with FRIEND_LIMIT_POLICY, static or user,
+// using the limits from MAX_FRIENDS and MAX_FRIENDS_PREMIUM
+func friendLimitPolicyFromEnv() FriendLimitPolicy {
+	limit := envInt("MAX_FRIENDS", 250)
+
+	switch policy := os.Getenv("FRIEND_LIMIT_POLICY"); policy {
+	case "", "static":
+		return &StaticFriendLimit{Limit: limit}
+	case "user":
+		return &UserServiceFriendLimit{Limit: limit, PremiumLimit: envInt("MAX_FRIENDS_PREMIUM", 500)}
+	default:
+		log.Error(ErrorEnvVar, "Unknown friend limit policy, using static limit", "policy", policy)
+		return &StaticFriendLimit{Limit: limit}
+	}
+}
+
+// checkFriendLimits verifies that the users becoming friends in a relationship are under their friend limit
+// countFriends returns the number of friends of a user, ignoring the relationship being saved
+func checkFriendLimits(ctx context.Context, policy FriendLimitPolicy, relationship *data.Relationship, countFriends func(userID string) (int64, error)) error {
+	for _, user := range []data.User{relationship.User1, relationship.User2} {
+		if user.RelationshipType != data.Friend {
+			continue
+		}
+
+		limit, err := policy.FriendLimit(ctx, user.UserID)
+		if err != nil {
+			return err
+		}
+		if limit == 0 {
+			continue
+		}
+
+		count, err := countFriends(user.UserID)
+		if err != nil {
+			return err
+		}
+		if count >= int64(limit) {
+			return &data.FriendLimitError{UserID: user.UserID, Limit: limit}
+		}
+	}
+	return nil
+}
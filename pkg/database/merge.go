@@ -0,0 +1,24 @@
+package database
+
+import (
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
+)
+
+// mergeEvents returns the events of a relationship moved from the source user to the target user by a merge
+// The source user loses the relationship and the target user gains it, so the events are the ones of a removal and of an addition
+func mergeEvents(previous *data.Relationship, moved *data.Relationship) events.Events {
+	return append(events.FromChange(previous, nil), events.FromChange(nil, moved)...)
+}
+
+// mergedFollowUsers returns the follower and followed user of a follow of the source user once moved to the target user
+func mergedFollowUsers(follow *data.Follow, sourceUserID string, targetUserID string) (string, string) {
+	followerID, followedID := follow.FollowerID, follow.FollowedID
+	if followerID == sourceUserID {
+		followerID = targetUserID
+	}
+	if followedID == sourceUserID {
+		followedID = targetUserID
+	}
+	return followerID, followedID
+}
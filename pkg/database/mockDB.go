@@ -5,16 +5,21 @@ import (
 	"time"
 
 	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
 	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
 )
 
 type MockRelationships struct {
+	inviteTTL   time.Duration
+	inviteQuota inviteQuota
+	friendLimit FriendLimitPolicy
+	publisher   events.Publisher
 }
 
-func NewMockRelationships() RelationshipDB {
+func NewMockRelationships(publisher events.Publisher) RelationshipDB {
 	log.Info("Connecting to mock database")
-	return &MockRelationships{}
+	return &MockRelationships{inviteTTL: inviteTTL(), inviteQuota: inviteQuotaFromEnv(), friendLimit: &StaticFriendLimit{Limit: envInt("MAX_FRIENDS", 250)}, publisher: publisher}
 }
 
 func (mp *MockRelationships) Connect() error {
@@ -32,7 +37,7 @@ func (mp *MockRelationships) CloseDB() {
 func (mp *MockRelationships) GetFriendsListByUserID(ctx context.Context, userID string) (*data.DetailedRelationships, error) {
 	_, span := otel.Tracer("friendslist").Start(ctx, "getFriendsListByUserIdDatabase")
 	defer span.End()
-	friendsList := findFriendsListByUserID(userID)
+	friendsList := withoutFrozen(userID, findFriendsListByUserID(userID), frozenUserList)
 	if len(friendsList) == 0 {
 		return nil, data.ErrorRelationshipNotFound
 	}
@@ -46,7 +51,7 @@ func (mp *MockRelationships) GetFriendsListByUserID(ctx context.Context, userID
 func (mp *MockRelationships) GetInvitesListByUserID(ctx context.Context, userID string) (*data.DetailedRelationships, error) {
 	_, span := otel.Tracer("friendslist").Start(ctx, "getFriendsRequestsByUserIdDatabase")
 	defer span.End()
-	invitesList := findInvitesListByUserID(userID)
+	invitesList := withoutFrozen(userID, findInvitesListByUserID(userID), frozenUserList)
 	if len(invitesList) == 0 {
 		return nil, data.ErrorRelationshipNotFound
 	}
@@ -66,24 +71,112 @@ func (mp *MockRelationships) UpdateRelationship(ctx context.Context, relationshi
 	}
 
 	err := mp.validateRelationship(relationship)
+	if err == nil {
+		err = checkNotFrozen(relationship)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = relationship.ValidateTransition(relationshipList[index])
 	if err != nil {
 		return err
 	}
 
+	err = checkFriendLimits(ctx, mp.friendLimit, relationship, friendsCounter(relationship.ID))
+	if err != nil {
+		return err
+	}
+
+	relationship.ExpiresAt = updatedInviteExpiry(relationship, relationshipList[index], mp.inviteTTL)
+	relationship.KeepPreferences(relationshipList[index])
+	previous := relationshipList[index]
 	relationshipList[index] = relationship
+	if relationship.IsBlocked() {
+		deleteFollowsBetween(relationship.User1.UserID, relationship.User2.UserID)
+	}
+	publishChange(ctx, mp.publisher, previous, relationship)
+	return nil
+}
+
+func (mp *MockRelationships) UpdateRelationshipPreferences(ctx context.Context, id string, userID string, preferences *data.RelationshipPreferences) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "updateRelationshipPreferencesDatabase")
+	defer span.End()
+	index := findIndexByRelationshipID(id)
+	if index == -1 {
+		return data.ErrorRelationshipNotFound
+	}
+	side := relationshipList[index].Side(userID)
+	if side == nil {
+		return data.ErrorRelationshipNotFound
+	}
+	side.RelationshipPreferences = *preferences
 	return nil
 }
 
 func (mp *MockRelationships) AddRelationship(ctx context.Context, relationship *data.Relationship) error {
+	return mp.addRelationship(ctx, relationship, true)
+}
+
+func (mp *MockRelationships) addRelationship(ctx context.Context, relationship *data.Relationship, checkPrivacy bool) error {
 	_, span := otel.Tracer("friendslist").Start(ctx, "addRelationshipDatabase")
 	defer span.End()
+
+	if err := checkNotFrozen(relationship); err != nil {
+		return err
+	}
+
+	// A friend request crossing a request of the other user accepts it
+	if existing := findRelationshipByUserIDs(relationship.User1.UserID, relationship.User2.UserID); existing != nil && relationship.Crosses(existing) && !existing.IsExpired(time.Now().UTC()) {
+		friendship := *existing
+		friendship.Accept()
+		err := checkFriendLimits(ctx, mp.friendLimit, &friendship, friendsCounter(existing.ID))
+		if err != nil {
+			return err
+		}
+		friendship.UpdatedOn = time.Now().UTC().String()
+		publishChange(ctx, mp.publisher, existing, &friendship)
+		*existing = friendship
+		// The friendship is returned to the sender of the request, the preferences of the other user stay private
+		otherUserID := relationship.OtherUser(relationship.Sender()).UserID
+		*relationship = friendship
+		relationship.ClearPreferencesOf(otherUserID)
+		return nil
+	}
+
 	err := mp.validateRelationship(relationship)
+	if err == nil && checkPrivacy {
+		err = checkInvitePolicy(ctx, mp, relationship)
+	}
+	if err == nil {
+		err = checkFriendLimits(ctx, mp.friendLimit, relationship, friendsCounter(relationship.ID))
+	}
+	var reservedKeys []string
+	if err == nil {
+		reservedKeys, err = mp.checkInviteQuota(relationship)
+	}
+	if err == nil && checkPrivacy {
+		err = autoAcceptInvite(ctx, mp, mp.friendLimit, relationship, friendsCounter(relationship.ID))
+	}
 	if err == nil {
 		relationship.ID = uuid.NewString()
 		relationship.ConversationID, err = mp.getConversationID([]string{relationship.User1.UserID, relationship.User2.UserID})
-		relationshipList = append(relationshipList, relationship)
 	}
-	return err
+	if err != nil {
+		// A friend request that isn't stored doesn't count against the quota
+		for _, key := range reservedKeys {
+			inviteCounters[key]--
+		}
+		return err
+	}
+	relationship.ExpiresAt = inviteExpiry(relationship, mp.inviteTTL)
+	relationship.ClearPreferences()
+	relationshipList = append(relationshipList, relationship)
+	if relationship.IsBlocked() {
+		deleteFollowsBetween(relationship.User1.UserID, relationship.User2.UserID)
+	}
+	publishChange(ctx, mp.publisher, nil, relationship)
+	return nil
 }
 
 func (mp *MockRelationships) DeleteRelationship(ctx context.Context, id string) error {
@@ -94,11 +187,172 @@ func (mp *MockRelationships) DeleteRelationship(ctx context.Context, id string)
 		return data.ErrorRelationshipNotFound
 	}
 
+	deleted := relationshipList[index]
 	relationshipList = append(relationshipList[:index], relationshipList[index+1:]...)
+	removeFromFriendGroups(id)
+	publishChange(ctx, mp.publisher, deleted, nil)
 
 	return nil
 }
 
+func (mp *MockRelationships) GetRelationshipsByUserIDAndType(ctx context.Context, userID string, relationshipType data.RelationshipType) (*data.DetailedRelationships, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getRelationshipsByUserIdAndTypeDatabase")
+	defer span.End()
+	relationships := data.Relationships{}
+	for _, relationship := range findRelationshipsByUserID(userID) {
+		if relationship.Side(userID).RelationshipType == relationshipType {
+			relationships = append(relationships, relationship)
+		}
+	}
+	return mp.GetUserDetails(userID, withoutFrozen(userID, relationships, frozenUserList))
+}
+
+func (mp *MockRelationships) GetFriendIDsByUserID(ctx context.Context, userID string) ([]string, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getFriendIdsByUserIdDatabase")
+	defer span.End()
+	var friendIDs []string
+	for _, relationship := range withoutFrozen(userID, findFriendsListByUserID(userID), frozenUserList) {
+		friendIDs = append(friendIDs, relationship.OtherUser(userID).UserID)
+	}
+	return friendIDs, nil
+}
+
+func (mp *MockRelationships) GetBlockerIDsByUserID(ctx context.Context, userID string) ([]string, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getBlockerIdsByUserIdDatabase")
+	defer span.End()
+	var blockerIDs []string
+	for _, relationship := range findRelationshipsByUserID(userID) {
+		otherUser := relationship.OtherUser(userID)
+		if otherUser.RelationshipType == data.Blocked {
+			blockerIDs = append(blockerIDs, otherUser.UserID)
+		}
+	}
+	return blockerIDs, nil
+}
+
+func (mp *MockRelationships) GetRelationshipByUserIDs(ctx context.Context, userID1 string, userID2 string) (*data.Relationship, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getRelationshipByUserIdsDatabase")
+	defer span.End()
+	relationship := findRelationshipByUserIDs(userID1, userID2)
+	if relationship == nil {
+		return nil, data.ErrorRelationshipNotFound
+	}
+	return relationship, nil
+}
+
+func (mp *MockRelationships) DeleteExpiredInvites(ctx context.Context) (int64, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "deleteExpiredInvitesDatabase")
+	defer span.End()
+	var deleted int64
+	now := time.Now().UTC()
+	// Iterate over a copy since expired relationships are removed from the list
+	for _, relationship := range append(data.Relationships{}, relationshipList...) {
+		if relationship.IsExpired(now) {
+			deleteRelationshipByID(relationship.ID)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// RelayOutbox has nothing to relay, the mock database publishes the events with their change
+func (mp *MockRelationships) RelayOutbox(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func (mp *MockRelationships) ExportRelationshipsByUserID(ctx context.Context, userID string, export func(*data.Relationship) error) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "exportRelationshipsByUserIdDatabase")
+	defer span.End()
+	for _, relationship := range findRelationshipsByUserID(userID) {
+		err := export(relationship)
+		if err != nil {
+			return err
+		}
+	}
+	for _, follow := range followList {
+		if follow.FollowerID != userID && follow.FollowedID != userID {
+			continue
+		}
+		err := export(follow.Relationship())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mp *MockRelationships) AddPartyFriendships(ctx context.Context, userIDs []string) (*data.PartyFriendshipResult, error) {
+	return addPartyFriendships(ctx, mp, userIDs)
+}
+func (mp *MockRelationships) MergeUserRelationships(ctx context.Context, sourceUserID string, targetUserID string) (*data.MergeResult, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "mergeUserRelationshipsDatabase")
+	defer span.End()
+	if sourceUserID == targetUserID {
+		return nil, data.ErrorSameUserID
+	}
+	if !mp.validateUserExist(targetUserID) {
+		return nil, data.ErrorUserNotFound
+	}
+
+	result := &data.MergeResult{SourceUserID: sourceUserID, TargetUserID: targetUserID}
+	for _, relationship := range findRelationshipsByUserID(sourceUserID) {
+		otherUserID := relationship.OtherUser(sourceUserID).UserID
+		if otherUserID == targetUserID {
+			deleteRelationshipByID(relationship.ID)
+			publishChange(ctx, mp.publisher, relationship, nil)
+			result.Dropped++
+			continue
+		}
+
+		existing := findRelationshipByUserIDs(targetUserID, otherUserID)
+		if existing != nil {
+			if !relationship.KeepsOver(existing) {
+				deleteRelationshipByID(relationship.ID)
+				publishChange(ctx, mp.publisher, relationship, nil)
+				result.Dropped++
+				continue
+			}
+			deleteRelationshipByID(existing.ID)
+			publishChange(ctx, mp.publisher, existing, nil)
+			result.Replaced++
+		} else {
+			result.Moved++
+		}
+
+		previous := *relationship
+		relationship.Side(sourceUserID).UserID = targetUserID
+		relationship.UpdatedOn = time.Now().UTC().String()
+		publishEvents(ctx, mp.publisher, mergeEvents(&previous, relationship))
+	}
+
+	for _, friendGroup := range friendGroupList {
+		if friendGroup.UserID == sourceUserID {
+			friendGroup.UserID = targetUserID
+			friendGroup.UpdatedOn = time.Now().UTC().String()
+			result.MovedGroups++
+		}
+	}
+
+	follows := data.Follows{}
+	for _, follow := range followList {
+		if follow.FollowerID != sourceUserID && follow.FollowedID != sourceUserID {
+			follows = append(follows, follow)
+			continue
+		}
+		followerID, followedID := mergedFollowUsers(follow, sourceUserID, targetUserID)
+		relationship := findRelationshipByUserIDs(followerID, followedID)
+		if followerID == followedID || findIndexByFollow(followerID, followedID) != -1 || (relationship != nil && relationship.IsBlocked()) {
+			result.DroppedFollows++
+			continue
+		}
+		follow.FollowerID, follow.FollowedID = followerID, followedID
+		follows = append(follows, follow)
+		result.MovedFollows++
+	}
+	followList = follows
+	return result, nil
+}
+
 // Returns an array of friends in the database
 // Returns -1 when no relationship is found
 func findFriendsListByUserID(id string) data.Relationships {
@@ -117,7 +371,11 @@ func findFriendsListByUserID(id string) data.Relationships {
 // Returns -1 when no relationship is found
 func findInvitesListByUserID(id string) data.Relationships {
 	var invitesList data.Relationships
+	now := time.Now().UTC()
 	for _, relationship := range relationshipList {
+		if relationship.IsExpired(now) {
+			continue
+		}
 		if relationship.User1.UserID == id && relationship.User1.RelationshipType == data.PendingIncoming {
 			invitesList = append(invitesList, relationship)
 		} else if relationship.User2.UserID == id && relationship.User2.RelationshipType == data.PendingIncoming {
@@ -138,6 +396,37 @@ func findIndexByRelationshipID(id string) int {
 	return -1
 }
 
+// Returns every relationship involving the user
+func findRelationshipsByUserID(id string) data.Relationships {
+	var relationships data.Relationships
+	for _, relationship := range relationshipList {
+		if relationship.User1.UserID == id || relationship.User2.UserID == id {
+			relationships = append(relationships, relationship)
+		}
+	}
+	return relationships
+}
+
+// Returns the relationship between two users
+// Returns nil when no relationship is found
+func findRelationshipByUserIDs(userID1 string, userID2 string) *data.Relationship {
+	for _, relationship := range relationshipList {
+		if relationship.Side(userID1) != nil && relationship.Side(userID2) != nil {
+			return relationship
+		}
+	}
+	return nil
+}
+
+// Removes a relationship from the database if it exists
+func deleteRelationshipByID(id string) {
+	index := findIndexByRelationshipID(id)
+	if index != -1 {
+		relationshipList = append(relationshipList[:index], relationshipList[index+1:]...)
+		removeFromFriendGroups(id)
+	}
+}
+
 func (mp *MockRelationships) validateRelationship(relationship *data.Relationship) error {
 	if !mp.validateUserExist(relationship.User1.UserID) || !mp.validateUserExist(relationship.User2.UserID) {
 		return data.ErrorUserNotFound
@@ -156,8 +445,58 @@ func (mp *MockRelationships) validateRelationship(relationship *data.Relationshi
 	return nil
 }
 
+// Returns a function counting the friends of a user, without the relationship with the excluded ID
+func friendsCounter(excludedID string) func(userID string) (int64, error) {
+	return func(userID string) (int64, error) {
+		var count int64
+		for _, relationship := range findFriendsListByUserID(userID) {
+			if relationship.ID != excludedID {
+				count++
+			}
+		}
+		return count, nil
+	}
+}
+
+func (mp *MockRelationships) checkInviteQuota(relationship *data.Relationship) ([]string, error) {
+	senderID := relationship.Sender()
+	if senderID == "" {
+		return nil, nil
+	}
+	now := time.Now().UTC()
+
+	if mp.inviteQuota.maxPending > 0 {
+		pending := 0
+		for _, existing := range findRelationshipsByUserID(senderID) {
+			if existing.Sender() == senderID && !existing.IsExpired(now) {
+				pending++
+			}
+		}
+		if pending >= mp.inviteQuota.maxPending {
+			return nil, &data.InviteQuotaError{Limit: "pending", RetryAfter: time.Hour}
+		}
+	}
+
+	for _, window := range mp.inviteQuota.windows() {
+		if inviteCounters[window.key(senderID, now)] >= window.limit {
+			return nil, &data.InviteQuotaError{Limit: window.name, RetryAfter: window.end(now).Sub(now)}
+		}
+	}
+	var reservedKeys []string
+	for _, window := range mp.inviteQuota.windows() {
+		key := window.key(senderID, now)
+		inviteCounters[key]++
+		reservedKeys = append(reservedKeys, key)
+	}
+	return reservedKeys, nil
+}
+
 func (mp *MockRelationships) validateUserExist(userID string) bool {
-	return true
+	return !unknownUserList[userID]
+}
+
+func (mp *MockRelationships) getGuildID(ctx context.Context, userID string) (string, error) {
+	return guildList[userID], nil
 }
 
 // Returns an bool when a relationship with the two users is found
@@ -200,6 +539,8 @@ func (mp *MockRelationships) GetUserDetails(userID string, relations data.Relati
 			ConversationID: relation.ConversationID,
 			CreatedOn: relation.CreatedOn,
 			UpdatedOn: relation.UpdatedOn,
+			ExpiresAt: relation.ExpiresAt,
+			RelationshipPreferences: relation.PreferencesOf(userID),
 		}
 		detailedRelationsList = append(detailedRelationsList, &detailedRelationship)
 	}
@@ -210,10 +551,25 @@ func (mp *MockRelationships) GetUserByID(userID string) (*data.DetailedUser, err
 	return &data.DetailedUser{ID: userID, Username: "Test", Status: "Online", RelationshipType: data.Friend}, nil
 }
 
+func (mp *MockRelationships) FindUsers(ctx context.Context, lookup *data.UserLookup) ([]*data.DetailedUser, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "findUsersDatabase")
+	defer span.End()
+	users := []*data.DetailedUser{}
+	for _, user := range userList {
+		if (lookup.Username == "" || user.Username == lookup.Username) && (lookup.Email == "" || user.email == lookup.Email) {
+			users = append(users, &data.DetailedUser{ID: user.ID, Username: user.Username, Status: user.Status})
+		}
+	}
+	return users, nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 /////////////////////////// Mocked database ///////////////////////////////////
 //////////////////////////////////////////////////////////////////////////////
 
+// Friend requests sent by each user per quota window
+var inviteCounters = map[string]int{}
+
 var relationshipList = []*data.Relationship{
 	{
 		ID:             "a2181017-5c53-422b-b6bc-036b27c04fc8",
@@ -247,4 +603,91 @@ var relationshipList = []*data.Relationship{
 		CreatedOn:      time.Now().UTC().String(),
 		UpdatedOn:      time.Now().UTC().String(),
 	},
+	// Friends list of 3c1d2e4f seen by other users
+	{
+		ID:             "1f2a3b4c-8a7b-11eb-8dcd-0242ac130003",
+		User1:          data.User{UserID: "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003", RelationshipType: data.Friend},
+		User2:          data.User{UserID: "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003", RelationshipType: data.Friend},
+		ConversationID: "1f2a3b4c-8a7b-11eb-8dcd-0242ac130003",
+		CreatedOn:      time.Now().UTC().String(),
+		UpdatedOn:      time.Now().UTC().String(),
+	},
+	{
+		ID:             "2a3b4c5d-8a7b-11eb-8dcd-0242ac130003",
+		User1:          data.User{UserID: "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003", RelationshipType: data.Friend},
+		User2:          data.User{UserID: "5e3f4a6b-8a7a-11eb-8dcd-0242ac130003", RelationshipType: data.Friend},
+		ConversationID: "2a3b4c5d-8a7b-11eb-8dcd-0242ac130003",
+		CreatedOn:      time.Now().UTC().String(),
+		UpdatedOn:      time.Now().UTC().String(),
+	},
+	{
+		ID:             "3b4c5d6e-8a7b-11eb-8dcd-0242ac130003",
+		User1:          data.User{UserID: "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003", RelationshipType: data.Friend},
+		User2:          data.User{UserID: "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003", RelationshipType: data.Friend},
+		ConversationID: "3b4c5d6e-8a7b-11eb-8dcd-0242ac130003",
+		CreatedOn:      time.Now().UTC().String(),
+		UpdatedOn:      time.Now().UTC().String(),
+	},
+	{
+		ID:             "4c5d6e7f-8a7b-11eb-8dcd-0242ac130003",
+		User1:          data.User{UserID: "7a5b6c8d-8a7a-11eb-8dcd-0242ac130003", RelationshipType: data.Friend},
+		User2:          data.User{UserID: "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003", RelationshipType: data.Friend},
+		ConversationID: "4c5d6e7f-8a7b-11eb-8dcd-0242ac130003",
+		CreatedOn:      time.Now().UTC().String(),
+		UpdatedOn:      time.Now().UTC().String(),
+	},
+	{
+		ID:             "5d6e7f8a-8a7b-11eb-8dcd-0242ac130003",
+		User1:          data.User{UserID: "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003", RelationshipType: data.Friend},
+		User2:          data.User{UserID: "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003", RelationshipType: data.Friend},
+		ConversationID: "5d6e7f8a-8a7b-11eb-8dcd-0242ac130003",
+		CreatedOn:      time.Now().UTC().String(),
+		UpdatedOn:      time.Now().UTC().String(),
+	},
+	{
+		ID:             "6e7f8a9b-8a7b-11eb-8dcd-0242ac130003",
+		User1:          data.User{UserID: "7a5b6c8d-8a7a-11eb-8dcd-0242ac130003", RelationshipType: data.Blocked},
+		User2:          data.User{UserID: "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003", RelationshipType: data.None},
+		ConversationID: "6e7f8a9b-8a7b-11eb-8dcd-0242ac130003",
+		CreatedOn:      time.Now().UTC().String(),
+		UpdatedOn:      time.Now().UTC().String(),
+	},
+	{
+		ID:             "7f8a9b0c-8a7b-11eb-8dcd-0242ac130003",
+		User1:          data.User{UserID: "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003", RelationshipType: data.Blocked},
+		User2:          data.User{UserID: "8b6c7d9e-8a7a-11eb-8dcd-0242ac130003", RelationshipType: data.None},
+		ConversationID: "7f8a9b0c-8a7b-11eb-8dcd-0242ac130003",
+		CreatedOn:      time.Now().UTC().String(),
+		UpdatedOn:      time.Now().UTC().String(),
+	},
+}
+
+// Users known by the mocked microservice-user lookup
+var userList = []struct {
+	data.DetailedUser
+	email string
+}{
+	{
+		DetailedUser: data.DetailedUser{ID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", Username: "sickboy", Status: "Online"},
+		email:        "sickboy@ubivius.tk",
+	},
+	{
+		DetailedUser: data.DetailedUser{ID: "9b7d1e3f-8a79-11eb-8dcd-0242ac130003", Username: "Test", Status: "Offline"},
+		email:        "test1@ubivius.tk",
+	},
+	{
+		DetailedUser: data.DetailedUser{ID: "ac8e2f40-8a79-11eb-8dcd-0242ac130003", Username: "Test", Status: "Online"},
+		email:        "test2@ubivius.tk",
+	},
+}
+
+// Users the mocked microservice-user lookup doesn't find, every other user exists
+var unknownUserList = map[string]bool{
+	"c0d1e2f3-8a84-11eb-8dcd-0242ac130003": true,
+}
+
+// guildList maps the mock users to their guild
+var guildList = map[string]string{
+	"6f4a5b7c-8a7a-11eb-8dcd-0242ac130003": "b1c2d3e4-8a7d-11eb-8dcd-0242ac130003",
+	"5e3f4a6b-8a7a-11eb-8dcd-0242ac130003": "b1c2d3e4-8a7d-11eb-8dcd-0242ac130003",
 }
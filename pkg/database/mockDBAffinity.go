@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+func (mp *MockRelationships) AddAffinitySignal(ctx context.Context, signal *data.AffinitySignal) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "addAffinitySignalDatabase")
+	defer span.End()
+	pairs, err := affinityPairs(ctx, mp, signal)
+	if err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		counted := newCountedSignal(pair[0], pair[1], signal)
+		if previous, ok := countedSignalList[counted.ID]; ok && time.Since(previous.CountedOn) < affinitySignalRetention() {
+			log.Info("Ignoring affinity signal already counted", "id", counted.ID)
+			continue
+		}
+		countedSignalList[counted.ID] = counted
+
+		id := affinityID(pair[0], pair[1])
+		affinity, ok := affinityList[id]
+		if !ok {
+			affinity = newAffinity(pair[0], pair[1])
+			affinityList[id] = affinity
+		}
+		addAffinitySignal(affinity, signal, affinityHalfLife())
+	}
+	return nil
+}
+
+func (mp *MockRelationships) GetAffinities(ctx context.Context, userID string, friendIDs []string) (map[string]float64, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getAffinitiesDatabase")
+	defer span.End()
+	now := time.Now().UTC()
+	scores := map[string]float64{}
+	for _, friendID := range friendIDs {
+		affinity, ok := affinityList[affinityID(userID, friendID)]
+		if ok {
+			scores[friendID] = currentAffinity(affinity, now, affinityHalfLife())
+		}
+	}
+	return scores, nil
+}
+
+func (mp *MockRelationships) DeleteAffinity(ctx context.Context, userID1 string, userID2 string) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "deleteAffinityDatabase")
+	defer span.End()
+	delete(affinityList, affinityID(userID1, userID2))
+	return nil
+}
+
+// Affinities of the friends, by pair of users
+var affinityList = map[string]*data.Affinity{}
+
+// Signals counted in the affinities, by pair of users and signal
+var countedSignalList = map[string]*data.CountedSignal{}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+func (mp *MockRelationships) AddFeedItems(ctx context.Context, items data.FeedItems, keep int) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "addFeedItemsDatabase")
+	defer span.End()
+	for _, item := range items {
+		if findFeedItemIndexByID(item.ID) == -1 {
+			feedItemList = append(feedItemList, item)
+		}
+	}
+
+	// Newest first, the items over the limit of their feed are dropped
+	sort.SliceStable(feedItemList, func(i, j int) bool {
+		return feedItemList[i].CreatedOn.After(feedItemList[j].CreatedOn)
+	})
+	counts := map[string]int{}
+	kept := data.FeedItems{}
+	for _, item := range feedItemList {
+		counts[item.UserID]++
+		if counts[item.UserID] <= keep {
+			kept = append(kept, item)
+		}
+	}
+	feedItemList = kept
+	return nil
+}
+
+func (mp *MockRelationships) GetFeedByUserID(ctx context.Context, userID string, offset int, limit int) (data.FeedItems, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getFeedByUserIdDatabase")
+	defer span.End()
+	items := data.FeedItems{}
+	for _, item := range feedItemList {
+		if item.UserID == userID && item.ExpiresAt.After(time.Now().UTC()) {
+			copied := *item
+			items = append(items, &copied)
+		}
+	}
+	if offset >= len(items) {
+		return data.FeedItems{}, nil
+	}
+	items = items[offset:]
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+func (mp *MockRelationships) DeleteFeedItemsBetween(ctx context.Context, userID1 string, userID2 string) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "deleteFeedItemsBetweenDatabase")
+	defer span.End()
+	kept := data.FeedItems{}
+	for _, item := range feedItemList {
+		between := (item.ActorID == userID1 && item.SubjectID == userID2) || (item.ActorID == userID2 && item.SubjectID == userID1)
+		if !between {
+			kept = append(kept, item)
+		}
+	}
+	feedItemList = kept
+	return nil
+}
+
+// Returns the index of a feed item in the list, -1 if no item is found
+func findFeedItemIndexByID(id string) int {
+	for index, item := range feedItemList {
+		if item.ID == id {
+			return index
+		}
+	}
+	return -1
+}
+
+// Activities of the feeds, newest first
+var feedItemList = data.FeedItems{}
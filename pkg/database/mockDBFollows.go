@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+)
+
+func (mp *MockRelationships) AddFollow(ctx context.Context, followerID string, followedID string) (*data.Follow, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "addFollowDatabase")
+	defer span.End()
+	if followerID == followedID {
+		return nil, data.ErrorSameUserID
+	}
+	if !mp.validateUserExist(followedID) {
+		return nil, data.ErrorUserNotFound
+	}
+	if relationship := findRelationshipByUserIDs(followerID, followedID); relationship != nil && relationship.IsBlocked() {
+		return nil, data.ErrorUserBlocked
+	}
+	if findIndexByFollow(followerID, followedID) != -1 {
+		return nil, data.ErrorFollowExist
+	}
+
+	now := time.Now().UTC()
+	follow := &data.Follow{
+		ID:         uuid.NewString(),
+		FollowerID: followerID,
+		FollowedID: followedID,
+		CreatedOn:  now.String(),
+		FollowedAt: now,
+	}
+	followList = append(followList, follow)
+	return follow, nil
+}
+
+func (mp *MockRelationships) DeleteFollow(ctx context.Context, followerID string, followedID string) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "deleteFollowDatabase")
+	defer span.End()
+	index := findIndexByFollow(followerID, followedID)
+	if index == -1 {
+		return data.ErrorFollowNotFound
+	}
+	followList = append(followList[:index], followList[index+1:]...)
+	return nil
+}
+
+func (mp *MockRelationships) GetFollowersByUserID(ctx context.Context, userID string, offset int, limit int) (*data.DetailedRelationships, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getFollowersByUserIdDatabase")
+	defer span.End()
+	return mp.getFollows(userID, func(follow *data.Follow) bool { return follow.FollowedID == userID }, offset, limit)
+}
+
+func (mp *MockRelationships) GetFollowingByUserID(ctx context.Context, userID string, offset int, limit int) (*data.DetailedRelationships, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getFollowingByUserIdDatabase")
+	defer span.End()
+	return mp.getFollows(userID, func(follow *data.Follow) bool { return follow.FollowerID == userID }, offset, limit)
+}
+
+func (mp *MockRelationships) GetFollowCounts(ctx context.Context, userID string) (*data.FollowCounts, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getFollowCountsDatabase")
+	defer span.End()
+	followCounts := &data.FollowCounts{UserID: userID}
+	for _, follow := range followList {
+		if follow.FollowedID == userID {
+			followCounts.Followers++
+		}
+		if follow.FollowerID == userID {
+			followCounts.Following++
+		}
+	}
+	return followCounts, nil
+}
+
+// Returns a page of the follows matching the filter, newest first
+func (mp *MockRelationships) getFollows(userID string, matches func(*data.Follow) bool, offset int, limit int) (*data.DetailedRelationships, error) {
+	follows := data.Follows{}
+	for _, follow := range followList {
+		if matches(follow) {
+			follows = append(follows, follow)
+		}
+	}
+	sort.SliceStable(follows, func(i, j int) bool {
+		return follows[i].FollowedAt.After(follows[j].FollowedAt)
+	})
+	relationships := data.Relationships{}
+	for _, follow := range follows {
+		relationships = append(relationships, follow.Relationship())
+	}
+	if offset > len(relationships) {
+		offset = len(relationships)
+	}
+	relationships = relationships[offset:]
+	if limit < len(relationships) {
+		relationships = relationships[:limit]
+	}
+	return mp.GetUserDetails(userID, relationships)
+}
+
+// Removes the follows between two users, in both directions
+func deleteFollowsBetween(userID1 string, userID2 string) {
+	for _, users := range [][2]string{{userID1, userID2}, {userID2, userID1}} {
+		index := findIndexByFollow(users[0], users[1])
+		if index != -1 {
+			followList = append(followList[:index], followList[index+1:]...)
+		}
+	}
+}
+
+// Returns the index of a follow in the database
+// Returns -1 when no follow is found
+func findIndexByFollow(followerID string, followedID string) int {
+	for index, follow := range followList {
+		if follow.FollowerID == followerID && follow.FollowedID == followedID {
+			return index
+		}
+	}
+	return -1
+}
+
+var followList = data.Follows{
+	{
+		ID:         "8c9d0e1f-8a7d-11eb-8dcd-0242ac130003",
+		FollowerID: "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003",
+		FollowedID: "e2382ea2-b5fa-4506-aa9d-d338aa52af45",
+		CreatedOn:  time.Now().UTC().String(),
+		FollowedAt: time.Now().UTC(),
+	},
+}
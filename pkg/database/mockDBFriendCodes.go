@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+func (mp *MockRelationships) AddFriendCode(ctx context.Context, friendCode *data.FriendCode) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "addFriendCodeDatabase")
+	defer span.End()
+	code, err := data.GenerateFriendCode()
+	if err != nil {
+		return err
+	}
+	friendCode.Code = code
+	friendCode.CreatedOn = time.Now().UTC().String()
+	friendCodeList = append(friendCodeList, friendCode)
+	return nil
+}
+
+func (mp *MockRelationships) GetFriendCodesByUserID(ctx context.Context, userID string) (data.FriendCodes, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getFriendCodesByUserIdDatabase")
+	defer span.End()
+	friendCodes := data.FriendCodes{}
+	for _, friendCode := range friendCodeList {
+		if friendCode.UserID == userID && !friendCode.IsExpired(time.Now().UTC()) {
+			friendCodes = append(friendCodes, friendCode)
+		}
+	}
+	return friendCodes, nil
+}
+
+func (mp *MockRelationships) GetFriendCode(ctx context.Context, code string) (*data.FriendCode, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getFriendCodeDatabase")
+	defer span.End()
+	index := findIndexByFriendCode(code)
+	if index == -1 || friendCodeList[index].IsExpired(time.Now().UTC()) {
+		return nil, data.ErrorFriendCodeNotFound
+	}
+	return friendCodeList[index], nil
+}
+
+func (mp *MockRelationships) DeleteFriendCode(ctx context.Context, code string) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "deleteFriendCodeDatabase")
+	defer span.End()
+	index := findIndexByFriendCode(code)
+	if index == -1 {
+		return data.ErrorFriendCodeNotFound
+	}
+	friendCodeList = append(friendCodeList[:index], friendCodeList[index+1:]...)
+	return nil
+}
+
+func (mp *MockRelationships) RedeemFriendCode(ctx context.Context, code string, userID string) (*data.Relationship, error) {
+	friendCode, err := mp.GetFriendCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	relationship := friendCode.Relationship(userID)
+	err = mp.addRelationship(ctx, relationship, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if friendCode.SingleUse {
+		err = mp.DeleteFriendCode(ctx, code)
+	}
+	return relationship, err
+}
+
+// Returns the index of a friend code in the database
+// Returns -1 when no friend code is found
+func findIndexByFriendCode(code string) int {
+	for index, friendCode := range friendCodeList {
+		if friendCode.Code == code {
+			return index
+		}
+	}
+	return -1
+}
+
+var friendCodeList = data.FriendCodes{
+	{
+		Code:      "7KQ4-MX2B",
+		UserID:    "e2382ea2-b5fa-4506-aa9d-d338aa52af44",
+		SingleUse: true,
+		CreatedOn: time.Now().UTC().String(),
+	},
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+)
+
+func (mp *MockRelationships) AddFriendGroup(ctx context.Context, friendGroup *data.FriendGroup) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "addFriendGroupDatabase")
+	defer span.End()
+	err := validateGroupMembers(friendGroup)
+	if err != nil {
+		return err
+	}
+	friendGroup.ID = uuid.NewString()
+	friendGroup.CreatedOn = time.Now().UTC().String()
+	friendGroup.UpdatedOn = time.Now().UTC().String()
+	friendGroupList = append(friendGroupList, friendGroup)
+	return nil
+}
+
+func (mp *MockRelationships) GetFriendGroupsByUserID(ctx context.Context, userID string) (data.FriendGroups, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getFriendGroupsByUserIdDatabase")
+	defer span.End()
+	friendGroups := data.FriendGroups{}
+	for _, friendGroup := range friendGroupList {
+		if friendGroup.UserID == userID {
+			friendGroups = append(friendGroups, friendGroup)
+		}
+	}
+	return friendGroups, nil
+}
+
+func (mp *MockRelationships) GetFriendGroup(ctx context.Context, id string) (*data.FriendGroup, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getFriendGroupDatabase")
+	defer span.End()
+	index := findIndexByFriendGroupID(id)
+	if index == -1 {
+		return nil, data.ErrorFriendGroupNotFound
+	}
+	return friendGroupList[index], nil
+}
+
+func (mp *MockRelationships) UpdateFriendGroup(ctx context.Context, friendGroup *data.FriendGroup) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "updateFriendGroupDatabase")
+	defer span.End()
+	index := findIndexByFriendGroupID(friendGroup.ID)
+	if index == -1 || friendGroupList[index].UserID != friendGroup.UserID {
+		return data.ErrorFriendGroupNotFound
+	}
+	err := validateGroupMembers(friendGroup)
+	if err != nil {
+		return err
+	}
+	friendGroup.CreatedOn = friendGroupList[index].CreatedOn
+	friendGroup.UpdatedOn = time.Now().UTC().String()
+	friendGroupList[index] = friendGroup
+	return nil
+}
+
+func (mp *MockRelationships) DeleteFriendGroup(ctx context.Context, id string) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "deleteFriendGroupDatabase")
+	defer span.End()
+	index := findIndexByFriendGroupID(id)
+	if index == -1 {
+		return data.ErrorFriendGroupNotFound
+	}
+	friendGroupList = append(friendGroupList[:index], friendGroupList[index+1:]...)
+	return nil
+}
+
+// Makes sure every member of the friend group is a friendship of its owner
+func validateGroupMembers(friendGroup *data.FriendGroup) error {
+	for _, relationshipID := range friendGroup.RelationshipIDs {
+		index := findIndexByRelationshipID(relationshipID)
+		if index == -1 {
+			return data.ErrorInvalidGroupMember
+		}
+		side := relationshipList[index].Side(friendGroup.UserID)
+		if side == nil || side.RelationshipType != data.Friend {
+			return data.ErrorInvalidGroupMember
+		}
+	}
+	return nil
+}
+
+// Removes a deleted relationship from the friend groups referencing it
+func removeFromFriendGroups(relationshipID string) {
+	for _, friendGroup := range friendGroupList {
+		relationshipIDs := []string{}
+		for _, id := range friendGroup.RelationshipIDs {
+			if id != relationshipID {
+				relationshipIDs = append(relationshipIDs, id)
+			}
+		}
+		friendGroup.RelationshipIDs = relationshipIDs
+	}
+}
+
+// Returns the index of a friend group in the database
+// Returns -1 when no friend group is found
+func findIndexByFriendGroupID(id string) int {
+	for index, friendGroup := range friendGroupList {
+		if friendGroup.ID == id {
+			return index
+		}
+	}
+	return -1
+}
+
+var friendGroupList = data.FriendGroups{
+	{
+		ID:              "0c1d2e3f-8a7c-11eb-8dcd-0242ac130003",
+		UserID:          "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003",
+		Name:            "Raid team",
+		RelationshipIDs: []string{"1f2a3b4c-8a7b-11eb-8dcd-0242ac130003", "3b4c5d6e-8a7b-11eb-8dcd-0242ac130003"},
+		CreatedOn:       time.Now().UTC().String(),
+		UpdatedOn:       time.Now().UTC().String(),
+	},
+}
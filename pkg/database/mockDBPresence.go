@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+func (mp *MockRelationships) SetPresence(ctx context.Context, presence *data.Presence) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "setPresenceDatabase")
+	defer span.End()
+	current, ok := presenceList[presence.UserID]
+	if ok && current.UpdatedOn.After(presence.UpdatedOn) {
+		return nil
+	}
+	presence.ExpiresAt = presence.UpdatedOn.Add(presenceTTL())
+	presenceList[presence.UserID] = presence
+	return nil
+}
+
+func (mp *MockRelationships) GetPresenceStatuses(ctx context.Context, userIDs []string) (map[string]string, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getPresenceStatusesDatabase")
+	defer span.End()
+	statuses := make(map[string]string, len(userIDs))
+	for _, userID := range userIDs {
+		presence, ok := presenceList[userID]
+		if ok && presence.ExpiresAt.After(time.Now().UTC()) {
+			statuses[userID] = presence.Status
+		}
+	}
+	return statuses, nil
+}
+
+// Last statuses received for the users
+var presenceList = map[string]*data.Presence{}
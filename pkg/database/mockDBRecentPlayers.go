@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+func (mp *MockRelationships) AddMatchRoster(ctx context.Context, matchRoster *data.MatchRoster) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "addMatchRosterDatabase")
+	defer span.End()
+	expiresAt := matchRoster.PlayedAt.Add(recentPlayersTTL())
+	for _, userID := range matchRoster.UserIDs {
+		for _, playerID := range matchRoster.UserIDs {
+			if userID == playerID {
+				continue
+			}
+			id := recentPlayerID(userID, playerID)
+			recentPlayer, ok := recentPlayerList[id]
+			if !ok {
+				recentPlayer = &data.RecentPlayer{ID: id, UserID: userID, PlayerID: playerID}
+				recentPlayerList[id] = recentPlayer
+			}
+			if containsString(recentPlayer.MatchIDs, matchRoster.MatchID) {
+				continue
+			}
+			recentPlayer.Matches++
+			recentPlayer.MatchIDs = append(recentPlayer.MatchIDs, matchRoster.MatchID)
+			if len(recentPlayer.MatchIDs) > recentMatchIDs {
+				recentPlayer.MatchIDs = recentPlayer.MatchIDs[len(recentPlayer.MatchIDs)-recentMatchIDs:]
+			}
+			if matchRoster.PlayedAt.After(recentPlayer.LastPlayedOn) {
+				recentPlayer.LastPlayedOn = matchRoster.PlayedAt
+			}
+			if expiresAt.After(recentPlayer.ExpiresAt) {
+				recentPlayer.ExpiresAt = expiresAt
+			}
+		}
+	}
+	return nil
+}
+
+func (mp *MockRelationships) GetRecentPlayersByUserID(ctx context.Context, userID string, offset int, limit int) (data.RecentPlayers, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getRecentPlayersByUserIdDatabase")
+	defer span.End()
+	recentPlayers := data.RecentPlayers{}
+	for _, recentPlayer := range recentPlayerList {
+		if recentPlayer.UserID == userID && recentPlayer.ExpiresAt.After(time.Now().UTC()) {
+			copied := *recentPlayer
+			recentPlayers = append(recentPlayers, &copied)
+		}
+	}
+	sort.Slice(recentPlayers, func(i, j int) bool {
+		if !recentPlayers[i].LastPlayedOn.Equal(recentPlayers[j].LastPlayedOn) {
+			return recentPlayers[i].LastPlayedOn.After(recentPlayers[j].LastPlayedOn)
+		}
+		return recentPlayers[i].ID < recentPlayers[j].ID
+	})
+	if offset >= len(recentPlayers) {
+		return data.RecentPlayers{}, nil
+	}
+	recentPlayers = recentPlayers[offset:]
+	if len(recentPlayers) > limit {
+		recentPlayers = recentPlayers[:limit]
+	}
+	return withoutFrozenPlayers(recentPlayers, frozenUserList), nil
+}
+
+func (mp *MockRelationships) GetFriendSuggestions(ctx context.Context, userID string, limit int) (data.FriendSuggestions, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getFriendSuggestionsDatabase")
+	defer span.End()
+	return suggestFriends(ctx, mp, userID, limit)
+}
+
+// Co-players of the users, by user and co-player
+var recentPlayerList = map[string]*data.RecentPlayer{}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+)
+
+func (mp *MockRelationships) AddSessionInvite(ctx context.Context, sessionInvite *data.SessionInvite) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "addSessionInviteDatabase")
+	defer span.End()
+	if sessionInvite.HostID == sessionInvite.InviteeID {
+		return data.ErrorSameUserID
+	}
+	err := checkMockSessionFriends(sessionInvite)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, existing := range sessionInviteList {
+		if existing.SessionID == sessionInvite.SessionID && existing.InviteeID == sessionInvite.InviteeID && existing.Status == data.SessionInvitePending && !existing.IsExpired(now) {
+			return data.ErrorSessionInviteExist
+		}
+	}
+
+	sessionInvite.ID = uuid.NewString()
+	sessionInvite.Status = data.SessionInvitePending
+	sessionInvite.CreatedOn = now
+	sessionInvite.UpdatedOn = now
+	sessionInvite.ExpiresAt = now.Add(sessionInviteTTL())
+	sessionInviteList = append(sessionInviteList, sessionInvite)
+	publishEvents(ctx, mp.publisher, events.Events{events.FromSessionInvite(events.SessionInviteSent, sessionInvite, sessionInvite.HostID)})
+	return nil
+}
+
+func (mp *MockRelationships) GetSessionInvite(ctx context.Context, id string) (*data.SessionInvite, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getSessionInviteDatabase")
+	defer span.End()
+	index := findSessionInviteIndexByID(id)
+	if index == -1 {
+		return nil, data.ErrorSessionInviteNotFound
+	}
+	sessionInvite := *sessionInviteList[index]
+	return &sessionInvite, nil
+}
+
+func (mp *MockRelationships) GetSessionInvitesByUserID(ctx context.Context, userID string) (data.SessionInvites, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getSessionInvitesByUserIdDatabase")
+	defer span.End()
+	now := time.Now().UTC()
+	sessionInvites := data.SessionInvites{}
+	for i := len(sessionInviteList) - 1; i >= 0; i-- {
+		sessionInvite := sessionInviteList[i]
+		if sessionInvite.InviteeID == userID && sessionInvite.Status == data.SessionInvitePending && !sessionInvite.IsExpired(now) {
+			sessionInvites = append(sessionInvites, sessionInvite)
+		}
+	}
+	return sessionInvites, nil
+}
+
+func (mp *MockRelationships) AnswerSessionInvite(ctx context.Context, id string, userID string, accept bool) (*data.SessionInvite, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "answerSessionInviteDatabase")
+	defer span.End()
+	index := findSessionInviteIndexByID(id)
+	if index == -1 {
+		return nil, data.ErrorSessionInviteNotFound
+	}
+	sessionInvite := *sessionInviteList[index]
+	eventType, err := answerSessionInvite(&sessionInvite, userID, accept, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if accept {
+		err = checkMockSessionFriends(&sessionInvite)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sessionInviteList[index] = &sessionInvite
+	publishEvents(ctx, mp.publisher, events.Events{events.FromSessionInvite(eventType, &sessionInvite, userID)})
+	return &sessionInvite, nil
+}
+
+// Refuses session invites between users who aren't friends
+func checkMockSessionFriends(sessionInvite *data.SessionInvite) error {
+	relationship := findRelationshipByUserIDs(sessionInvite.HostID, sessionInvite.InviteeID)
+	err := checkFriends(relationship)
+	if err != nil {
+		return err
+	}
+	return checkNotFrozen(relationship)
+}
+
+// Returns the index of a session invite in the list, -1 if no session invite is found
+func findSessionInviteIndexByID(id string) int {
+	for index, sessionInvite := range sessionInviteList {
+		if sessionInvite.ID == id {
+			return index
+		}
+	}
+	return -1
+}
+
+// Invites of friends to game sessions
+var sessionInviteList = data.SessionInvites{}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+func (mp *MockRelationships) GetUserSettings(ctx context.Context, userID string) (*data.UserSettings, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getUserSettingsDatabase")
+	defer span.End()
+	settings, ok := userSettingsList[userID]
+	if !ok {
+		return data.DefaultUserSettings(userID), nil
+	}
+	return settings, nil
+}
+
+func (mp *MockRelationships) UpdateUserSettings(ctx context.Context, settings *data.UserSettings) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "updateUserSettingsDatabase")
+	defer span.End()
+	settings.UpdatedOn = time.Now().UTC().String()
+	userSettingsList[settings.UserID] = settings
+	return nil
+}
+
+var userSettingsList = map[string]*data.UserSettings{
+	"d4e5f6a7-8a79-11eb-8dcd-0242ac130003": {
+		UserID:             "d4e5f6a7-8a79-11eb-8dcd-0242ac130003",
+		InvitePolicy:       data.InvitesFromNobody,
+		FriendsListVisible: false,
+		UpdatedOn:          time.Now().UTC().String(),
+	},
+}
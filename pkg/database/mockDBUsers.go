@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+func (mp *MockRelationships) DeleteUserRelationships(ctx context.Context, userID string) (int64, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "deleteUserRelationshipsDatabase")
+	defer span.End()
+	var deleted int64
+	for _, relationship := range findRelationshipsByUserID(userID) {
+		err := mp.DeleteRelationship(ctx, relationship.ID)
+		if err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	follows := data.Follows{}
+	for _, follow := range followList {
+		if follow.FollowerID != userID && follow.FollowedID != userID {
+			follows = append(follows, follow)
+		}
+	}
+	followList = follows
+
+	friendCodes := data.FriendCodes{}
+	for _, friendCode := range friendCodeList {
+		if friendCode.UserID != userID {
+			friendCodes = append(friendCodes, friendCode)
+		}
+	}
+	friendCodeList = friendCodes
+
+	friendGroups := data.FriendGroups{}
+	for _, friendGroup := range friendGroupList {
+		if friendGroup.UserID != userID {
+			friendGroups = append(friendGroups, friendGroup)
+		}
+	}
+	friendGroupList = friendGroups
+
+	feedItems := data.FeedItems{}
+	for _, item := range feedItemList {
+		if item.UserID != userID && item.ActorID != userID && item.SubjectID != userID {
+			feedItems = append(feedItems, item)
+		}
+	}
+	feedItemList = feedItems
+
+	sessionInvites := data.SessionInvites{}
+	for _, sessionInvite := range sessionInviteList {
+		if sessionInvite.HostID != userID && sessionInvite.InviteeID != userID {
+			sessionInvites = append(sessionInvites, sessionInvite)
+		}
+	}
+	sessionInviteList = sessionInvites
+
+	for id, recentPlayer := range recentPlayerList {
+		if recentPlayer.UserID == userID || recentPlayer.PlayerID == userID {
+			delete(recentPlayerList, id)
+		}
+	}
+
+	for id, affinity := range affinityList {
+		if containsString(affinity.UserIDs, userID) {
+			delete(affinityList, id)
+		}
+	}
+	for id, counted := range countedSignalList {
+		if containsString(counted.UserIDs, userID) {
+			delete(countedSignalList, id)
+		}
+	}
+
+	delete(userSettingsList, userID)
+	delete(frozenUserList, userID)
+	delete(frozenUserUpdates, userID)
+	delete(presenceList, userID)
+	return deleted, nil
+}
+
+func (mp *MockRelationships) SetUserFrozen(ctx context.Context, userID string, frozen bool, occurredAt time.Time) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "setUserFrozenDatabase")
+	defer span.End()
+	updatedOn, ok := frozenUserUpdates[userID]
+	if ok && updatedOn.After(occurredAt) {
+		return nil
+	}
+	frozenUserUpdates[userID] = occurredAt
+	if frozen {
+		frozenUserList[userID] = true
+	} else {
+		delete(frozenUserList, userID)
+	}
+	return nil
+}
+
+// The mocked user details aren't cached
+func (mp *MockRelationships) InvalidateUserDetails(ctx context.Context, userID string) error {
+	return nil
+}
+
+// Refuses changes to the relationships of banned users
+func checkNotFrozen(relationship *data.Relationship) error {
+	if frozenUserList[relationship.User1.UserID] || frozenUserList[relationship.User2.UserID] {
+		return data.ErrorUserFrozen
+	}
+	return nil
+}
+
+// Users banned by microservice-user
+var frozenUserList = map[string]bool{}
+
+// Time of the last ban or unban event applied to each user
+var frozenUserUpdates = map[string]time.Time{}
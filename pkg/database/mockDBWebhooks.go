@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+)
+
+func (mp *MockRelationships) AddWebhook(ctx context.Context, webhook *data.Webhook) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "addWebhookDatabase")
+	defer span.End()
+	webhook.ID = uuid.NewString()
+	webhook.CreatedOn = time.Now().UTC().String()
+	webhook.UpdatedOn = time.Now().UTC().String()
+	webhookList = append(webhookList, webhook)
+	return nil
+}
+
+func (mp *MockRelationships) GetWebhooks(ctx context.Context) (data.Webhooks, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getWebhooksDatabase")
+	defer span.End()
+	webhooks := data.Webhooks{}
+	webhooks = append(webhooks, webhookList...)
+	return webhooks, nil
+}
+
+func (mp *MockRelationships) GetWebhook(ctx context.Context, id string) (*data.Webhook, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getWebhookDatabase")
+	defer span.End()
+	index := findIndexByWebhookID(id)
+	if index == -1 {
+		return nil, data.ErrorWebhookNotFound
+	}
+	return webhookList[index], nil
+}
+
+func (mp *MockRelationships) UpdateWebhook(ctx context.Context, webhook *data.Webhook) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "updateWebhookDatabase")
+	defer span.End()
+	index := findIndexByWebhookID(webhook.ID)
+	if index == -1 {
+		return data.ErrorWebhookNotFound
+	}
+	webhook.CreatedOn = webhookList[index].CreatedOn
+	webhook.UpdatedOn = time.Now().UTC().String()
+	webhookList[index] = webhook
+	return nil
+}
+
+func (mp *MockRelationships) DeleteWebhook(ctx context.Context, id string) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "deleteWebhookDatabase")
+	defer span.End()
+	index := findIndexByWebhookID(id)
+	if index == -1 {
+		return data.ErrorWebhookNotFound
+	}
+	webhookList = append(webhookList[:index], webhookList[index+1:]...)
+
+	deliveries := data.WebhookDeliveries{}
+	for _, delivery := range deliveryList {
+		if delivery.WebhookID != id {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	deliveryList = deliveries
+	return nil
+}
+
+func (mp *MockRelationships) AddWebhookDeliveries(ctx context.Context, deliveries data.WebhookDeliveries) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "addWebhookDeliveriesDatabase")
+	defer span.End()
+	for _, delivery := range deliveries {
+		if findIndexByWebhookDeliveryID(delivery.ID) == -1 {
+			deliveryList = append(deliveryList, delivery)
+		}
+	}
+	return nil
+}
+
+func (mp *MockRelationships) ClaimWebhookDeliveries(ctx context.Context, limit int) (data.WebhookDeliveries, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "claimWebhookDeliveriesDatabase")
+	defer span.End()
+	now := time.Now().UTC()
+	deliveries := data.WebhookDeliveries{}
+	for _, delivery := range deliveryList {
+		if len(deliveries) == limit {
+			break
+		}
+		if delivery.Status == data.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) {
+			delivery.NextAttemptAt = now.Add(webhookClaimTimeout)
+			claimed := *delivery
+			deliveries = append(deliveries, &claimed)
+		}
+	}
+	return deliveries, nil
+}
+
+func (mp *MockRelationships) UpdateWebhookDelivery(ctx context.Context, delivery *data.WebhookDelivery) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "updateWebhookDeliveryDatabase")
+	defer span.End()
+	index := findIndexByWebhookDeliveryID(delivery.ID)
+	if index != -1 {
+		deliveryList[index] = delivery
+	}
+	return nil
+}
+
+func (mp *MockRelationships) DeleteWebhookDelivery(ctx context.Context, id string) error {
+	_, span := otel.Tracer("friendslist").Start(ctx, "deleteWebhookDeliveryDatabase")
+	defer span.End()
+	index := findIndexByWebhookDeliveryID(id)
+	if index != -1 {
+		deliveryList = append(deliveryList[:index], deliveryList[index+1:]...)
+	}
+	return nil
+}
+
+func (mp *MockRelationships) GetDeadWebhookDeliveries(ctx context.Context, webhookID string) (data.WebhookDeliveries, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "getDeadWebhookDeliveriesDatabase")
+	defer span.End()
+	deliveries := data.WebhookDeliveries{}
+	for _, delivery := range deliveryList {
+		if delivery.WebhookID == webhookID && delivery.Status == data.WebhookDeliveryDead {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (mp *MockRelationships) ReplayWebhookDeliveries(ctx context.Context, webhookID string, deliveryIDs []string) (int64, error) {
+	_, span := otel.Tracer("friendslist").Start(ctx, "replayWebhookDeliveriesDatabase")
+	defer span.End()
+	var replayed int64
+	for _, delivery := range deliveryList {
+		if delivery.WebhookID != webhookID || delivery.Status != data.WebhookDeliveryDead {
+			continue
+		}
+		if len(deliveryIDs) > 0 && !containsString(deliveryIDs, delivery.ID) {
+			continue
+		}
+		delivery.Status = data.WebhookDeliveryPending
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = time.Now().UTC()
+		replayed++
+	}
+	return replayed, nil
+}
+
+// Returns the index of a webhook in the database
+// Returns -1 when no webhook is found
+func findIndexByWebhookID(id string) int {
+	for index, webhook := range webhookList {
+		if webhook.ID == id {
+			return index
+		}
+	}
+	return -1
+}
+
+// Returns the index of a webhook delivery in the database
+// Returns -1 when no delivery is found
+func findIndexByWebhookDeliveryID(id string) int {
+	for index, delivery := range deliveryList {
+		if delivery.ID == id {
+			return index
+		}
+	}
+	return -1
+}
+
+var webhookList = data.Webhooks{}
+
+var deliveryList = data.WebhookDeliveries{}
@@ -7,12 +7,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -24,12 +26,43 @@ import (
 var ErrorEnvVar = fmt.Errorf("missing environment variable")
 
 type MongoRelationships struct {
-	client     *mongo.Client
-	collection *mongo.Collection
+	client           *mongo.Client
+	collection       *mongo.Collection
+	inviteCounters   *mongo.Collection
+	friendCodes      *mongo.Collection
+	settings         *mongo.Collection
+	friendGroups     *mongo.Collection
+	follows          *mongo.Collection
+	followCounts     *mongo.Collection
+	outbox           *mongo.Collection
+	outboxSequences  *mongo.Collection
+	outboxLeases     *mongo.Collection
+	webhooks         *mongo.Collection
+	deliveries       *mongo.Collection
+	bannedUsers      *mongo.Collection
+	userDetails      *mongo.Collection
+	presence         *mongo.Collection
+	feed             *mongo.Collection
+	sessionInvites   *mongo.Collection
+	recentPlayers    *mongo.Collection
+	affinities       *mongo.Collection
+	countedSignals   *mongo.Collection
+	transactions     bool   // the deployment supports transactions
+	replicaID        string // identifies this replica when relaying the outbox
+	inviteTTL        time.Duration
+	userDetailsTTL   time.Duration
+	presenceTTL      time.Duration
+	sessionInviteTTL time.Duration
+	recentPlayersTTL time.Duration
+	affinityHalfLife time.Duration
+	signalRetention  time.Duration
+	inviteQuota      inviteQuota
+	friendLimit      FriendLimitPolicy
+	publisher        events.Publisher
 }
 
-func NewMongoRelationships() RelationshipDB {
-	mp := &MongoRelationships{}
+func NewMongoRelationships(publisher events.Publisher) RelationshipDB {
+	mp := &MongoRelationships{inviteTTL: inviteTTL(), userDetailsTTL: userDetailsTTL(), presenceTTL: presenceTTL(), sessionInviteTTL: sessionInviteTTL(), recentPlayersTTL: recentPlayersTTL(), affinityHalfLife: affinityHalfLife(), signalRetention: affinitySignalRetention(), inviteQuota: inviteQuotaFromEnv(), friendLimit: friendLimitPolicyFromEnv(), publisher: publisher, replicaID: uuid.NewString()}
 	err := mp.Connect()
 	// If connect fails, kill the program
 	if err != nil {
@@ -65,8 +98,189 @@ func (mp *MongoRelationships) Connect() error {
 
 	collection := client.Database("ubivius").Collection("relationships")
 
+	// Index used to find and sweep expired friend requests
+	_, err = collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{Keys: bson.D{{Key: "expires_at", Value: 1}}})
+	if err != nil {
+		log.Error(err, "Failed to create expires_at index")
+	}
+
+	// Counters of the friend requests sent by each user, removed by MongoDB once their window is over
+	inviteCounters := client.Database("ubivius").Collection("invite_counters")
+	_, err = inviteCounters.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Error(err, "Failed to create invite_counters TTL index")
+	}
+
+	// Friend codes are removed by MongoDB once they expire
+	friendCodes := client.Database("ubivius").Collection("friend_codes")
+	_, err = friendCodes.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		log.Error(err, "Failed to create friend_codes indexes")
+	}
+
+	// Privacy settings of the users
+	settings := client.Database("ubivius").Collection("user_settings")
+
+	// Friend groups, found by owner and by member when a relationship is deleted
+	friendGroups := client.Database("ubivius").Collection("friend_groups")
+	_, err = friendGroups.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "relationship_ids", Value: 1}}},
+	})
+	if err != nil {
+		log.Error(err, "Failed to create friend_groups indexes")
+	}
+
+	// Follows are unique per follower and followed user, their counts are kept apart
+	follows := client.Database("ubivius").Collection("follows")
+	_, err = follows.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "follower_id", Value: 1}, {Key: "followed_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "followed_id", Value: 1}, {Key: "followed_at", Value: -1}}},
+		{Keys: bson.D{{Key: "follower_id", Value: 1}, {Key: "followed_at", Value: -1}}},
+	})
+	if err != nil {
+		log.Error(err, "Failed to create follows indexes")
+	}
+	followCounts := client.Database("ubivius").Collection("follow_counts")
+
+	// Events of the relationship changes waiting to be published, written in the transaction of the change
+	// Collections must exist before being written in a transaction
+	for _, name := range []string{"relationships", "outbox", "outbox_sequences", "session_invites", "affinities", "affinity_signals"} {
+		err = createCollection(context.Background(), client.Database("ubivius"), name)
+		if err != nil {
+			log.Error(err, "Failed to create collection", "collection", name)
+		}
+	}
+	outbox := client.Database("ubivius").Collection("outbox")
+	_, err = outbox.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "pair_key", Value: 1}, {Key: "sequence", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "created_on", Value: 1}}},
+	})
+	if err != nil {
+		log.Error(err, "Failed to create outbox indexes")
+	}
+	outboxSequences := client.Database("ubivius").Collection("outbox_sequences")
+	outboxLeases := client.Database("ubivius").Collection("outbox_leases")
+
+	webhooks := client.Database("ubivius").Collection("webhooks")
+	deliveries := client.Database("ubivius").Collection("webhook_deliveries")
+	_, err = deliveries.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "webhook_id", Value: 1}, {Key: "status", Value: 1}}},
+	})
+	if err != nil {
+		log.Error(err, "Failed to create webhook deliveries indexes")
+	}
+
+	// Users banned by microservice-user, and the user details shared by the replicas
+	bannedUsers := client.Database("ubivius").Collection("banned_users")
+	userDetails := client.Database("ubivius").Collection("user_details")
+	if mp.userDetailsTTL > 0 {
+		_, err = userDetails.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+			Keys:    bson.D{{Key: "cached_on", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(mp.userDetailsTTL.Seconds())),
+		})
+		if err != nil {
+			log.Error(err, "Failed to create user details indexes")
+		}
+	}
+
+	// Last statuses of the users, removed by MongoDB once they expire
+	presence := client.Database("ubivius").Collection("presence")
+	_, err = presence.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Error(err, "Failed to create presence TTL index")
+	}
+
+	// Activity feeds, read newest first and removed by MongoDB once they expire
+	feed := client.Database("ubivius").Collection("feed_items")
+	_, err = feed.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_on", Value: -1}}},
+		{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "subject_id", Value: 1}}},
+		{Keys: bson.D{{Key: "subject_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		log.Error(err, "Failed to create feed indexes")
+	}
+
+	// Invites to game sessions, removed by MongoDB once they expire
+	sessionInvites := client.Database("ubivius").Collection("session_invites")
+	_, err = sessionInvites.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "invitee_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_on", Value: -1}}},
+		{Keys: bson.D{{Key: "session_id", Value: 1}, {Key: "invitee_id", Value: 1}}},
+		{Keys: bson.D{{Key: "host_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		log.Error(err, "Failed to create session invites indexes")
+	}
+
+	// Co-players of the users sent by the game servers, removed by MongoDB once they expire
+	recentPlayers := client.Database("ubivius").Collection("recent_players")
+	_, err = recentPlayers.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "last_played_on", Value: -1}}},
+		{Keys: bson.D{{Key: "player_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		log.Error(err, "Failed to create recent players indexes")
+	}
+
+	// Affinities of the friends, deleted with the friendship or one of the users
+	affinities := client.Database("ubivius").Collection("affinities")
+	_, err = affinities.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "user_ids", Value: 1}},
+	})
+	if err != nil {
+		log.Error(err, "Failed to create affinities index")
+	}
+
+	// Signals counted in the affinities, removed by MongoDB once a signal received again can be counted again
+	countedSignals := client.Database("ubivius").Collection("affinity_signals")
+	_, err = countedSignals.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_ids", Value: 1}}},
+		{Keys: bson.D{{Key: "counted_on", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(mp.signalRetention.Seconds()))},
+	})
+	if err != nil {
+		log.Error(err, "Failed to create affinity signals indexes")
+	}
+
+	mp.transactions = supportsTransactions(context.Background(), client)
+	if !mp.transactions {
+		log.Info("MongoDB deployment doesn't support transactions, events are saved in the outbox after their change")
+	}
+
 	// Assign client and collection to the MongoRelationships struct
 	mp.collection = collection
+	mp.settings = settings
+	mp.friendGroups = friendGroups
+	mp.follows = follows
+	mp.followCounts = followCounts
+	mp.outbox = outbox
+	mp.outboxSequences = outboxSequences
+	mp.outboxLeases = outboxLeases
+	mp.webhooks = webhooks
+	mp.deliveries = deliveries
+	mp.bannedUsers = bannedUsers
+	mp.userDetails = userDetails
+	mp.presence = presence
+	mp.feed = feed
+	mp.sessionInvites = sessionInvites
+	mp.recentPlayers = recentPlayers
+	mp.affinities = affinities
+	mp.countedSignals = countedSignals
+	mp.inviteCounters = inviteCounters
+	mp.friendCodes = friendCodes
 	mp.client = client
 	return nil
 }
@@ -130,6 +344,13 @@ func (mp *MongoRelationships) GetFriendsListByUserID(ctx context.Context, userID
 	// Close the cursor once finished
 	cursor.Close(ctx)
 
+	// Friends banned by microservice-user are hidden until their ban is lifted
+	friends, err = mp.withoutFrozen(ctx, userID, friends)
+	if err != nil {
+		log.Error(err, "Error filtering frozen friends")
+		return nil, err
+	}
+
 	detailedFriends, err := mp.GetUserDetails(userID, friends)
 	if err != nil {
 		log.Error(err, "Error fetching users details")
@@ -158,6 +379,13 @@ func (mp *MongoRelationships) GetInvitesListByUserID(ctx context.Context, userID
 				},
 			}},
 		},
+	}, {
+		// Expired invites are ignored until they are swept
+		Key: "$or",
+		Value: bson.A{
+			bson.D{{Key: "expires_at", Value: nil}},
+			bson.D{{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now().UTC()}}}},
+		},
 	}}
 
 	// friends will hold the array of Relationships
@@ -186,6 +414,12 @@ func (mp *MongoRelationships) GetInvitesListByUserID(ctx context.Context, userID
 	// Close the cursor once finished
 	cursor.Close(ctx)
 
+	invites, err = mp.withoutFrozen(ctx, userID, invites)
+	if err != nil {
+		log.Error(err, "Error filtering frozen invites")
+		return nil, err
+	}
+
 	detailedInvites, err := mp.GetUserDetails(userID, invites)
 	if err != nil {
 		log.Error(err, "Error fetching users details")
@@ -200,68 +434,560 @@ func (mp *MongoRelationships) UpdateRelationship(ctx context.Context, relationsh
 		return err
 	}
 
+	err = mp.checkNotFrozen(ctx, relationship)
+	if err != nil {
+		return err
+	}
+
+	err = checkFriendLimits(ctx, mp.friendLimit, relationship, mp.friendsCounter(ctx, relationship.ID))
+	if err != nil {
+		return err
+	}
+
 	// Set updated timestamp in relationship
 	relationship.UpdatedOn = time.Now().UTC().String()
 
 	// MongoDB search filter
 	filter := bson.D{{Key: "_id", Value: relationship.ID}}
 
+	// Preferences are edited separately by each user, the ones in the request are ignored
+	previous := &data.Relationship{}
+	err = mp.collection.FindOne(ctx, filter).Decode(previous)
+	if err == mongo.ErrNoDocuments {
+		return data.ErrorRelationshipNotFound
+	}
+	if err != nil {
+		return err
+	}
+	err = relationship.ValidateTransition(previous)
+	if err != nil {
+		return err
+	}
+	relationship.KeepPreferences(previous)
+	relationship.ExpiresAt = updatedInviteExpiry(relationship, previous, mp.inviteTTL)
+
 	// Update sets the matched relationships in the database to relationship
 	update := bson.M{"$set": relationship}
 
-	// Update a single item in the database with the values in update that match the filter
-	updateResult, err := mp.collection.UpdateOne(ctx, filter, update)
+	// Update a single item in the database with the values in update that match the filter, with its events
+	err = mp.withOutbox(ctx, func(ctx context.Context) (*data.Relationship, *data.Relationship, error) {
+		updateResult, err := mp.collection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			log.Error(err, "Error updating relationship")
+			return nil, nil, err
+		}
+		if updateResult.MatchedCount != 1 {
+			log.Error(data.ErrorRelationshipNotFound, "No matches found for update")
+			return nil, nil, data.ErrorRelationshipNotFound
+		}
+		return previous, relationship, nil
+	})
 	if err != nil {
-		log.Error(err, "Error updating relationship")
-	}
-	if updateResult.MatchedCount != 1 {
-		log.Error(data.ErrorRelationshipNotFound, "No matches found for update")
 		return err
 	}
 
+	if relationship.IsBlocked() {
+		err = mp.deleteFollowsBetween(ctx, relationship.User1.UserID, relationship.User2.UserID)
+	}
 	return err
 }
 
+func (mp *MongoRelationships) UpdateRelationshipPreferences(ctx context.Context, id string, userID string, preferences *data.RelationshipPreferences) error {
+	relationship := &data.Relationship{}
+	err := mp.collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(relationship)
+	if err == mongo.ErrNoDocuments || (err == nil && relationship.Side(userID) == nil) {
+		return data.ErrorRelationshipNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only the side of the user is updated
+	sideKey := "user_1"
+	if relationship.User2.UserID == userID {
+		sideKey = "user_2"
+	}
+	filter := bson.D{{Key: "_id", Value: id}, {Key: sideKey + ".user_id", Value: userID}}
+	update := bson.M{"$set": bson.M{
+		sideKey + ".nickname":  preferences.Nickname,
+		sideKey + ".note":      preferences.Note,
+		sideKey + ".favourite": preferences.Favourite,
+	}}
+
+	result, err := mp.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Error(err, "Error updating relationship preferences")
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return data.ErrorRelationshipNotFound
+	}
+	return nil
+}
+
 func (mp *MongoRelationships) AddRelationship(ctx context.Context, relationship *data.Relationship) error {
-	err := mp.validateRelationship(relationship)
+	return mp.addRelationship(ctx, relationship, true)
+}
+
+// addRelationship inserts a new relationship, checkPrivacy is false when the receiver of a friend request already agreed to it
+func (mp *MongoRelationships) addRelationship(ctx context.Context, relationship *data.Relationship, checkPrivacy bool) error {
+	err := mp.checkNotFrozen(ctx, relationship)
 	if err != nil {
 		return err
 	}
 
+	accepted, err := mp.acceptCrossingRequest(ctx, relationship)
+	if err != nil || accepted {
+		return err
+	}
+
+	err = mp.validateRelationship(relationship)
+	if err != nil {
+		return err
+	}
+
+	if checkPrivacy {
+		err = checkInvitePolicy(ctx, mp, relationship)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = checkFriendLimits(ctx, mp.friendLimit, relationship, mp.friendsCounter(ctx, relationship.ID))
+	if err != nil {
+		return err
+	}
+
+	reservedKeys, err := mp.checkInviteQuota(ctx, relationship)
+	if err != nil {
+		return err
+	}
+	// A friend request that isn't stored doesn't count against the quota
+	release := func(err error) error {
+		mp.releaseInviteQuota(ctx, reservedKeys)
+		return err
+	}
+
+	if checkPrivacy {
+		err = autoAcceptInvite(ctx, mp, mp.friendLimit, relationship, mp.friendsCounter(ctx, relationship.ID))
+		if err != nil {
+			return release(err)
+		}
+	}
+
 	relationship.ID = uuid.NewString()
 	relationship.ConversationID, err = mp.getConversationID([]string{relationship.User1.UserID, relationship.User2.UserID})
 	if err != nil {
-		return err
+		return release(err)
 	}
 
 	// Adding time information to new relationship
 	relationship.CreatedOn = time.Now().UTC().String()
 	relationship.UpdatedOn = time.Now().UTC().String()
+	relationship.ExpiresAt = inviteExpiry(relationship, mp.inviteTTL)
 
-	// Inserting the new relationship into the database
-	insertResult, err := mp.collection.InsertOne(ctx, relationship)
+	// Each user sets their own preferences once the relationship exists
+	relationship.ClearPreferences()
+
+	// Inserting the new relationship into the database, with its events
+	err = mp.withOutbox(ctx, func(ctx context.Context) (*data.Relationship, *data.Relationship, error) {
+		_, err := mp.collection.InsertOne(ctx, relationship)
+		return nil, relationship, err
+	})
 	if err != nil {
-		return err
+		return release(err)
 	}
 
-	log.Info("Inserting relationship", "Inserted ID", insertResult.InsertedID)
+	log.Info("Inserting relationship", "Inserted ID", relationship.ID)
+
+	if relationship.IsBlocked() {
+		return mp.deleteFollowsBetween(ctx, relationship.User1.UserID, relationship.User2.UserID)
+	}
 	return nil
 }
 
+// acceptCrossingRequest turns the pending request of the receiver to the sender of a friend request into a friendship
+// The other user already asked for the friendship, so the privacy settings and quotas of the receiver don't apply
+func (mp *MongoRelationships) acceptCrossingRequest(ctx context.Context, relationship *data.Relationship) (bool, error) {
+	if !relationship.IsPending() {
+		return false, nil
+	}
+
+	existing, err := mp.findRelationshipByUserIDs(ctx, relationship.User1.UserID, relationship.User2.UserID)
+	if err != nil || existing == nil || !relationship.Crosses(existing) || existing.IsExpired(time.Now().UTC()) {
+		return false, err
+	}
+
+	friendship := *existing
+	friendship.Accept()
+	err = checkFriendLimits(ctx, mp.friendLimit, &friendship, mp.friendsCounter(ctx, existing.ID))
+	if err != nil {
+		return false, err
+	}
+	friendship.UpdatedOn = time.Now().UTC().String()
+
+	// Only a request still pending is accepted, a concurrent answer wins
+	filter := bson.D{
+		{Key: "_id", Value: existing.ID},
+		{Key: "user_1.relationship_type", Value: existing.User1.RelationshipType},
+		{Key: "user_2.relationship_type", Value: existing.User2.RelationshipType},
+	}
+	update := bson.M{"$set": bson.M{
+		"user_1.relationship_type": data.Friend,
+		"user_2.relationship_type": data.Friend,
+		"updated_on":               friendship.UpdatedOn,
+		"expires_at":               nil,
+	}}
+	accepted := false
+	err = mp.withOutbox(ctx, func(ctx context.Context) (*data.Relationship, *data.Relationship, error) {
+		result, err := mp.collection.UpdateOne(ctx, filter, update)
+		if err != nil || result.MatchedCount == 0 {
+			return nil, nil, err
+		}
+		accepted = true
+		return existing, &friendship, nil
+	})
+	if err != nil || !accepted {
+		return false, err
+	}
+
+	log.Info("Accepted crossing friend requests", "id", existing.ID)
+	// The friendship is returned to the sender of the request, the preferences of the other user stay private
+	otherUserID := relationship.OtherUser(relationship.Sender()).UserID
+	*relationship = friendship
+	relationship.ClearPreferencesOf(otherUserID)
+	return true, nil
+}
+
 func (mp *MongoRelationships) DeleteRelationship(ctx context.Context, id string) error {
 	// MongoDB search filter
 	filter := bson.D{{Key: "_id", Value: id}}
 
-	// Delete a single item matching the filter
-	result, err := mp.collection.DeleteOne(ctx, filter)
+	// Delete a single item matching the filter, with its events
+	err := mp.withOutbox(ctx, func(ctx context.Context) (*data.Relationship, *data.Relationship, error) {
+		deleted := &data.Relationship{}
+		err := mp.collection.FindOneAndDelete(ctx, filter).Decode(deleted)
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return deleted, nil, nil
+	})
 	if err != nil {
 		log.Error(err, "Error deleting relationship")
+		return err
 	}
 
-	log.Info("Deleted documents in relationships collection", "delete_count", result.DeletedCount)
+	log.Info("Deleted relationship", "id", id)
+
+	err = mp.removeFromFriendGroups(ctx, id)
+	if err != nil {
+		log.Error(err, "Error removing deleted relationship from friend groups", "id", id)
+	}
 	return nil
 }
 
+func (mp *MongoRelationships) GetRelationshipsByUserIDAndType(ctx context.Context, userID string, relationshipType data.RelationshipType) (*data.DetailedRelationships, error) {
+	// The type is the one of the user's side
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "user_1.user_id", Value: userID}, {Key: "user_1.relationship_type", Value: relationshipType}},
+		bson.D{{Key: "user_2.user_id", Value: userID}, {Key: "user_2.relationship_type", Value: relationshipType}},
+	}}}
+
+	cursor, err := mp.collection.Find(ctx, filter)
+	if err != nil {
+		log.Error(err, "Error getting relationships by type from database")
+		return nil, err
+	}
+
+	relationships := data.Relationships{}
+	err = cursor.All(ctx, &relationships)
+	if err != nil {
+		log.Error(err, "Error decoding relationships from database")
+		return nil, err
+	}
+
+	relationships, err = mp.withoutFrozen(ctx, userID, relationships)
+	if err != nil {
+		return nil, err
+	}
+
+	return mp.GetUserDetails(userID, relationships)
+}
+
+func (mp *MongoRelationships) GetFriendIDsByUserID(ctx context.Context, userID string) ([]string, error) {
+	cursor, err := mp.collection.Find(ctx, userFriendshipsFilter(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	friends := data.Relationships{}
+	err = cursor.All(ctx, &friends)
+	if err != nil {
+		return nil, err
+	}
+
+	friends, err = mp.withoutFrozen(ctx, userID, friends)
+	if err != nil {
+		return nil, err
+	}
+
+	var friendIDs []string
+	for _, relationship := range friends {
+		friendIDs = append(friendIDs, relationship.OtherUser(userID).UserID)
+	}
+	return friendIDs, nil
+}
+
+func (mp *MongoRelationships) GetBlockerIDsByUserID(ctx context.Context, userID string) ([]string, error) {
+	// Relationships where the other user is on the blocked side
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "user_1.user_id", Value: userID}, {Key: "user_2.relationship_type", Value: data.Blocked}},
+		bson.D{{Key: "user_2.user_id", Value: userID}, {Key: "user_1.relationship_type", Value: data.Blocked}},
+	}}}
+
+	cursor, err := mp.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var blockerIDs []string
+	for cursor.Next(ctx) {
+		var relationship data.Relationship
+		err := cursor.Decode(&relationship)
+		if err != nil {
+			return nil, err
+		}
+		blockerIDs = append(blockerIDs, relationship.OtherUser(userID).UserID)
+	}
+	return blockerIDs, cursor.Err()
+}
+
+func (mp *MongoRelationships) GetRelationshipByUserIDs(ctx context.Context, userID1 string, userID2 string) (*data.Relationship, error) {
+	relationship, err := mp.findRelationshipByUserIDs(ctx, userID1, userID2)
+	if err != nil {
+		return nil, err
+	}
+	if relationship == nil {
+		return nil, data.ErrorRelationshipNotFound
+	}
+	return relationship, nil
+}
+
+func (mp *MongoRelationships) DeleteExpiredInvites(ctx context.Context) (int64, error) {
+	// MongoDB search filter
+	filter := bson.D{{Key: "expires_at", Value: bson.D{{Key: "$lte", Value: time.Now().UTC()}}}}
+
+	result, err := mp.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		log.Error(err, "Error deleting expired invites")
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
+
+func (mp *MongoRelationships) ExportRelationshipsByUserID(ctx context.Context, userID string, export func(*data.Relationship) error) error {
+	// Find returns a cursor that must be iterated through
+	cursor, err := mp.collection.Find(ctx, userRelationshipsFilter(userID))
+	if err != nil {
+		log.Error(err, "Error getting relationships from database")
+		return err
+	}
+
+	// Close the cursor once finished
+	defer cursor.Close(ctx)
+
+	// Each relationship is handed to export as soon as it is decoded so the whole list is never held in memory
+	for cursor.Next(ctx) {
+		var result data.Relationship
+		err := cursor.Decode(&result)
+		if err != nil {
+			log.Error(err, "Error decoding relationship from database")
+			return err
+		}
+
+		err = export(&result)
+		if err != nil {
+			return err
+		}
+	}
+	err = cursor.Err()
+	if err != nil {
+		return err
+	}
+
+	// Follows are relationships too, the export holds every one of them
+	return mp.exportFollows(ctx, userID, export)
+}
+
+func (mp *MongoRelationships) AddPartyFriendships(ctx context.Context, userIDs []string) (*data.PartyFriendshipResult, error) {
+	return addPartyFriendships(ctx, mp, userIDs)
+}
+
+func (mp *MongoRelationships) MergeUserRelationships(ctx context.Context, sourceUserID string, targetUserID string) (*data.MergeResult, error) {
+	if sourceUserID == targetUserID {
+		return nil, data.ErrorSameUserID
+	}
+	if !mp.validateUserExist(targetUserID) {
+		return nil, data.ErrorUserNotFound
+	}
+
+	result := &data.MergeResult{SourceUserID: sourceUserID, TargetUserID: targetUserID}
+
+	// Relationships are handled one at a time and every step leaves the collection consistent,
+	// so an interrupted merge resumes where it stopped when it is requested again
+	for {
+		var relationship data.Relationship
+		err := mp.collection.FindOne(ctx, userRelationshipsFilter(sourceUserID)).Decode(&relationship)
+		if err == mongo.ErrNoDocuments {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+
+		otherUserID := relationship.OtherUser(sourceUserID).UserID
+
+		// The relationship between the two merged accounts can't be kept
+		if otherUserID == targetUserID {
+			err = mp.deleteMergedRelationship(ctx, &relationship)
+			if err != nil {
+				return result, err
+			}
+			result.Dropped++
+			continue
+		}
+
+		existing, err := mp.findRelationshipByUserIDs(ctx, targetUserID, otherUserID)
+		if err != nil {
+			return result, err
+		}
+
+		if existing != nil {
+			// Collision, only the relationship with the highest precedence is kept
+			toDelete := &relationship
+			if relationship.KeepsOver(existing) {
+				toDelete = existing
+			}
+			err = mp.deleteMergedRelationship(ctx, toDelete)
+			if err != nil {
+				return result, err
+			}
+			if toDelete == &relationship {
+				result.Dropped++
+				continue
+			}
+			result.Replaced++
+		} else {
+			result.Moved++
+		}
+
+		// Rewrite the source user's side to the target user
+		err = mp.moveMergedRelationship(ctx, &relationship, sourceUserID, targetUserID)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// The friend groups hold the moved relationships, the dropped ones were removed from them
+	groupsResult, err := mp.friendGroups.UpdateMany(ctx,
+		bson.D{{Key: "user_id", Value: sourceUserID}},
+		bson.M{"$set": bson.M{"user_id": targetUserID, "updated_on": time.Now().UTC().String()}},
+	)
+	if err != nil {
+		return result, err
+	}
+	result.MovedGroups = int(groupsResult.ModifiedCount)
+
+	err = mp.mergeFollows(ctx, sourceUserID, targetUserID, result)
+	if err != nil {
+		return result, err
+	}
+
+	log.Info("Merged user relationships", "source_user_id", sourceUserID, "target_user_id", targetUserID, "moved", result.Moved, "replaced", result.Replaced, "dropped", result.Dropped, "moved_follows", result.MovedFollows, "moved_groups", result.MovedGroups)
+	return result, nil
+}
+
+// mergeFollows moves the follows of the source user to the target user, one at a time like the relationships
+// A follow is inserted for the target user before the one of the source user is deleted, so the counters of every user stay right
+func (mp *MongoRelationships) mergeFollows(ctx context.Context, sourceUserID string, targetUserID string, result *data.MergeResult) error {
+	for {
+		follow := &data.Follow{}
+		err := mp.follows.FindOne(ctx, bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "follower_id", Value: sourceUserID}},
+			bson.D{{Key: "followed_id", Value: sourceUserID}},
+		}}}).Decode(follow)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		followerID, followedID := mergedFollowUsers(follow, sourceUserID, targetUserID)
+		moved := false
+		if followerID != followedID {
+			relationship, err := mp.findRelationshipByUserIDs(ctx, followerID, followedID)
+			if err != nil {
+				return err
+			}
+			if relationship == nil || !relationship.IsBlocked() {
+				err = mp.insertFollow(ctx, &data.Follow{ID: uuid.NewString(), FollowerID: followerID, FollowedID: followedID, CreatedOn: follow.CreatedOn, FollowedAt: follow.FollowedAt})
+				if err != nil && !mongo.IsDuplicateKeyError(err) {
+					return err
+				}
+				moved = err == nil
+			}
+		}
+
+		err = mp.DeleteFollow(ctx, follow.FollowerID, follow.FollowedID)
+		if err != nil && err != data.ErrorFollowNotFound {
+			return err
+		}
+		if moved {
+			result.MovedFollows++
+		} else {
+			result.DroppedFollows++
+		}
+	}
+}
+
+// deleteMergedRelationship deletes a relationship discarded by an account merge
+func (mp *MongoRelationships) deleteMergedRelationship(ctx context.Context, relationship *data.Relationship) error {
+	return mp.withOutbox(ctx, func(ctx context.Context) (*data.Relationship, *data.Relationship, error) {
+		result, err := mp.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: relationship.ID}})
+		if err != nil || result.DeletedCount == 0 {
+			return nil, nil, err
+		}
+		return relationship, nil, mp.removeFromFriendGroups(ctx, relationship.ID)
+	})
+}
+
+// moveMergedRelationship rewrites the side of the source user of a relationship to the target user
+func (mp *MongoRelationships) moveMergedRelationship(ctx context.Context, relationship *data.Relationship, sourceUserID string, targetUserID string) error {
+	userKey := "user_1.user_id"
+	if relationship.User2.UserID == sourceUserID {
+		userKey = "user_2.user_id"
+	}
+	moved := *relationship
+	moved.Side(sourceUserID).UserID = targetUserID
+	moved.UpdatedOn = time.Now().UTC().String()
+
+	return mp.withEvents(ctx, func(ctx context.Context) (events.Events, error) {
+		filter := bson.D{{Key: "_id", Value: relationship.ID}, {Key: userKey, Value: sourceUserID}}
+		update := bson.M{"$set": bson.M{userKey: targetUserID, "updated_on": moved.UpdatedOn}}
+		result, err := mp.collection.UpdateOne(ctx, filter, update)
+		if err != nil || result.ModifiedCount == 0 {
+			return nil, err
+		}
+		return mergeEvents(relationship, &moved), nil
+	})
+}
+
 func (mp *MongoRelationships) validateRelationship(relationship *data.Relationship) error {
 	if !mp.validateUserExist(relationship.User1.UserID) || !mp.validateUserExist(relationship.User2.UserID) {
 		return data.ErrorUserNotFound
@@ -282,6 +1008,97 @@ func (mp *MongoRelationships) validateRelationship(relationship *data.Relationsh
 	return nil
 }
 
+// friendsCounter returns a function counting the friends of a user, without the relationship with the excluded ID
+func (mp *MongoRelationships) friendsCounter(ctx context.Context, excludedID string) func(userID string) (int64, error) {
+	return func(userID string) (int64, error) {
+		filter := bson.D{
+			{Key: "_id", Value: bson.D{{Key: "$ne", Value: excludedID}}},
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: "user_1.user_id", Value: userID}, {Key: "user_1.relationship_type", Value: data.Friend}},
+				bson.D{{Key: "user_2.user_id", Value: userID}, {Key: "user_2.relationship_type", Value: data.Friend}},
+			}},
+		}
+		return mp.collection.CountDocuments(ctx, filter)
+	}
+}
+
+// checkInviteQuota counts a new friend request against the quotas of its sender, and returns the incremented counters
+// Counters are stored in MongoDB so the limits hold across every replica of the service
+func (mp *MongoRelationships) checkInviteQuota(ctx context.Context, relationship *data.Relationship) ([]string, error) {
+	senderID := relationship.Sender()
+	if senderID == "" {
+		return nil, nil
+	}
+	now := time.Now().UTC()
+
+	if mp.inviteQuota.maxPending > 0 {
+		filter := bson.D{
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: "user_1.user_id", Value: senderID}, {Key: "user_1.relationship_type", Value: data.PendingOutgoing}},
+				bson.D{{Key: "user_2.user_id", Value: senderID}, {Key: "user_2.relationship_type", Value: data.PendingOutgoing}},
+			}},
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: "expires_at", Value: nil}},
+				bson.D{{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now}}}},
+			}},
+		}
+		count, err := mp.collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		if count >= int64(mp.inviteQuota.maxPending) {
+			// A slot is freed at the latest when the oldest pending invite expires
+			retryAfter := mp.inviteTTL
+			var oldest data.Relationship
+			err = mp.collection.FindOne(ctx, filter, options.FindOne().SetSort(bson.D{{Key: "expires_at", Value: 1}})).Decode(&oldest)
+			if err == nil && oldest.ExpiresAt != nil {
+				retryAfter = oldest.ExpiresAt.Sub(now)
+			}
+			if retryAfter <= 0 {
+				retryAfter = time.Hour
+			}
+			return nil, &data.InviteQuotaError{Limit: "pending", RetryAfter: retryAfter}
+		}
+	}
+
+	var incrementedKeys []string
+	for _, window := range mp.inviteQuota.windows() {
+		key := window.key(senderID, now)
+		update := bson.M{
+			"$inc":         bson.M{"count": 1},
+			"$setOnInsert": bson.M{"user_id": senderID, "expires_at": window.end(now)},
+		}
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+		var counter struct {
+			Count int `bson:"count"`
+		}
+		err := mp.inviteCounters.FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: key}}, update, opts).Decode(&counter)
+		if err != nil {
+			mp.releaseInviteQuota(ctx, incrementedKeys)
+			return nil, err
+		}
+		incrementedKeys = append(incrementedKeys, key)
+
+		if counter.Count > window.limit {
+			// Refused requests don't count against the quota
+			mp.releaseInviteQuota(ctx, incrementedKeys)
+			return nil, &data.InviteQuotaError{Limit: window.name, RetryAfter: window.end(now).Sub(now)}
+		}
+	}
+	return incrementedKeys, nil
+}
+
+// releaseInviteQuota gives back the friend requests counted by checkInviteQuota
+func (mp *MongoRelationships) releaseInviteQuota(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		_, err := mp.inviteCounters.UpdateOne(ctx, bson.D{{Key: "_id", Value: key}}, bson.M{"$inc": bson.M{"count": -1}})
+		if err != nil {
+			log.Error(err, "Error releasing invite quota", "key", key)
+		}
+	}
+}
+
 func (mp *MongoRelationships) validateUserExist(userID string) bool {
 	getUserByIDPath := data.MicroserviceUserPath + "/users/" + userID
 	resp, err := http.Get(getUserByIDPath)
@@ -289,8 +1106,56 @@ func (mp *MongoRelationships) validateUserExist(userID string) bool {
 }
 
 func (mp *MongoRelationships) relationshipExist(id string, userID1 string, userID2 string) (bool, error) {
-	// MongoDB search filter
-	filter := bson.D{
+	// Holds search result
+	var result data.Relationship
+
+	// Find a single matching item from the database
+	err := mp.collection.FindOne(context.Background(), relationshipBetweenFilter(userID1, userID2)).Decode(&result)
+
+	if err == mongo.ErrNoDocuments || result.ID == id {
+		return false, nil
+	}
+	return true, err
+}
+
+// findRelationshipByUserIDs returns the relationship between two users, or nil if there is none
+func (mp *MongoRelationships) findRelationshipByUserIDs(ctx context.Context, userID1 string, userID2 string) (*data.Relationship, error) {
+	var result data.Relationship
+	err := mp.collection.FindOne(ctx, relationshipBetweenFilter(userID1, userID2)).Decode(&result)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// userRelationshipsFilter matches every relationship involving the user
+func userRelationshipsFilter(userID string) bson.D {
+	return bson.D{{
+		Key: "$or",
+		Value: bson.A{
+			bson.D{{Key: "user_1.user_id", Value: userID}},
+			bson.D{{Key: "user_2.user_id", Value: userID}},
+		},
+	}}
+}
+
+// userFriendshipsFilter matches every relationship where the user is a friend
+func userFriendshipsFilter(userID string) bson.D {
+	return bson.D{{
+		Key: "$or",
+		Value: bson.A{
+			bson.D{{Key: "user_1.user_id", Value: userID}, {Key: "user_1.relationship_type", Value: data.Friend}},
+			bson.D{{Key: "user_2.user_id", Value: userID}, {Key: "user_2.relationship_type", Value: data.Friend}},
+		},
+	}}
+}
+
+// relationshipBetweenFilter matches the relationship between two users, whatever their side
+func relationshipBetweenFilter(userID1 string, userID2 string) bson.D {
+	return bson.D{
 		{
 			Key: "$or",
 			Value: bson.A{
@@ -306,17 +1171,6 @@ func (mp *MongoRelationships) relationshipExist(id string, userID1 string, userI
 			},
 		},
 	}
-
-	// Holds search result
-	var result data.Relationship
-
-	// Find a single matching item from the database
-	err := mp.collection.FindOne(context.Background(), filter).Decode(&result)
-
-	if err == mongo.ErrNoDocuments || result.ID == id {
-		return false, nil
-	}
-	return true, err
 }
 
 func (mp *MongoRelationships) getConversationID(userID []string) (string, error) {
@@ -347,7 +1201,40 @@ func deleteAllRelationshipsFromMongoDB() error {
 	}
 	collection := client.Database("ubivius").Collection("relationships")
 	_, err = collection.DeleteMany(context.Background(), bson.D{{}})
-	return err
+	if err != nil {
+		return err
+	}
+	_, err = client.Database("ubivius").Collection("invite_counters").DeleteMany(context.Background(), bson.D{{}})
+	if err != nil {
+		return err
+	}
+	_, err = client.Database("ubivius").Collection("friend_codes").DeleteMany(context.Background(), bson.D{{}})
+	if err != nil {
+		return err
+	}
+	_, err = client.Database("ubivius").Collection("user_settings").DeleteMany(context.Background(), bson.D{{}})
+	if err != nil {
+		return err
+	}
+	_, err = client.Database("ubivius").Collection("friend_groups").DeleteMany(context.Background(), bson.D{{}})
+	if err != nil {
+		return err
+	}
+	_, err = client.Database("ubivius").Collection("follows").DeleteMany(context.Background(), bson.D{{}})
+	if err != nil {
+		return err
+	}
+	_, err = client.Database("ubivius").Collection("follow_counts").DeleteMany(context.Background(), bson.D{{}})
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"outbox", "outbox_sequences", "outbox_leases", "webhooks", "webhook_deliveries", "banned_users", "user_details", "presence", "feed_items", "session_invites", "recent_players", "affinities", "affinity_signals"} {
+		_, err = client.Database("ubivius").Collection(name).DeleteMany(context.Background(), bson.D{{}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // extracts the value for a key from a JSON-formatted string
@@ -400,6 +1287,8 @@ func (mp *MongoRelationships) GetUserDetails(userID string, relations data.Relat
 			ConversationID: relation.ConversationID,
 			CreatedOn: relation.CreatedOn,
 			UpdatedOn: relation.UpdatedOn,
+			ExpiresAt: relation.ExpiresAt,
+			RelationshipPreferences: relation.PreferencesOf(userID),
 		}
 		detailedRelationsList = append(detailedRelationsList, &detailedRelationship)
 	}
@@ -407,11 +1296,16 @@ func (mp *MongoRelationships) GetUserDetails(userID string, relations data.Relat
 }
 
 func (mp *MongoRelationships) GetUserByID(userID string) (*data.DetailedUser, error) {
+	if cached := mp.cachedUser(context.Background(), userID); cached != nil {
+		return cached, nil
+	}
+
 	getUserByIDPath := data.MicroserviceUserPath + "/users/" + userID
 	resp, err := http.Get(getUserByIDPath)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	detailedUser := &data.DetailedUser{}
 	err = json.NewDecoder(resp.Body).Decode(detailedUser)
@@ -419,5 +1313,73 @@ func (mp *MongoRelationships) GetUserByID(userID string) (*data.DetailedUser, er
 		return nil, err
 	}
 
+	if resp.StatusCode == http.StatusOK {
+		mp.cacheUser(context.Background(), detailedUser)
+	}
 	return detailedUser, nil
 }
+
+// getGuildID looks up the guild of the user in microservice-user, an empty string meaning the user has no guild
+func (mp *MongoRelationships) getGuildID(ctx context.Context, userID string) (string, error) {
+	getUserByIDPath := data.MicroserviceUserPath + "/users/" + userID
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, getUserByIDPath, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d from microservice-user", resp.StatusCode)
+	}
+
+	account := struct {
+		GuildID string `json:"guild_id"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&account)
+	if err != nil {
+		return "", err
+	}
+	return account.GuildID, nil
+}
+
+// FindUsers searches users by exact username or email with the lookup API of microservice-user
+func (mp *MongoRelationships) FindUsers(ctx context.Context, lookup *data.UserLookup) ([]*data.DetailedUser, error) {
+	query := url.Values{}
+	if lookup.Username != "" {
+		query.Set("username", lookup.Username)
+	}
+	if lookup.Email != "" {
+		query.Set("email", lookup.Email)
+	}
+
+	findUsersPath := data.MicroserviceUserPath + "/users?" + query.Encode()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, findUsersPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	users := []*data.DetailedUser{}
+	if resp.StatusCode == http.StatusNotFound {
+		return users, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from microservice-user", resp.StatusCode)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&users)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (mp *MongoRelationships) AddAffinitySignal(ctx context.Context, signal *data.AffinitySignal) error {
+	pairs, err := affinityPairs(ctx, mp, signal)
+	if err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		err = mp.addAffinity(ctx, pair[0], pair[1], signal)
+		if err != nil {
+			log.Error(err, "Error adding affinity signal", "signal_id", signal.ID)
+			return err
+		}
+	}
+	return nil
+}
+
+// addAffinity counts a signal in the affinity of two friends, unless it was already counted
+// The signal is remembered in the same transaction as the score, a signal received late is counted however old it is
+func (mp *MongoRelationships) addAffinity(ctx context.Context, userID1 string, userID2 string, signal *data.AffinitySignal) error {
+	counted := newCountedSignal(userID1, userID2, signal)
+	return mp.withTransaction(ctx, func(ctx context.Context) error {
+		result, err := mp.countedSignals.UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: counted.ID}},
+			bson.M{"$setOnInsert": bson.M{"user_ids": counted.UserIDs, "counted_on": counted.CountedOn}},
+			options.Update().SetUpsert(true),
+		)
+		if mongo.IsDuplicateKeyError(err) || (err == nil && result.UpsertedCount == 0) {
+			log.Info("Ignoring affinity signal already counted", "id", counted.ID)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = mp.addAffinityScore(ctx, userID1, userID2, signal)
+		if err != nil && !mp.transactions {
+			// Without transaction, the signal is forgotten so it is counted when it is received again
+			_, deleteErr := mp.countedSignals.DeleteOne(ctx, bson.D{{Key: "_id", Value: counted.ID}})
+			if deleteErr != nil {
+				log.Error(deleteErr, "Error forgetting affinity signal", "id", counted.ID)
+			}
+		}
+		return err
+	})
+}
+
+// addAffinityScore adds a signal to the score of the affinity of two friends
+// The affinity is only replaced when no other signal changed it since it was read, the signal is counted again on the new score otherwise
+func (mp *MongoRelationships) addAffinityScore(ctx context.Context, userID1 string, userID2 string, signal *data.AffinitySignal) error {
+	id := affinityID(userID1, userID2)
+	for attempt := 0; attempt < affinityRetries; attempt++ {
+		affinity := &data.Affinity{}
+		err := mp.affinities.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(affinity)
+		if err == mongo.ErrNoDocuments {
+			affinity = newAffinity(userID1, userID2)
+			addAffinitySignal(affinity, signal, mp.affinityHalfLife)
+			_, err = mp.affinities.InsertOne(ctx, affinity)
+			if mongo.IsDuplicateKeyError(err) {
+				continue
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		version := affinity.Version
+		addAffinitySignal(affinity, signal, mp.affinityHalfLife)
+		filter := bson.D{{Key: "_id", Value: id}, {Key: "version", Value: version}}
+		updateResult, err := mp.affinities.ReplaceOne(ctx, filter, affinity)
+		if err != nil {
+			return err
+		}
+		if updateResult.MatchedCount == 1 {
+			return nil
+		}
+	}
+	return errorAffinityConflict
+}
+
+func (mp *MongoRelationships) GetAffinities(ctx context.Context, userID string, friendIDs []string) (map[string]float64, error) {
+	ids := make([]string, 0, len(friendIDs))
+	for _, friendID := range friendIDs {
+		ids = append(ids, affinityID(userID, friendID))
+	}
+	cursor, err := mp.affinities.Find(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+	if err != nil {
+		return nil, err
+	}
+	affinities := []*data.Affinity{}
+	err = cursor.All(ctx, &affinities)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	scores := make(map[string]float64, len(affinities))
+	for _, affinity := range affinities {
+		for _, affinityUserID := range affinity.UserIDs {
+			if affinityUserID != userID {
+				scores[affinityUserID] = currentAffinity(affinity, now, mp.affinityHalfLife)
+			}
+		}
+	}
+	return scores, nil
+}
+
+func (mp *MongoRelationships) DeleteAffinity(ctx context.Context, userID1 string, userID2 string) error {
+	_, err := mp.affinities.DeleteOne(ctx, bson.D{{Key: "_id", Value: affinityID(userID1, userID2)}})
+	if err != nil {
+		log.Error(err, "Error deleting affinity")
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (mp *MongoRelationships) AddFeedItems(ctx context.Context, items data.FeedItems, keep int) error {
+	if len(items) == 0 {
+		return nil
+	}
+	documents := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		documents = append(documents, item)
+	}
+
+	// Items saved by a previous attempt are kept, the other items are still inserted
+	_, err := mp.feed.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		log.Error(err, "Error adding feed items")
+		return err
+	}
+
+	// An event adds an item to the feed of each friend, every feed is trimmed once
+	trimmed := map[string]bool{}
+	for _, item := range items {
+		if trimmed[item.UserID] {
+			continue
+		}
+		trimmed[item.UserID] = true
+		err = mp.trimFeed(ctx, item.UserID, keep)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mp *MongoRelationships) GetFeedByUserID(ctx context.Context, userID string, offset int, limit int) (data.FeedItems, error) {
+	// Expired items are skipped, MongoDB removes them on its own schedule
+	filter := bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now().UTC()}}},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_on", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+	cursor, err := mp.feed.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	items := data.FeedItems{}
+	err = cursor.All(ctx, &items)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (mp *MongoRelationships) DeleteFeedItemsBetween(ctx context.Context, userID1 string, userID2 string) error {
+	_, err := mp.feed.DeleteMany(ctx, bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "actor_id", Value: userID1}, {Key: "subject_id", Value: userID2}},
+		bson.D{{Key: "actor_id", Value: userID2}, {Key: "subject_id", Value: userID1}},
+	}}})
+	if err != nil {
+		log.Error(err, "Error deleting feed items")
+	}
+	return err
+}
+
+// trimFeed deletes the oldest items of a feed holding more than keep items
+func (mp *MongoRelationships) trimFeed(ctx context.Context, userID string, keep int) error {
+	filter := bson.D{{Key: "user_id", Value: userID}}
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_on", Value: -1}}).SetSkip(int64(keep))
+	oldest := &data.FeedItem{}
+	err := mp.feed.FindOne(ctx, filter, opts).Decode(oldest)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = mp.feed.DeleteMany(ctx, bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "created_on", Value: bson.D{{Key: "$lte", Value: oldest.CreatedOn}}},
+	})
+	return err
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (mp *MongoRelationships) AddFollow(ctx context.Context, followerID string, followedID string) (*data.Follow, error) {
+	if followerID == followedID {
+		return nil, data.ErrorSameUserID
+	}
+	if !mp.validateUserExist(followedID) {
+		return nil, data.ErrorUserNotFound
+	}
+
+	// Blocks apply to follows
+	relationship, err := mp.findRelationshipByUserIDs(ctx, followerID, followedID)
+	if err != nil {
+		return nil, err
+	}
+	if relationship != nil && relationship.IsBlocked() {
+		return nil, data.ErrorUserBlocked
+	}
+
+	now := time.Now().UTC()
+	follow := &data.Follow{
+		ID:         uuid.NewString(),
+		FollowerID: followerID,
+		FollowedID: followedID,
+		CreatedOn:  now.String(),
+		FollowedAt: now,
+	}
+	err = mp.insertFollow(ctx, follow)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, data.ErrorFollowExist
+	}
+	if err != nil {
+		log.Error(err, "Error inserting follow", "follower_id", followerID, "followed_id", followedID)
+		return nil, err
+	}
+
+	log.Info("Inserting follow", "follower_id", followerID, "followed_id", followedID)
+	return follow, nil
+}
+
+func (mp *MongoRelationships) DeleteFollow(ctx context.Context, followerID string, followedID string) error {
+	filter := bson.D{{Key: "follower_id", Value: followerID}, {Key: "followed_id", Value: followedID}}
+
+	// The follow and the counters change together, the counters stay right when one of the writes fails
+	err := mp.withTransaction(ctx, func(ctx context.Context) error {
+		result, err := mp.follows.DeleteOne(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			return data.ErrorFollowNotFound
+		}
+		return mp.incrementFollowCounts(ctx, followerID, followedID, -1)
+	})
+	if err != nil && err != data.ErrorFollowNotFound {
+		log.Error(err, "Error deleting follow")
+	}
+	return err
+}
+
+func (mp *MongoRelationships) GetFollowersByUserID(ctx context.Context, userID string, offset int, limit int) (*data.DetailedRelationships, error) {
+	return mp.getFollows(ctx, bson.D{{Key: "followed_id", Value: userID}}, userID, offset, limit)
+}
+
+func (mp *MongoRelationships) GetFollowingByUserID(ctx context.Context, userID string, offset int, limit int) (*data.DetailedRelationships, error) {
+	return mp.getFollows(ctx, bson.D{{Key: "follower_id", Value: userID}}, userID, offset, limit)
+}
+
+func (mp *MongoRelationships) GetFollowCounts(ctx context.Context, userID string) (*data.FollowCounts, error) {
+	followCounts := &data.FollowCounts{}
+	err := mp.followCounts.FindOne(ctx, bson.D{{Key: "_id", Value: userID}}).Decode(followCounts)
+	if err == mongo.ErrNoDocuments {
+		return &data.FollowCounts{UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return followCounts, nil
+}
+
+// getFollows returns a page of the follows matching the filter, newest first
+func (mp *MongoRelationships) getFollows(ctx context.Context, filter bson.D, userID string, offset int, limit int) (*data.DetailedRelationships, error) {
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "followed_at", Value: -1}, {Key: "_id", Value: 1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+
+	cursor, err := mp.follows.Find(ctx, filter, findOptions)
+	if err != nil {
+		log.Error(err, "Error getting follows from database")
+		return nil, err
+	}
+
+	follows := data.Follows{}
+	err = cursor.All(ctx, &follows)
+	if err != nil {
+		log.Error(err, "Error decoding follows from database")
+		return nil, err
+	}
+
+	relationships := data.Relationships{}
+	for _, follow := range follows {
+		relationships = append(relationships, follow.Relationship())
+	}
+	return mp.GetUserDetails(userID, relationships)
+}
+
+// insertFollow saves a follow and counts it for both users, in the same transaction
+func (mp *MongoRelationships) insertFollow(ctx context.Context, follow *data.Follow) error {
+	return mp.withTransaction(ctx, func(ctx context.Context) error {
+		_, err := mp.follows.InsertOne(ctx, follow)
+		if err != nil {
+			return err
+		}
+		return mp.incrementFollowCounts(ctx, follow.FollowerID, follow.FollowedID, 1)
+	})
+}
+
+// exportFollows hands every follow of the user to export, as a Follower or Following relationship
+func (mp *MongoRelationships) exportFollows(ctx context.Context, userID string, export func(*data.Relationship) error) error {
+	cursor, err := mp.follows.Find(ctx, bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "follower_id", Value: userID}},
+		bson.D{{Key: "followed_id", Value: userID}},
+	}}})
+	if err != nil {
+		log.Error(err, "Error getting follows from database")
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var follow data.Follow
+		err := cursor.Decode(&follow)
+		if err != nil {
+			log.Error(err, "Error decoding follow from database")
+			return err
+		}
+
+		err = export(follow.Relationship())
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// deleteFollowsBetween removes the follows between two users, in both directions
+func (mp *MongoRelationships) deleteFollowsBetween(ctx context.Context, userID1 string, userID2 string) error {
+	for _, users := range [][2]string{{userID1, userID2}, {userID2, userID1}} {
+		err := mp.DeleteFollow(ctx, users[0], users[1])
+		if err != nil && err != data.ErrorFollowNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// incrementFollowCounts updates the counters of both users of a follow
+func (mp *MongoRelationships) incrementFollowCounts(ctx context.Context, followerID string, followedID string, increment int64) error {
+	upsert := options.Update().SetUpsert(true)
+	_, err := mp.followCounts.UpdateOne(ctx, bson.D{{Key: "_id", Value: followerID}}, bson.M{"$inc": bson.M{"following": increment}}, upsert)
+	if err != nil {
+		return err
+	}
+	_, err = mp.followCounts.UpdateOne(ctx, bson.D{{Key: "_id", Value: followedID}}, bson.M{"$inc": bson.M{"followers": increment}}, upsert)
+	return err
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// friendCodeAttempts is the number of codes generated before giving up on collisions
+const friendCodeAttempts = 5
+
+func (mp *MongoRelationships) AddFriendCode(ctx context.Context, friendCode *data.FriendCode) error {
+	if !mp.validateUserExist(friendCode.UserID) {
+		return data.ErrorUserNotFound
+	}
+
+	friendCode.CreatedOn = time.Now().UTC().String()
+
+	var err error
+	for attempt := 0; attempt < friendCodeAttempts; attempt++ {
+		friendCode.Code, err = data.GenerateFriendCode()
+		if err != nil {
+			return err
+		}
+
+		_, err = mp.friendCodes.InsertOne(ctx, friendCode)
+		if !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Info("Inserting friend code", "user_id", friendCode.UserID, "single_use", friendCode.SingleUse)
+	return nil
+}
+
+func (mp *MongoRelationships) GetFriendCodesByUserID(ctx context.Context, userID string) (data.FriendCodes, error) {
+	// MongoDB search filter, expired codes wait for the TTL monitor to remove them
+	filter := bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "expires_at", Value: nil}},
+			bson.D{{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now().UTC()}}}},
+		}},
+	}
+
+	cursor, err := mp.friendCodes.Find(ctx, filter)
+	if err != nil {
+		log.Error(err, "Error getting friend codes from database")
+		return nil, err
+	}
+
+	friendCodes := data.FriendCodes{}
+	err = cursor.All(ctx, &friendCodes)
+	if err != nil {
+		log.Error(err, "Error decoding friend codes from database")
+		return nil, err
+	}
+
+	return friendCodes, nil
+}
+
+func (mp *MongoRelationships) GetFriendCode(ctx context.Context, code string) (*data.FriendCode, error) {
+	friendCode := &data.FriendCode{}
+	err := mp.friendCodes.FindOne(ctx, bson.D{{Key: "_id", Value: code}}).Decode(friendCode)
+	if err == mongo.ErrNoDocuments || (err == nil && friendCode.IsExpired(time.Now().UTC())) {
+		return nil, data.ErrorFriendCodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return friendCode, nil
+}
+
+func (mp *MongoRelationships) DeleteFriendCode(ctx context.Context, code string) error {
+	result, err := mp.friendCodes.DeleteOne(ctx, bson.D{{Key: "_id", Value: code}})
+	if err != nil {
+		log.Error(err, "Error deleting friend code")
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return data.ErrorFriendCodeNotFound
+	}
+	return nil
+}
+
+func (mp *MongoRelationships) RedeemFriendCode(ctx context.Context, code string, userID string) (*data.Relationship, error) {
+	filter := bson.D{{Key: "_id", Value: code}}
+
+	friendCode := &data.FriendCode{}
+	err := mp.friendCodes.FindOne(ctx, filter).Decode(friendCode)
+	if err == nil && friendCode.SingleUse {
+		// Single use codes are consumed atomically so two users can't redeem the same code
+		err = mp.friendCodes.FindOneAndDelete(ctx, filter).Decode(friendCode)
+	}
+	if err == mongo.ErrNoDocuments || (err == nil && friendCode.IsExpired(time.Now().UTC())) {
+		return nil, data.ErrorFriendCodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Sharing a friend code agrees to the friend requests made with it, whatever the privacy settings
+	relationship := friendCode.Relationship(userID)
+	err = mp.addRelationship(ctx, relationship, false)
+	if err != nil {
+		// Give the code back when no relationship was created with it
+		if friendCode.SingleUse {
+			_, insertErr := mp.friendCodes.InsertOne(ctx, friendCode)
+			if insertErr != nil {
+				log.Error(insertErr, "Error restoring single use friend code", "user_id", friendCode.UserID)
+			}
+		}
+		return nil, err
+	}
+
+	return relationship, nil
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (mp *MongoRelationships) AddFriendGroup(ctx context.Context, friendGroup *data.FriendGroup) error {
+	err := mp.validateGroupMembers(ctx, friendGroup)
+	if err != nil {
+		return err
+	}
+
+	friendGroup.ID = uuid.NewString()
+	friendGroup.CreatedOn = time.Now().UTC().String()
+	friendGroup.UpdatedOn = time.Now().UTC().String()
+
+	_, err = mp.friendGroups.InsertOne(ctx, friendGroup)
+	if err != nil {
+		log.Error(err, "Error inserting friend group")
+		return err
+	}
+
+	log.Info("Inserting friend group", "id", friendGroup.ID, "user_id", friendGroup.UserID)
+	return nil
+}
+
+func (mp *MongoRelationships) GetFriendGroupsByUserID(ctx context.Context, userID string) (data.FriendGroups, error) {
+	cursor, err := mp.friendGroups.Find(ctx, bson.D{{Key: "user_id", Value: userID}})
+	if err != nil {
+		log.Error(err, "Error getting friend groups from database")
+		return nil, err
+	}
+
+	friendGroups := data.FriendGroups{}
+	err = cursor.All(ctx, &friendGroups)
+	if err != nil {
+		log.Error(err, "Error decoding friend groups from database")
+		return nil, err
+	}
+
+	return friendGroups, nil
+}
+
+func (mp *MongoRelationships) GetFriendGroup(ctx context.Context, id string) (*data.FriendGroup, error) {
+	friendGroup := &data.FriendGroup{}
+	err := mp.friendGroups.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(friendGroup)
+	if err == mongo.ErrNoDocuments {
+		return nil, data.ErrorFriendGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return friendGroup, nil
+}
+
+func (mp *MongoRelationships) UpdateFriendGroup(ctx context.Context, friendGroup *data.FriendGroup) error {
+	err := mp.validateGroupMembers(ctx, friendGroup)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.D{{Key: "_id", Value: friendGroup.ID}, {Key: "user_id", Value: friendGroup.UserID}}
+	update := bson.M{"$set": bson.M{
+		"name":             friendGroup.Name,
+		"relationship_ids": friendGroup.RelationshipIDs,
+		"updated_on":       time.Now().UTC().String(),
+	}}
+
+	result, err := mp.friendGroups.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Error(err, "Error updating friend group")
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return data.ErrorFriendGroupNotFound
+	}
+	return nil
+}
+
+func (mp *MongoRelationships) DeleteFriendGroup(ctx context.Context, id string) error {
+	result, err := mp.friendGroups.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		log.Error(err, "Error deleting friend group")
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return data.ErrorFriendGroupNotFound
+	}
+	return nil
+}
+
+// validateGroupMembers makes sure every member of the friend group is a friendship of its owner
+func (mp *MongoRelationships) validateGroupMembers(ctx context.Context, friendGroup *data.FriendGroup) error {
+	if len(friendGroup.RelationshipIDs) == 0 {
+		return nil
+	}
+
+	filter := append(bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: friendGroup.RelationshipIDs}}}}, userFriendshipsFilter(friendGroup.UserID)...)
+	count, err := mp.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	// Relationship IDs are unique within a group, see FriendGroup validation
+	if count != int64(len(friendGroup.RelationshipIDs)) {
+		return data.ErrorInvalidGroupMember
+	}
+	return nil
+}
+
+// removeFromFriendGroups removes a deleted relationship from the friend groups referencing it
+func (mp *MongoRelationships) removeFromFriendGroups(ctx context.Context, relationshipID string) error {
+	filter := bson.D{{Key: "relationship_ids", Value: relationshipID}}
+	update := bson.M{"$pull": bson.M{"relationship_ids": relationshipID}}
+	_, err := mp.friendGroups.UpdateMany(ctx, filter, update)
+	return err
+}
@@ -0,0 +1,276 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opencensus.io/stats"
+)
+
+// outboxBatchSize bounds the entries relayed in one pass
+const outboxBatchSize = 100
+
+// outboxLease is how long a replica relays the outbox before another replica can take over
+const outboxLease = 30 * time.Second
+
+// outboxEntry is an event waiting in the outbox to be published
+// Entries of a pair of users are numbered so they are published in the order of the changes
+type outboxEntry struct {
+	ID        string        `bson:"_id"`
+	PairKey   string        `bson:"pair_key"`
+	Sequence  int64         `bson:"sequence"`
+	Event     *events.Event `bson:"event"`
+	CreatedOn time.Time     `bson:"created_on"`
+	Attempts  int           `bson:"attempts"`
+	LastError string        `bson:"last_error,omitempty"`
+}
+
+// pairKey identifies the pair of users of a relationship, whatever their order
+func pairKey(userID1 string, userID2 string) string {
+	if userID2 < userID1 {
+		userID1, userID2 = userID2, userID1
+	}
+	return userID1 + ":" + userID2
+}
+
+// supportsTransactions returns true when the MongoDB deployment is a replica set or a sharded cluster
+func supportsTransactions(ctx context.Context, client *mongo.Client) bool {
+	result := struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}{}
+	err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&result)
+	if err != nil {
+		log.Error(err, "Error checking MongoDB deployment")
+		return false
+	}
+	return result.SetName != "" || result.Msg == "isdbgrid"
+}
+
+// withOutbox runs the write of a relationship change and saves the events of the change in the outbox, in the same transaction
+// write returns the relationship before and after the change, both nil when nothing changed
+func (mp *MongoRelationships) withOutbox(ctx context.Context, write func(ctx context.Context) (*data.Relationship, *data.Relationship, error)) error {
+	return mp.withEvents(ctx, func(ctx context.Context) (events.Events, error) {
+		previous, current, err := write(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return events.FromChange(previous, current), nil
+	})
+}
+
+// withEvents runs a write and saves the events it returns in the outbox, in the same transaction
+// Without transaction support, the events are saved right after the write and can be lost if the service stops in between
+func (mp *MongoRelationships) withEvents(ctx context.Context, write func(ctx context.Context) (events.Events, error)) error {
+	return mp.withTransaction(ctx, func(ctx context.Context) error {
+		writeEvents, err := write(ctx)
+		if err != nil {
+			return err
+		}
+		return mp.addToOutbox(ctx, writeEvents)
+	})
+}
+
+// withTransaction runs the writes in a transaction when the deployment supports them, one after the other otherwise
+func (mp *MongoRelationships) withTransaction(ctx context.Context, write func(ctx context.Context) error) error {
+	if !mp.transactions {
+		return write(ctx)
+	}
+
+	session, err := mp.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return nil, write(sessionContext)
+	})
+	return err
+}
+
+// addToOutbox saves the events of a change, numbered after the previous events of each pair of users
+func (mp *MongoRelationships) addToOutbox(ctx context.Context, changeEvents events.Events) error {
+	keys := []string{}
+	pairEvents := map[string]events.Events{}
+	for _, event := range changeEvents {
+		key := pairKey(event.UserID, event.OtherUserID)
+		if _, ok := pairEvents[key]; !ok {
+			keys = append(keys, key)
+		}
+		pairEvents[key] = append(pairEvents[key], event)
+	}
+
+	for _, key := range keys {
+		err := mp.addPairToOutbox(ctx, key, pairEvents[key])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addPairToOutbox saves the events of a pair of users, numbered after the previous events of the pair
+func (mp *MongoRelationships) addPairToOutbox(ctx context.Context, key string, changeEvents events.Events) error {
+	counter := struct {
+		Sequence int64 `bson:"sequence"`
+	}{}
+	err := mp.outboxSequences.FindOneAndUpdate(ctx,
+		bson.D{{Key: "_id", Value: key}},
+		bson.M{"$inc": bson.M{"sequence": len(changeEvents)}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]interface{}, 0, len(changeEvents))
+	for i, event := range changeEvents {
+		entries = append(entries, &outboxEntry{
+			ID:        event.ID,
+			PairKey:   key,
+			Sequence:  counter.Sequence - int64(len(changeEvents)-1-i),
+			Event:     event,
+			CreatedOn: time.Now().UTC(),
+		})
+	}
+	_, err = mp.outbox.InsertMany(ctx, entries)
+	return err
+}
+
+// RelayOutbox publishes the events waiting in the outbox, in order for each pair of users
+// An event is removed once published, so it can be published again if the service stops in between
+// Only the replica holding the relay lease publishes, the other ones return right away
+func (mp *MongoRelationships) RelayOutbox(ctx context.Context) (int, error) {
+	leader, err := mp.acquireOutboxLease(ctx)
+	if err != nil || !leader {
+		return 0, err
+	}
+
+	// Pairs of users with the oldest waiting events
+	cursor, err := mp.outbox.Find(ctx, bson.D{}, options.Find().
+		SetSort(bson.D{{Key: "created_on", Value: 1}}).
+		SetLimit(outboxBatchSize).
+		SetProjection(bson.D{{Key: "pair_key", Value: 1}}))
+	if err != nil {
+		return 0, err
+	}
+	oldest := []*outboxEntry{}
+	err = cursor.All(ctx, &oldest)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	relayedPairs := map[string]bool{}
+	for _, entry := range oldest {
+		if relayedPairs[entry.PairKey] {
+			continue
+		}
+		relayedPairs[entry.PairKey] = true
+
+		count, err := mp.relayPair(ctx, entry.PairKey)
+		published += count
+		if err != nil {
+			return published, err
+		}
+	}
+
+	mp.recordOutboxLag(ctx)
+	return published, nil
+}
+
+// relayPair publishes the waiting events of a pair of users in order, stopping at the first event that fails
+func (mp *MongoRelationships) relayPair(ctx context.Context, key string) (int, error) {
+	cursor, err := mp.outbox.Find(ctx, bson.D{{Key: "pair_key", Value: key}}, options.Find().
+		SetSort(bson.D{{Key: "sequence", Value: 1}}).
+		SetLimit(outboxBatchSize))
+	if err != nil {
+		return 0, err
+	}
+	entries := []*outboxEntry{}
+	err = cursor.All(ctx, &entries)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, entry := range entries {
+		err = mp.publisher.Publish(ctx, entry.Event)
+		if err != nil {
+			stats.Record(ctx, outboxPublishErrors.M(1))
+			log.Error(err, "Error publishing event from the outbox, retrying later", "id", entry.ID, "attempts", entry.Attempts+1)
+			_, updateErr := mp.outbox.UpdateOne(ctx, bson.D{{Key: "_id", Value: entry.ID}}, bson.M{
+				"$inc": bson.M{"attempts": 1},
+				"$set": bson.M{"last_error": err.Error()},
+			})
+			if updateErr != nil {
+				log.Error(updateErr, "Error saving outbox publication attempt", "id", entry.ID)
+			}
+			return i, nil
+		}
+		stats.Record(ctx, outboxDeliveryLag.M(time.Since(entry.Event.OccurredAt).Seconds()))
+
+		_, err = mp.outbox.DeleteOne(ctx, bson.D{{Key: "_id", Value: entry.ID}})
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(entries), nil
+}
+
+// acquireOutboxLease returns true when this replica holds the relay lease, taking it when it is free or expired
+func (mp *MongoRelationships) acquireOutboxLease(ctx context.Context) (bool, error) {
+	now := time.Now().UTC()
+	filter := bson.D{
+		{Key: "_id", Value: "relay"},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "owner", Value: mp.replicaID}},
+			bson.D{{Key: "expires_at", Value: bson.D{{Key: "$lt", Value: now}}}},
+		}},
+	}
+	update := bson.M{"$set": bson.M{"owner": mp.replicaID, "expires_at": now.Add(outboxLease)}}
+
+	_, err := mp.outboxLeases.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// Another replica holds the lease
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// recordOutboxLag records the number of waiting events and the age of the oldest one
+func (mp *MongoRelationships) recordOutboxLag(ctx context.Context) {
+	pending, err := mp.outbox.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		log.Error(err, "Error counting outbox events")
+		return
+	}
+
+	lag := 0.0
+	oldest := &outboxEntry{}
+	err = mp.outbox.FindOne(ctx, bson.D{}, options.FindOne().SetSort(bson.D{{Key: "created_on", Value: 1}})).Decode(oldest)
+	if err == nil {
+		lag = time.Since(oldest.CreatedOn).Seconds()
+	} else if err != mongo.ErrNoDocuments {
+		log.Error(err, "Error finding oldest outbox event")
+	}
+
+	stats.Record(ctx, outboxPending.M(pending), outboxLag.M(lag))
+}
+
+// createCollection creates a collection before it is used in a transaction, an existing collection is kept
+func createCollection(ctx context.Context, database *mongo.Database, name string) error {
+	err := database.CreateCollection(ctx, name)
+	var commandError mongo.CommandError
+	if errors.As(err, &commandError) && (commandError.Code == 48 || strings.Contains(commandError.Message, "already exists")) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (mp *MongoRelationships) SetPresence(ctx context.Context, presence *data.Presence) error {
+	presence.ExpiresAt = presence.UpdatedOn.Add(mp.presenceTTL)
+
+	// Only replaces an older status, the upsert of an update received late conflicts with the newer status
+	filter := bson.D{
+		{Key: "_id", Value: presence.UserID},
+		{Key: "updated_on", Value: bson.D{{Key: "$lte", Value: presence.UpdatedOn}}},
+	}
+	_, err := mp.presence.ReplaceOne(ctx, filter, presence, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	if err != nil {
+		log.Error(err, "Error updating presence")
+	}
+	return err
+}
+
+func (mp *MongoRelationships) GetPresenceStatuses(ctx context.Context, userIDs []string) (map[string]string, error) {
+	statuses := make(map[string]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return statuses, nil
+	}
+
+	// Expired statuses are skipped, MongoDB removes them on its own schedule
+	filter := bson.D{
+		{Key: "_id", Value: bson.D{{Key: "$in", Value: userIDs}}},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now().UTC()}}},
+	}
+	cursor, err := mp.presence.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	presences := []*data.Presence{}
+	err = cursor.All(ctx, &presences)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, presence := range presences {
+		statuses[presence.UserID] = presence.Status
+	}
+	return statuses, nil
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (mp *MongoRelationships) AddMatchRoster(ctx context.Context, matchRoster *data.MatchRoster) error {
+	expiresAt := matchRoster.PlayedAt.Add(mp.recentPlayersTTL)
+	models := make([]mongo.WriteModel, 0, len(matchRoster.UserIDs)*(len(matchRoster.UserIDs)-1))
+	for _, userID := range matchRoster.UserIDs {
+		for _, playerID := range matchRoster.UserIDs {
+			if userID == playerID {
+				continue
+			}
+			filter := bson.D{{Key: "_id", Value: recentPlayerID(userID, playerID)}}
+			models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(recentPlayerUpdate(userID, playerID, matchRoster, expiresAt)).SetUpsert(true))
+		}
+	}
+
+	_, err := mp.recentPlayers.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if retries := duplicateKeyWrites(models, err); len(retries) > 0 {
+		// Two rosters inserted the same co-player at once, the update finds the inserted co-player this time
+		_, err = mp.recentPlayers.BulkWrite(ctx, retries, options.BulkWrite().SetOrdered(false))
+	}
+	if err != nil {
+		log.Error(err, "Error adding match roster")
+		return err
+	}
+	return nil
+}
+
+func (mp *MongoRelationships) GetRecentPlayersByUserID(ctx context.Context, userID string, offset int, limit int) (data.RecentPlayers, error) {
+	// Expired co-players are skipped, MongoDB removes them on its own schedule
+	filter := bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now().UTC()}}},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "last_played_on", Value: -1}, {Key: "_id", Value: 1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+	cursor, err := mp.recentPlayers.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	recentPlayers := data.RecentPlayers{}
+	err = cursor.All(ctx, &recentPlayers)
+	if err != nil {
+		return nil, err
+	}
+	if len(recentPlayers) == 0 {
+		return recentPlayers, nil
+	}
+
+	playerIDs := make([]string, 0, len(recentPlayers))
+	for _, recentPlayer := range recentPlayers {
+		playerIDs = append(playerIDs, recentPlayer.PlayerID)
+	}
+	frozenUserIDs, err := mp.frozenUserIDs(ctx, playerIDs)
+	if err != nil {
+		return nil, err
+	}
+	return withoutFrozenPlayers(recentPlayers, frozenUserIDs), nil
+}
+
+func (mp *MongoRelationships) GetFriendSuggestions(ctx context.Context, userID string, limit int) (data.FriendSuggestions, error) {
+	return suggestFriends(ctx, mp, userID, limit)
+}
+
+// recentPlayerUpdate counts the match for a co-player of the user, unless it was already counted when the roster was sent before
+func recentPlayerUpdate(userID string, playerID string, matchRoster *data.MatchRoster, expiresAt time.Time) mongo.Pipeline {
+	matchIDs := bson.D{{Key: "$ifNull", Value: bson.A{"$match_ids", bson.A{}}}}
+	counted := bson.D{{Key: "$in", Value: bson.A{matchRoster.MatchID, matchIDs}}}
+	return mongo.Pipeline{bson.D{{Key: "$set", Value: bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "player_id", Value: playerID},
+		{Key: "matches", Value: bson.D{{Key: "$cond", Value: bson.A{
+			counted,
+			"$matches",
+			bson.D{{Key: "$add", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$matches", 0}}}, 1}}},
+		}}}},
+		{Key: "match_ids", Value: bson.D{{Key: "$cond", Value: bson.A{
+			counted,
+			"$match_ids",
+			bson.D{{Key: "$slice", Value: bson.A{
+				bson.D{{Key: "$concatArrays", Value: bson.A{matchIDs, bson.A{matchRoster.MatchID}}}},
+				-recentMatchIDs,
+			}}},
+		}}}},
+		{Key: "last_played_on", Value: bson.D{{Key: "$max", Value: bson.A{"$last_played_on", matchRoster.PlayedAt}}}},
+		{Key: "expires_at", Value: bson.D{{Key: "$max", Value: bson.A{"$expires_at", expiresAt}}}},
+	}}}}
+}
+
+// duplicateKeyWrites returns the writes of a bulk write that failed on a duplicate key, or nil when another error happened
+func duplicateKeyWrites(models []mongo.WriteModel, err error) []mongo.WriteModel {
+	bulkErr, ok := err.(mongo.BulkWriteException)
+	if !ok || bulkErr.WriteConcernError != nil || len(bulkErr.WriteErrors) == 0 {
+		return nil
+	}
+	retries := make([]mongo.WriteModel, 0, len(bulkErr.WriteErrors))
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != 11000 {
+			return nil
+		}
+		retries = append(retries, models[writeErr.Index])
+	}
+	return retries
+}
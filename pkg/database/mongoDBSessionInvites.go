@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (mp *MongoRelationships) AddSessionInvite(ctx context.Context, sessionInvite *data.SessionInvite) error {
+	if sessionInvite.HostID == sessionInvite.InviteeID {
+		return data.ErrorSameUserID
+	}
+	err := mp.checkSessionFriends(ctx, sessionInvite)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	pending, err := mp.sessionInvites.CountDocuments(ctx, bson.D{
+		{Key: "session_id", Value: sessionInvite.SessionID},
+		{Key: "invitee_id", Value: sessionInvite.InviteeID},
+		{Key: "status", Value: data.SessionInvitePending},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now}}},
+	})
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return data.ErrorSessionInviteExist
+	}
+
+	sessionInvite.ID = uuid.NewString()
+	sessionInvite.Status = data.SessionInvitePending
+	sessionInvite.CreatedOn = now
+	sessionInvite.UpdatedOn = now
+	sessionInvite.ExpiresAt = now.Add(mp.sessionInviteTTL)
+	return mp.withEvents(ctx, func(ctx context.Context) (events.Events, error) {
+		_, err := mp.sessionInvites.InsertOne(ctx, sessionInvite)
+		if err != nil {
+			log.Error(err, "Error adding session invite")
+			return nil, err
+		}
+		return events.Events{events.FromSessionInvite(events.SessionInviteSent, sessionInvite, sessionInvite.HostID)}, nil
+	})
+}
+
+func (mp *MongoRelationships) GetSessionInvite(ctx context.Context, id string) (*data.SessionInvite, error) {
+	sessionInvite := &data.SessionInvite{}
+	err := mp.sessionInvites.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(sessionInvite)
+	if err == mongo.ErrNoDocuments {
+		return nil, data.ErrorSessionInviteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sessionInvite, nil
+}
+
+func (mp *MongoRelationships) GetSessionInvitesByUserID(ctx context.Context, userID string) (data.SessionInvites, error) {
+	filter := bson.D{
+		{Key: "invitee_id", Value: userID},
+		{Key: "status", Value: data.SessionInvitePending},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now().UTC()}}},
+	}
+	cursor, err := mp.sessionInvites.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_on", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	sessionInvites := data.SessionInvites{}
+	err = cursor.All(ctx, &sessionInvites)
+	if err != nil {
+		return nil, err
+	}
+	return sessionInvites, nil
+}
+
+func (mp *MongoRelationships) AnswerSessionInvite(ctx context.Context, id string, userID string, accept bool) (*data.SessionInvite, error) {
+	sessionInvite, err := mp.GetSessionInvite(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	eventType, err := answerSessionInvite(sessionInvite, userID, accept, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	// Joining requires the users to still be friends, declining doesn't
+	if accept {
+		err = mp.checkSessionFriends(ctx, sessionInvite)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = mp.withEvents(ctx, func(ctx context.Context) (events.Events, error) {
+		filter := bson.D{{Key: "_id", Value: id}, {Key: "status", Value: data.SessionInvitePending}}
+		update := bson.M{"$set": bson.M{"status": sessionInvite.Status, "updated_on": sessionInvite.UpdatedOn}}
+		result, err := mp.sessionInvites.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return nil, err
+		}
+		// Answered by another request in the meantime
+		if result.MatchedCount == 0 {
+			return nil, data.ErrorSessionInviteAnswered
+		}
+		return events.Events{events.FromSessionInvite(eventType, sessionInvite, userID)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sessionInvite, nil
+}
+
+// checkSessionFriends refuses session invites between users who aren't friends
+func (mp *MongoRelationships) checkSessionFriends(ctx context.Context, sessionInvite *data.SessionInvite) error {
+	relationship, err := mp.findRelationshipByUserIDs(ctx, sessionInvite.HostID, sessionInvite.InviteeID)
+	if err != nil {
+		return err
+	}
+	err = checkFriends(relationship)
+	if err != nil {
+		return err
+	}
+	return mp.checkNotFrozen(ctx, relationship)
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (mp *MongoRelationships) GetUserSettings(ctx context.Context, userID string) (*data.UserSettings, error) {
+	settings := &data.UserSettings{}
+	err := mp.settings.FindOne(ctx, bson.D{{Key: "_id", Value: userID}}).Decode(settings)
+	if err == mongo.ErrNoDocuments {
+		return data.DefaultUserSettings(userID), nil
+	}
+	if err != nil {
+		log.Error(err, "Error getting user settings from database")
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (mp *MongoRelationships) UpdateUserSettings(ctx context.Context, settings *data.UserSettings) error {
+	if !mp.validateUserExist(settings.UserID) {
+		return data.ErrorUserNotFound
+	}
+
+	settings.UpdatedOn = time.Now().UTC().String()
+
+	filter := bson.D{{Key: "_id", Value: settings.UserID}}
+	_, err := mp.settings.ReplaceOne(ctx, filter, settings, options.Replace().SetUpsert(true))
+	if err != nil {
+		log.Error(err, "Error updating user settings")
+	}
+	return err
+}
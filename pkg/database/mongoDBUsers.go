@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// bannedUser is the ban state of a user, the relationships are frozen while Frozen is set
+// Records stored before the unban events were tracked have no frozen field and are banned
+type bannedUser struct {
+	UserID    string    `bson:"_id"`
+	Frozen    bool      `bson:"frozen"`
+	BannedOn  time.Time `bson:"banned_on,omitempty"`
+	UpdatedOn time.Time `bson:"updated_on"` // time of the last ban or unban event applied
+}
+
+// cachedUserDetails are the details of a user fetched from microservice-user, shared by the replicas
+type cachedUserDetails struct {
+	UserID   string    `bson:"_id"`
+	Username string    `bson:"username"`
+	Status   string    `bson:"status"`
+	CachedOn time.Time `bson:"cached_on"`
+}
+
+// DeleteUserRelationships deletes every relationship of a deleted account with their events,
+// then its follows, settings, presence, feed, session invites, co-players, affinities and their counted signals, friend codes and friend groups
+func (mp *MongoRelationships) DeleteUserRelationships(ctx context.Context, userID string) (int64, error) {
+	cursor, err := mp.collection.Find(ctx, userRelationshipsFilter(userID))
+	if err != nil {
+		return 0, err
+	}
+	relationships := data.Relationships{}
+	err = cursor.All(ctx, &relationships)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, relationship := range relationships {
+		err = mp.DeleteRelationship(ctx, relationship.ID)
+		if err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	// Follows are deleted one by one to keep the counters of the other users right
+	cursor, err = mp.follows.Find(ctx, bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "follower_id", Value: userID}},
+		bson.D{{Key: "followed_id", Value: userID}},
+	}}})
+	if err != nil {
+		return deleted, err
+	}
+	follows := data.Follows{}
+	err = cursor.All(ctx, &follows)
+	if err != nil {
+		return deleted, err
+	}
+	for _, follow := range follows {
+		err = mp.DeleteFollow(ctx, follow.FollowerID, follow.FollowedID)
+		if err != nil && err != data.ErrorFollowNotFound {
+			return deleted, err
+		}
+	}
+
+	userFilter := bson.D{{Key: "_id", Value: userID}}
+	ownerFilter := bson.D{{Key: "user_id", Value: userID}}
+	for _, cleanup := range []struct {
+		collection *mongo.Collection
+		filter     bson.D
+	}{
+		{mp.followCounts, userFilter},
+		{mp.settings, userFilter},
+		{mp.bannedUsers, userFilter},
+		{mp.userDetails, userFilter},
+		{mp.presence, userFilter},
+		{mp.feed, bson.D{{Key: "$or", Value: bson.A{ownerFilter, bson.D{{Key: "actor_id", Value: userID}}, bson.D{{Key: "subject_id", Value: userID}}}}}},
+		{mp.sessionInvites, bson.D{{Key: "$or", Value: bson.A{bson.D{{Key: "host_id", Value: userID}}, bson.D{{Key: "invitee_id", Value: userID}}}}}},
+		{mp.recentPlayers, bson.D{{Key: "$or", Value: bson.A{ownerFilter, bson.D{{Key: "player_id", Value: userID}}}}}},
+		{mp.affinities, bson.D{{Key: "user_ids", Value: userID}}},
+		{mp.countedSignals, bson.D{{Key: "user_ids", Value: userID}}},
+		{mp.friendCodes, ownerFilter},
+		{mp.friendGroups, ownerFilter},
+	} {
+		_, err = cleanup.collection.DeleteMany(ctx, cleanup.filter)
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+func (mp *MongoRelationships) SetUserFrozen(ctx context.Context, userID string, frozen bool, occurredAt time.Time) error {
+	banned := &bannedUser{UserID: userID, Frozen: frozen, UpdatedOn: occurredAt}
+	if frozen {
+		banned.BannedOn = occurredAt
+	}
+
+	// Only replaces the state of an older event, the upsert of an event received late conflicts with the newer state
+	filter := bson.D{
+		{Key: "_id", Value: userID},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "updated_on", Value: bson.D{{Key: "$lte", Value: occurredAt}}}},
+			bson.D{{Key: "updated_on", Value: bson.D{{Key: "$exists", Value: false}}}},
+		}},
+	}
+	_, err := mp.bannedUsers.ReplaceOne(ctx, filter, banned, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// Another event inserted the state first, it is replaced only when it is older
+		_, err = mp.bannedUsers.ReplaceOne(ctx, filter, banned)
+	}
+	return err
+}
+
+func (mp *MongoRelationships) InvalidateUserDetails(ctx context.Context, userID string) error {
+	_, err := mp.userDetails.DeleteOne(ctx, bson.D{{Key: "_id", Value: userID}})
+	return err
+}
+
+// checkNotFrozen refuses changes to the relationships of banned users
+func (mp *MongoRelationships) checkNotFrozen(ctx context.Context, relationship *data.Relationship) error {
+	frozenUserIDs, err := mp.frozenUserIDs(ctx, []string{relationship.User1.UserID, relationship.User2.UserID})
+	if err != nil {
+		return err
+	}
+	if len(frozenUserIDs) > 0 {
+		return data.ErrorUserFrozen
+	}
+	return nil
+}
+
+// withoutFrozen removes the relationships of the user with banned users
+func (mp *MongoRelationships) withoutFrozen(ctx context.Context, userID string, relationships data.Relationships) (data.Relationships, error) {
+	if len(relationships) == 0 {
+		return relationships, nil
+	}
+	frozenUserIDs, err := mp.frozenUserIDs(ctx, otherUserIDs(userID, relationships))
+	if err != nil {
+		return nil, err
+	}
+	return withoutFrozen(userID, relationships, frozenUserIDs), nil
+}
+
+// frozenUserIDs returns the banned users among the users
+func (mp *MongoRelationships) frozenUserIDs(ctx context.Context, userIDs []string) (map[string]bool, error) {
+	filter := bson.D{
+		{Key: "_id", Value: bson.D{{Key: "$in", Value: userIDs}}},
+		{Key: "frozen", Value: bson.D{{Key: "$ne", Value: false}}},
+	}
+	cursor, err := mp.bannedUsers.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	banned := []*bannedUser{}
+	err = cursor.All(ctx, &banned)
+	if err != nil {
+		return nil, err
+	}
+
+	frozenUserIDs := map[string]bool{}
+	for _, user := range banned {
+		frozenUserIDs[user.UserID] = true
+	}
+	return frozenUserIDs, nil
+}
+
+// cachedUser returns the cached details of a user, or nil when they aren't cached or are too old
+func (mp *MongoRelationships) cachedUser(ctx context.Context, userID string) *data.DetailedUser {
+	if mp.userDetailsTTL == 0 {
+		return nil
+	}
+	filter := bson.D{
+		{Key: "_id", Value: userID},
+		{Key: "cached_on", Value: bson.D{{Key: "$gt", Value: time.Now().UTC().Add(-mp.userDetailsTTL)}}},
+	}
+	cached := &cachedUserDetails{}
+	err := mp.userDetails.FindOne(ctx, filter).Decode(cached)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Error(err, "Error reading cached user details", "user_id", userID)
+		}
+		return nil
+	}
+	return &data.DetailedUser{ID: cached.UserID, Username: cached.Username, Status: cached.Status}
+}
+
+// cacheUser saves the details of a user fetched from microservice-user, a failure only costs another fetch
+func (mp *MongoRelationships) cacheUser(ctx context.Context, user *data.DetailedUser) {
+	if mp.userDetailsTTL == 0 {
+		return
+	}
+	cached := &cachedUserDetails{UserID: user.ID, Username: user.Username, Status: user.Status, CachedOn: time.Now().UTC()}
+	_, err := mp.userDetails.ReplaceOne(ctx, bson.D{{Key: "_id", Value: user.ID}}, cached, options.Replace().SetUpsert(true))
+	if err != nil {
+		log.Error(err, "Error caching user details", "user_id", user.ID)
+	}
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (mp *MongoRelationships) AddWebhook(ctx context.Context, webhook *data.Webhook) error {
+	webhook.ID = uuid.NewString()
+	webhook.CreatedOn = time.Now().UTC().String()
+	webhook.UpdatedOn = time.Now().UTC().String()
+
+	_, err := mp.webhooks.InsertOne(ctx, webhook)
+	if err != nil {
+		log.Error(err, "Error inserting webhook")
+		return err
+	}
+
+	log.Info("Inserting webhook", "id", webhook.ID, "url", webhook.URL)
+	return nil
+}
+
+func (mp *MongoRelationships) GetWebhooks(ctx context.Context) (data.Webhooks, error) {
+	cursor, err := mp.webhooks.Find(ctx, bson.D{})
+	if err != nil {
+		log.Error(err, "Error getting webhooks from database")
+		return nil, err
+	}
+
+	webhooks := data.Webhooks{}
+	err = cursor.All(ctx, &webhooks)
+	if err != nil {
+		log.Error(err, "Error decoding webhooks from database")
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (mp *MongoRelationships) GetWebhook(ctx context.Context, id string) (*data.Webhook, error) {
+	webhook := &data.Webhook{}
+	err := mp.webhooks.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(webhook)
+	if err == mongo.ErrNoDocuments {
+		return nil, data.ErrorWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (mp *MongoRelationships) UpdateWebhook(ctx context.Context, webhook *data.Webhook) error {
+	filter := bson.D{{Key: "_id", Value: webhook.ID}}
+	update := bson.M{"$set": bson.M{
+		"url":         webhook.URL,
+		"event_types": webhook.EventTypes,
+		"secret":      webhook.Secret,
+		"updated_on":  time.Now().UTC().String(),
+	}}
+
+	result, err := mp.webhooks.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Error(err, "Error updating webhook")
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return data.ErrorWebhookNotFound
+	}
+	return nil
+}
+
+func (mp *MongoRelationships) DeleteWebhook(ctx context.Context, id string) error {
+	result, err := mp.webhooks.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		log.Error(err, "Error deleting webhook")
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return data.ErrorWebhookNotFound
+	}
+
+	// The pending and dead deliveries of a deleted webhook can't be sent anymore
+	_, err = mp.deliveries.DeleteMany(ctx, bson.D{{Key: "webhook_id", Value: id}})
+	if err != nil {
+		log.Error(err, "Error deleting webhook deliveries", "webhook_id", id)
+		return err
+	}
+	return nil
+}
+
+func (mp *MongoRelationships) AddWebhookDeliveries(ctx context.Context, deliveries data.WebhookDeliveries) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		documents = append(documents, delivery)
+	}
+	// Deliveries saved by a previous publication of the event are kept, the other deliveries are still inserted
+	_, err := mp.deliveries.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		log.Error(err, "Error inserting webhook deliveries")
+		return err
+	}
+	return nil
+}
+
+// ClaimWebhookDeliveries claims the pending deliveries due for an attempt one at a time,
+// pushing back their next attempt so other replicas don't send them concurrently
+func (mp *MongoRelationships) ClaimWebhookDeliveries(ctx context.Context, limit int) (data.WebhookDeliveries, error) {
+	deliveries := data.WebhookDeliveries{}
+	for len(deliveries) < limit {
+		now := time.Now().UTC()
+		filter := bson.D{
+			{Key: "status", Value: data.WebhookDeliveryPending},
+			{Key: "next_attempt_at", Value: bson.D{{Key: "$lte", Value: now}}},
+		}
+		update := bson.M{"$set": bson.M{"next_attempt_at": now.Add(webhookClaimTimeout)}}
+		opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}})
+
+		delivery := &data.WebhookDelivery{}
+		err := mp.deliveries.FindOneAndUpdate(ctx, filter, update, opts).Decode(delivery)
+		if err == mongo.ErrNoDocuments {
+			break
+		}
+		if err != nil {
+			log.Error(err, "Error claiming webhook delivery")
+			return deliveries, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+func (mp *MongoRelationships) UpdateWebhookDelivery(ctx context.Context, delivery *data.WebhookDelivery) error {
+	filter := bson.D{{Key: "_id", Value: delivery.ID}}
+	update := bson.M{"$set": bson.M{
+		"status":           delivery.Status,
+		"attempts":         delivery.Attempts,
+		"next_attempt_at":  delivery.NextAttemptAt,
+		"last_error":       delivery.LastError,
+		"last_status_code": delivery.LastStatusCode,
+	}}
+
+	_, err := mp.deliveries.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Error(err, "Error updating webhook delivery")
+		return err
+	}
+	return nil
+}
+
+func (mp *MongoRelationships) DeleteWebhookDelivery(ctx context.Context, id string) error {
+	_, err := mp.deliveries.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		log.Error(err, "Error deleting webhook delivery")
+		return err
+	}
+	return nil
+}
+
+func (mp *MongoRelationships) GetDeadWebhookDeliveries(ctx context.Context, webhookID string) (data.WebhookDeliveries, error) {
+	filter := bson.D{{Key: "webhook_id", Value: webhookID}, {Key: "status", Value: data.WebhookDeliveryDead}}
+	cursor, err := mp.deliveries.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_on", Value: 1}}))
+	if err != nil {
+		log.Error(err, "Error getting dead webhook deliveries from database")
+		return nil, err
+	}
+
+	deliveries := data.WebhookDeliveries{}
+	err = cursor.All(ctx, &deliveries)
+	if err != nil {
+		log.Error(err, "Error decoding dead webhook deliveries from database")
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (mp *MongoRelationships) ReplayWebhookDeliveries(ctx context.Context, webhookID string, deliveryIDs []string) (int64, error) {
+	filter := bson.D{{Key: "webhook_id", Value: webhookID}, {Key: "status", Value: data.WebhookDeliveryDead}}
+	if len(deliveryIDs) > 0 {
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$in", Value: deliveryIDs}}})
+	}
+	update := bson.M{"$set": bson.M{
+		"status":          data.WebhookDeliveryPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now().UTC(),
+	}}
+
+	result, err := mp.deliveries.UpdateMany(ctx, filter, update)
+	if err != nil {
+		log.Error(err, "Error replaying webhook deliveries")
+		return 0, err
+	}
+
+	log.Info("Replaying webhook deliveries", "webhook_id", webhookID, "replay_count", result.ModifiedCount)
+	return result.ModifiedCount, nil
+}
@@ -2,10 +2,13 @@ package database
 
 import (
 	"context"
+	"errors"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
 )
 
 func integrationTestSetup(t *testing.T) {
@@ -36,7 +39,7 @@ func TestMongoDBConnectionAndShutdownIntegration(t *testing.T) {
 	}
 	integrationTestSetup(t)
 
-	mp := NewMongoRelationships()
+	mp := NewMongoRelationships(events.NewMemoryBroker())
 	if mp == nil {
 		t.Fail()
 	}
@@ -55,7 +58,7 @@ func TestMongoDBAddRelationshipIntegration(t *testing.T) {
 		ConversationID: "",
 	}
 
-	mp := NewMongoRelationships()
+	mp := NewMongoRelationships(events.NewMemoryBroker())
 	err := mp.AddRelationship(context.Background(), relationship)
 	if err != nil {
 		t.Errorf("Failed to add relationship to database")
@@ -76,7 +79,7 @@ func TestMongoDBUpdateRelationshipIntegration(t *testing.T) {
 		ConversationID: "",
 	}
 
-	mp := NewMongoRelationships()
+	mp := NewMongoRelationships(events.NewMemoryBroker())
 	err := mp.UpdateRelationship(context.Background(), relationship)
 	if err != nil {
 		t.Fail()
@@ -90,7 +93,7 @@ func TestMongoDBGetFriendsListByUserIDIntegration(t *testing.T) {
 	}
 	integrationTestSetup(t)
 
-	mp := NewMongoRelationships()
+	mp := NewMongoRelationships(events.NewMemoryBroker())
 	_, err := mp.GetFriendsListByUserID(context.Background(), "a2181017-5c53-422b-b6bc-036b27c04fc8")
 	if err != nil {
 		t.Fail()
@@ -105,7 +108,7 @@ func TestMongoDBGetInvitesListByUserIDIntegration(t *testing.T) {
 	}
 	integrationTestSetup(t)
 
-	mp := NewMongoRelationships()
+	mp := NewMongoRelationships(events.NewMemoryBroker())
 	_, err := mp.GetInvitesListByUserID(context.Background(), "e2382ea2-b5fa-4506-aa9d-d338aa52af84")
 	if err != nil {
 		t.Fail()
@@ -113,3 +116,645 @@ func TestMongoDBGetInvitesListByUserIDIntegration(t *testing.T) {
 
 	mp.CloseDB()
 }
+
+func TestMongoDBExportRelationshipsByUserIDIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	err := mp.ExportRelationshipsByUserID(context.Background(), "a2181017-5c53-422b-b6bc-036b27c04fc8", func(relationship *data.Relationship) error {
+		return nil
+	})
+	if err != nil {
+		t.Fail()
+	}
+
+	mp.CloseDB()
+}
+
+func TestMongoDBMergeUserRelationshipsIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	_, err := mp.MergeUserRelationships(context.Background(), "e2382ea2-b5fa-4506-aa9d-d338aa52af45", "a2181017-5c53-422b-b6bc-036b27c04fc8")
+	if err != nil {
+		t.Fail()
+	}
+
+	mp.CloseDB()
+}
+
+func TestMongoDBDeleteExpiredInvitesIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	_, err := mp.DeleteExpiredInvites(context.Background())
+	if err != nil {
+		t.Fail()
+	}
+
+	mp.CloseDB()
+}
+
+func TestMongoDBAddRelationshipOverInviteQuotaIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+	os.Setenv("INVITE_MAX_PER_HOUR", "1")
+	defer os.Unsetenv("INVITE_MAX_PER_HOUR")
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	for _, receiver := range []string{"e2382ea2-b5fa-4506-aa9d-d338aa52af45", "e2382ea2-b5fa-4506-aa9d-d338aa52af46"} {
+		relationship := &data.Relationship{
+			User1:          data.User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: data.PendingOutgoing},
+			User2:          data.User{UserID: receiver, RelationshipType: data.PendingIncoming},
+			ConversationID: "",
+		}
+		err := mp.AddRelationship(context.Background(), relationship)
+		if receiver == "e2382ea2-b5fa-4506-aa9d-d338aa52af46" && !errors.Is(err, data.ErrorInviteQuotaExceeded) {
+			t.Errorf("Expected the second friend request to exceed the hourly quota")
+		}
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBAddAndRedeemFriendCodeIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	friendCode := &data.FriendCode{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", SingleUse: true}
+	err := mp.AddFriendCode(context.Background(), friendCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = mp.RedeemFriendCode(context.Background(), friendCode.Code, "a2181017-5c53-422b-b6bc-036b27c04fc8")
+	if err != nil {
+		t.Fail()
+	}
+
+	_, err = mp.GetFriendCode(context.Background(), friendCode.Code)
+	if err != data.ErrorFriendCodeNotFound {
+		t.Errorf("Expected single use friend code to be consumed")
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBUpdateUserSettingsIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	settings := &data.UserSettings{
+		UserID:             "a2181017-5c53-422b-b6bc-036b27c04fc8",
+		InvitePolicy:       data.InvitesFromNobody,
+		FriendsListVisible: false,
+	}
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	err := mp.UpdateUserSettings(context.Background(), settings)
+	if err != nil {
+		t.Fail()
+	}
+
+	storedSettings, err := mp.GetUserSettings(context.Background(), settings.UserID)
+	if err != nil || storedSettings.InvitePolicy != data.InvitesFromNobody {
+		t.Fail()
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBGetBlockerIDsByUserIDIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	relationship := &data.Relationship{
+		User1:          data.User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: data.Blocked},
+		User2:          data.User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: data.None},
+		ConversationID: "",
+	}
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	err := mp.AddRelationship(context.Background(), relationship)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockerIDs, err := mp.GetBlockerIDsByUserID(context.Background(), "e2382ea2-b5fa-4506-aa9d-d338aa52af44")
+	if err != nil || len(blockerIDs) != 1 || blockerIDs[0] != "a2181017-5c53-422b-b6bc-036b27c04fc8" {
+		t.Fail()
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBDeleteRelationshipInFriendGroupIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	relationship := &data.Relationship{
+		User1:          data.User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: data.Friend},
+		User2:          data.User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: data.Friend},
+		ConversationID: "",
+	}
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	err := mp.AddRelationship(context.Background(), relationship)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	friendGroup := &data.FriendGroup{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", Name: "Raid team", RelationshipIDs: []string{relationship.ID}}
+	err = mp.AddFriendGroup(context.Background(), friendGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mp.DeleteRelationship(context.Background(), relationship.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storedGroup, err := mp.GetFriendGroup(context.Background(), friendGroup.ID)
+	if err != nil || storedGroup.Contains(relationship.ID) {
+		t.Errorf("Expected the deleted relationship to be removed from the friend group")
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBDeleteRelationshipFailureIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	relationship := &data.Relationship{
+		User1:          data.User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: data.Friend},
+		User2:          data.User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: data.Friend},
+		ConversationID: "",
+	}
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	err := mp.AddRelationship(context.Background(), relationship)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A delete that fails is reported, so the user events deleting an account are sent again
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = mp.DeleteRelationship(ctx, relationship.ID)
+	if err == nil {
+		t.Error("Expected the failed delete to return an error")
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBUpdateRelationshipPreferencesIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	relationship := &data.Relationship{
+		User1:          data.User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: data.Friend},
+		User2:          data.User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: data.Friend},
+		ConversationID: "",
+	}
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	err := mp.AddRelationship(context.Background(), relationship)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	preferences := &data.RelationshipPreferences{Nickname: "Healer", Favourite: true}
+	err = mp.UpdateRelationshipPreferences(context.Background(), relationship.ID, "e2382ea2-b5fa-4506-aa9d-d338aa52af44", preferences)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Updating the relationship keeps the preferences of each user
+	err = mp.UpdateRelationship(context.Background(), relationship)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	friends, err := mp.GetFriendsListByUserID(context.Background(), "e2382ea2-b5fa-4506-aa9d-d338aa52af44")
+	if err != nil || len(*friends) != 1 || (*friends)[0].Nickname != "Healer" {
+		t.Errorf("Expected the relationship preferences to be kept")
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBFollowAndBlockIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	_, err := mp.AddFollow(context.Background(), "a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	followCounts, err := mp.GetFollowCounts(context.Background(), "e2382ea2-b5fa-4506-aa9d-d338aa52af44")
+	if err != nil || followCounts.Followers != 1 {
+		t.Errorf("Expected the follower to be counted")
+	}
+
+	// Blocking removes the follow
+	relationship := &data.Relationship{
+		User1:          data.User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: data.Blocked},
+		User2:          data.User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: data.None},
+		ConversationID: "",
+	}
+	err = mp.AddRelationship(context.Background(), relationship)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	followCounts, err = mp.GetFollowCounts(context.Background(), "e2382ea2-b5fa-4506-aa9d-d338aa52af44")
+	if err != nil || followCounts.Followers != 0 {
+		t.Errorf("Expected the block to remove the follow")
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBAddCrossingFriendRequestIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	err := mp.AddRelationship(context.Background(), data.NewInvite("a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relationship := data.NewInvite("e2382ea2-b5fa-4506-aa9d-d338aa52af44", "a2181017-5c53-422b-b6bc-036b27c04fc8")
+	err = mp.AddRelationship(context.Background(), relationship)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !relationship.IsFriendship() {
+		t.Errorf("Expected the crossing friend request to return the friendship")
+	}
+
+	stored, err := mp.GetRelationshipByUserIDs(context.Background(), "a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44")
+	if err != nil || !stored.IsFriendship() || stored.ExpiresAt != nil {
+		t.Errorf("Expected the pending relationship to be turned into a friendship")
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBAddPartyFriendshipsIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	err := mp.AddRelationship(context.Background(), data.NewInvite("a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := mp.AddPartyFriendships(context.Background(), []string{"a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Friendships) != 1 || !result.Friendships[0].IsFriendship() || result.Friendships[0].ExpiresAt != nil {
+		t.Errorf("Expected the pending relationship to be turned into a friendship")
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBRelayOutboxIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	broker := events.NewMemoryBroker()
+	published := events.Events{}
+	broker.Subscribe(func(event *events.Event) {
+		published = append(published, event)
+	})
+
+	mp := NewMongoRelationships(broker)
+	relationship := data.NewInvite("a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44")
+	err := mp.AddRelationship(context.Background(), relationship)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mp.DeleteRelationship(context.Background(), relationship.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Events wait in the outbox until they are relayed
+	if len(published) != 0 {
+		t.Errorf("Expected the events to be published by the relay")
+	}
+	count, err := mp.RelayOutbox(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 || published[0].Type != events.InviteSent || published[1].Type != events.InviteRemoved {
+		t.Errorf("Expected the events of the pair to be published in order, got %+v", published)
+	}
+
+	count, err = mp.RelayOutbox(context.Background())
+	if err != nil || count != 0 {
+		t.Errorf("Expected the relayed events to be removed from the outbox")
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBWebhookDeadLettersIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	webhook := &data.Webhook{URL: "http://localhost:1/hooks", EventTypes: []string{string(events.InviteSent)}, Secret: "0123456789abcdef"}
+	err := mp.AddWebhook(context.Background(), webhook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := NewWebhookDispatcher(mp)
+	dispatcher.maxAttempts = 1
+	err = dispatcher.Publish(context.Background(), &events.Event{ID: "a4b5c6d7-8a7e-11eb-8dcd-0242ac130003", Type: events.InviteSent})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing listens on the webhook URL, its only attempt fails
+	_, err = dispatcher.Dispatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadLetters, err := mp.GetDeadWebhookDeliveries(context.Background(), webhook.ID)
+	if err != nil || len(deadLetters) != 1 {
+		t.Fatalf("Expected the failed delivery in the dead letters, got %+v", deadLetters)
+	}
+
+	replayed, err := mp.ReplayWebhookDeliveries(context.Background(), webhook.ID, nil)
+	if err != nil || replayed != 1 {
+		t.Errorf("Expected the dead letter to be replayed, got %d", replayed)
+	}
+	claimed, err := mp.ClaimWebhookDeliveries(context.Background(), 10)
+	if err != nil || len(claimed) != 1 {
+		t.Errorf("Expected the replayed delivery to be claimed, got %+v", claimed)
+	}
+	claimed, err = mp.ClaimWebhookDeliveries(context.Background(), 10)
+	if err != nil || len(claimed) != 0 {
+		t.Errorf("Expected a claimed delivery to be hidden from the next claims, got %+v", claimed)
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBFreezeAndDeleteUserIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	friendship := data.NewFriendship("a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44")
+	err := mp.AddRelationship(context.Background(), friendship)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bannedOn := time.Now().UTC()
+	err = mp.SetUserFrozen(context.Background(), "e2382ea2-b5fa-4506-aa9d-d338aa52af44", true, bannedOn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An unban older than the ban is received late and ignored
+	err = mp.SetUserFrozen(context.Background(), "e2382ea2-b5fa-4506-aa9d-d338aa52af44", false, bannedOn.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	friendIDs, err := mp.GetFriendIDsByUserID(context.Background(), "a2181017-5c53-422b-b6bc-036b27c04fc8")
+	if err != nil || len(friendIDs) != 0 {
+		t.Errorf("Expected the banned friend to be hidden, got %v", friendIDs)
+	}
+	err = mp.UpdateRelationship(context.Background(), friendship)
+	if err != data.ErrorUserFrozen {
+		t.Errorf("Expected the relationship of a banned user to be frozen, got %v", err)
+	}
+
+	deleted, err := mp.DeleteUserRelationships(context.Background(), "e2382ea2-b5fa-4506-aa9d-d338aa52af44")
+	if err != nil || deleted != 1 {
+		t.Errorf("Expected the relationship of the deleted user to be deleted, got %d", deleted)
+	}
+	_, err = mp.GetRelationshipByUserIDs(context.Background(), "a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44")
+	if err != data.ErrorRelationshipNotFound {
+		t.Errorf("Expected no relationship left, got %v", err)
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBPresenceIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	now := time.Now().UTC()
+	err := mp.SetPresence(context.Background(), &data.Presence{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", Status: data.PresenceInGame, UpdatedOn: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An older update doesn't replace the stored status
+	err = mp.SetPresence(context.Background(), &data.Presence{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", Status: data.PresenceOffline, UpdatedOn: now.Add(-time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An expired status isn't returned
+	err = mp.SetPresence(context.Background(), &data.Presence{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", Status: data.PresenceOnline, UpdatedOn: now.Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := mp.GetPresenceStatuses(context.Background(), []string{"e2382ea2-b5fa-4506-aa9d-d338aa52af44", "a2181017-5c53-422b-b6bc-036b27c04fc8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses["e2382ea2-b5fa-4506-aa9d-d338aa52af44"] != data.PresenceInGame {
+		t.Errorf("Expected only the recent status, got %v", statuses)
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBFeedIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	now := time.Now().UTC()
+	items := data.FeedItems{
+		{ID: "event-1:a2181017-5c53-422b-b6bc-036b27c04fc8", UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", Type: data.FeedInviteAccepted, ActorID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", SubjectID: "a2181017-5c53-422b-b6bc-036b27c04fc8", EventID: "event-1", CreatedOn: now.Add(-time.Minute), ExpiresAt: now.Add(time.Hour)},
+		{ID: "event-2:a2181017-5c53-422b-b6bc-036b27c04fc8", UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", Type: data.FeedFriendAdded, ActorID: "0af831ea-8a78-11eb-8dcd-0242ac130003", SubjectID: "c5825d3e-8a77-11eb-8dcd-0242ac130003", EventID: "event-2", CreatedOn: now, ExpiresAt: now.Add(time.Hour)},
+	}
+	// Saving the items twice keeps one copy, and only the newest item is kept
+	for i := 0; i < 2; i++ {
+		err := mp.AddFeedItems(context.Background(), items, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	feed, err := mp.GetFeedByUserID(context.Background(), "a2181017-5c53-422b-b6bc-036b27c04fc8", 0, 10)
+	if err != nil || len(feed) != 1 || feed[0].EventID != "event-2" {
+		t.Errorf("Expected only the newest item in the feed, got %d items", len(feed))
+	}
+
+	err = mp.DeleteFeedItemsBetween(context.Background(), "c5825d3e-8a77-11eb-8dcd-0242ac130003", "0af831ea-8a78-11eb-8dcd-0242ac130003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed, _ = mp.GetFeedByUserID(context.Background(), "a2181017-5c53-422b-b6bc-036b27c04fc8", 0, 10)
+	if len(feed) != 0 {
+		t.Errorf("Expected the items of the ended friendship to be deleted, got %d items", len(feed))
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBSessionInviteIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	err := mp.AddRelationship(context.Background(), data.NewFriendship("a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionInvite := &data.SessionInvite{SessionID: "match-42", HostID: "a2181017-5c53-422b-b6bc-036b27c04fc8", InviteeID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44"}
+	err = mp.AddSessionInvite(context.Background(), sessionInvite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mp.AddSessionInvite(context.Background(), &data.SessionInvite{SessionID: "match-42", HostID: "a2181017-5c53-422b-b6bc-036b27c04fc8", InviteeID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44"})
+	if err != data.ErrorSessionInviteExist {
+		t.Errorf("Expected the duplicate session invite to be refused, got %v", err)
+	}
+
+	sessionInvites, err := mp.GetSessionInvitesByUserID(context.Background(), "e2382ea2-b5fa-4506-aa9d-d338aa52af44")
+	if err != nil || len(sessionInvites) != 1 {
+		t.Errorf("Expected the pending session invite, got %d", len(sessionInvites))
+	}
+	answered, err := mp.AnswerSessionInvite(context.Background(), sessionInvite.ID, "e2382ea2-b5fa-4506-aa9d-d338aa52af44", false)
+	if err != nil || answered.Status != data.SessionInviteDeclined {
+		t.Errorf("Expected the session invite to be declined, got %v", err)
+	}
+	_, err = mp.AnswerSessionInvite(context.Background(), sessionInvite.ID, "e2382ea2-b5fa-4506-aa9d-d338aa52af44", true)
+	if err != data.ErrorSessionInviteAnswered {
+		t.Errorf("Expected the answered session invite to be refused, got %v", err)
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBRecentPlayersIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	matchRoster := &data.MatchRoster{MatchID: "match-42", UserIDs: []string{"a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44"}, PlayedAt: time.Now().UTC()}
+	for i := 0; i < 2; i++ {
+		err := mp.AddMatchRoster(context.Background(), matchRoster)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recentPlayers, err := mp.GetRecentPlayersByUserID(context.Background(), "a2181017-5c53-422b-b6bc-036b27c04fc8", 0, 20)
+	if err != nil || len(recentPlayers) != 1 || recentPlayers[0].Matches != 1 {
+		t.Errorf("Expected the co-player counted once, got %v", err)
+	}
+	suggestions, err := mp.GetFriendSuggestions(context.Background(), "e2382ea2-b5fa-4506-aa9d-d338aa52af44", 10)
+	if err != nil || len(suggestions) != 1 || suggestions[0].UserID != "a2181017-5c53-422b-b6bc-036b27c04fc8" {
+		t.Errorf("Expected the co-player to be suggested, got %v", err)
+	}
+
+	// A banned co-player is left out until the ban is lifted
+	bannedOn := time.Now().UTC()
+	err = mp.SetUserFrozen(context.Background(), "e2382ea2-b5fa-4506-aa9d-d338aa52af44", true, bannedOn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recentPlayers, err = mp.GetRecentPlayersByUserID(context.Background(), "a2181017-5c53-422b-b6bc-036b27c04fc8", 0, 20)
+	if err != nil || len(recentPlayers) != 0 {
+		t.Errorf("Expected the banned co-player to be left out, got %v", err)
+	}
+	err = mp.SetUserFrozen(context.Background(), "e2382ea2-b5fa-4506-aa9d-d338aa52af44", false, bannedOn.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.CloseDB()
+}
+
+func TestMongoDBAffinityIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test skipped during unit tests")
+	}
+	integrationTestSetup(t)
+
+	mp := NewMongoRelationships(events.NewMemoryBroker())
+	err := mp.AddRelationship(context.Background(), data.NewFriendship("a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signal := &data.AffinitySignal{ID: "match:match-42", Type: data.AffinityMatch, UserIDs: []string{"a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44"}, OccurredAt: time.Now().UTC()}
+	for i := 0; i < 2; i++ {
+		err = mp.AddAffinitySignal(context.Background(), signal)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	affinities, err := mp.GetAffinities(context.Background(), "e2382ea2-b5fa-4506-aa9d-d338aa52af44", []string{"a2181017-5c53-422b-b6bc-036b27c04fc8"})
+	if err != nil || affinities["a2181017-5c53-422b-b6bc-036b27c04fc8"] > affinityWeights[data.AffinityMatch] || affinities["a2181017-5c53-422b-b6bc-036b27c04fc8"] < 2.9 {
+		t.Errorf("Expected the match to be counted once, got %v", affinities)
+	}
+
+	err = mp.DeleteAffinity(context.Background(), "a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44")
+	if err != nil {
+		t.Fatal(err)
+	}
+	affinities, _ = mp.GetAffinities(context.Background(), "e2382ea2-b5fa-4506-aa9d-d338aa52af44", []string{"a2181017-5c53-422b-b6bc-036b27c04fc8"})
+	if len(affinities) != 0 {
+		t.Errorf("Expected the affinity to be deleted, got %v", affinities)
+	}
+	mp.CloseDB()
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+)
+
+// Metrics of the outbox relay, exported with the other metrics of the service
+var (
+	outboxPending       = stats.Int64("outbox_pending", "Events waiting in the outbox", stats.UnitDimensionless)
+	outboxLag           = stats.Float64("outbox_lag_seconds", "Age of the oldest event waiting in the outbox", stats.UnitSeconds)
+	outboxDeliveryLag   = stats.Float64("outbox_delivery_lag_seconds", "Time between a relationship change and the publication of its event", stats.UnitSeconds)
+	outboxPublishErrors = stats.Int64("outbox_publish_errors", "Events that failed to be published", stats.UnitDimensionless)
+)
+
+var outboxViews = []*view.View{
+	{Name: "outbox_pending", Measure: outboxPending, Description: outboxPending.Description(), Aggregation: view.LastValue()},
+	{Name: "outbox_lag_seconds", Measure: outboxLag, Description: outboxLag.Description(), Aggregation: view.LastValue()},
+	{Name: "outbox_delivery_lag_seconds", Measure: outboxDeliveryLag, Description: outboxDeliveryLag.Description(), Aggregation: view.Distribution(0.1, 0.5, 1, 5, 10, 30, 60, 300)},
+	{Name: "outbox_publish_errors_count", Measure: outboxPublishErrors, Description: outboxPublishErrors.Description(), Aggregation: view.Count()},
+}
+
+// StartOutboxRelay periodically publishes the events waiting in the outbox until the context is cancelled
+// The interval between passes is read from OUTBOX_RELAY_INTERVAL and defaults to a second
+func StartOutboxRelay(ctx context.Context, db RelationshipDB) {
+	interval := envDuration("OUTBOX_RELAY_INTERVAL", time.Second)
+	if interval == 0 {
+		log.Info("Outbox relay disabled")
+		return
+	}
+
+	err := view.Register(outboxViews...)
+	if err != nil {
+		log.Error(err, "Failed to register outbox metric views")
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		log.Info("Starting outbox relay", "interval", interval.String())
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("Outbox relay stopped")
+				return
+			case <-ticker.C:
+				published, err := db.RelayOutbox(ctx)
+				if err != nil {
+					log.Error(err, "Error relaying outbox events")
+				}
+				if published > 0 {
+					log.Info("Relayed outbox events", "publish_count", published)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+)
+
+// relationshipStore is the part of a database needed to make the users of a party friends
+type relationshipStore interface {
+	GetRelationshipByUserIDs(ctx context.Context, userID1 string, userID2 string) (*data.Relationship, error)
+	AddRelationship(ctx context.Context, relationship *data.Relationship) error
+	UpdateRelationship(ctx context.Context, relationship *data.Relationship) error
+	validateUserExist(userID string) bool
+}
+
+// addPartyFriendships makes every pair of users friends, skipping the pairs that can't become friends
+// Pending and neutral relationships are turned into friendships, the users keep their preferences
+// Every user is checked before the first friendship is saved, a pair failing afterwards is skipped so the saved friendships are still reported
+func addPartyFriendships(ctx context.Context, store relationshipStore, userIDs []string) (*data.PartyFriendshipResult, error) {
+	for _, userID := range userIDs {
+		if !store.validateUserExist(userID) {
+			return nil, data.ErrorUserNotFound
+		}
+	}
+
+	result := &data.PartyFriendshipResult{Friendships: data.Relationships{}, Skipped: []*data.SkippedPair{}}
+	for i, userID1 := range userIDs {
+		for _, userID2 := range userIDs[i+1:] {
+			friendship, reason, err := addFriendship(ctx, store, userID1, userID2)
+			if err != nil {
+				log.Error(err, "Error adding party friendship", "user_id_1", userID1, "user_id_2", userID2)
+				reason = data.SkippedFailed
+			}
+			if reason != "" {
+				result.Skipped = append(result.Skipped, &data.SkippedPair{UserID1: userID1, UserID2: userID2, Reason: reason})
+				continue
+			}
+			result.Friendships = append(result.Friendships, friendship)
+		}
+	}
+
+	log.Info("Party friendships added", "users", len(userIDs), "friendships", len(result.Friendships), "skipped", len(result.Skipped))
+	return result, nil
+}
+
+// addFriendship makes two users friends, or returns the reason why they can't be
+func addFriendship(ctx context.Context, store relationshipStore, userID1 string, userID2 string) (*data.Relationship, string, error) {
+	existing, err := store.GetRelationshipByUserIDs(ctx, userID1, userID2)
+	if err != nil && err != data.ErrorRelationshipNotFound {
+		return nil, "", err
+	}
+
+	var friendship *data.Relationship
+	switch {
+	case existing == nil:
+		friendship = data.NewFriendship(userID1, userID2)
+		err = store.AddRelationship(ctx, friendship)
+	case existing.IsBlocked():
+		return nil, data.SkippedBlocked, nil
+	case existing.IsFriendship():
+		return nil, data.SkippedAlreadyFriends, nil
+	default:
+		accepted := *existing
+		accepted.Accept()
+		friendship = &accepted
+		err = store.UpdateRelationship(ctx, friendship)
+	}
+
+	var limitError *data.FriendLimitError
+	switch {
+	case errors.As(err, &limitError):
+		return nil, data.SkippedFriendLimit, nil
+	case err == data.ErrorInvalidTransition:
+		return nil, data.SkippedNotAllowed, nil
+	case err == data.ErrorUserFrozen:
+		return nil, data.SkippedFrozen, nil
+	case err != nil:
+		return nil, "", err
+	}
+	return friendship, "", nil
+}
@@ -0,0 +1,11 @@
+package database
+
+import (
+	"time"
+)
+
+// presenceTTL is the time a status stays valid without being received again
+// Users whose status expired are offline
+func presenceTTL() time.Duration {
+	return envDuration("PRESENCE_TTL", 5*time.Minute)
+}
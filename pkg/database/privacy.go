@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+)
+
+// socialGraph is the part of a database needed to apply the privacy settings of the users
+type socialGraph interface {
+	GetUserSettings(ctx context.Context, userID string) (*data.UserSettings, error)
+	GetFriendIDsByUserID(ctx context.Context, userID string) ([]string, error)
+}
+
+// checkInvitePolicy verifies that the receiver of a friend request accepts requests from its sender
+func checkInvitePolicy(ctx context.Context, graph socialGraph, relationship *data.Relationship) error {
+	senderID := relationship.Sender()
+	if senderID == "" {
+		return nil
+	}
+	receiverID := relationship.OtherUser(senderID).UserID
+
+	settings, err := graph.GetUserSettings(ctx, receiverID)
+	if err != nil {
+		return err
+	}
+
+	switch settings.InvitePolicy {
+	case data.InvitesFromNobody:
+		return data.ErrorInvitesNotAllowed
+	case data.InvitesFromFriendsOfFriends:
+		mutual, err := hasMutualFriend(ctx, graph, senderID, receiverID)
+		if err != nil {
+			return err
+		}
+		if !mutual {
+			return data.ErrorInvitesNotAllowed
+		}
+	}
+	return nil
+}
+
+// hasMutualFriend returns true when two users have at least one friend in common
+func hasMutualFriend(ctx context.Context, graph socialGraph, userID1 string, userID2 string) (bool, error) {
+	friends1, err := graph.GetFriendIDsByUserID(ctx, userID1)
+	if err != nil {
+		return false, err
+	}
+	friends2, err := graph.GetFriendIDsByUserID(ctx, userID2)
+	if err != nil {
+		return false, err
+	}
+
+	friendSet := make(map[string]bool, len(friends1))
+	for _, friendID := range friends1 {
+		friendSet[friendID] = true
+	}
+	for _, friendID := range friends2 {
+		if friendSet[friendID] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"time"
+)
+
+// inviteQuota holds the limits on the friend requests a user can send, a limit of 0 is disabled
+type inviteQuota struct {
+	maxPending int
+	maxPerHour int
+	maxPerDay  int
+}
+
+// quotaWindow is a fixed window during which a user can send a limited number of friend requests
+type quotaWindow struct {
+	name   string
+	length time.Duration
+	limit  int
+}
+
+// inviteQuotaFromEnv reads the friend request limits from INVITE_MAX_PENDING, INVITE_MAX_PER_HOUR and INVITE_MAX_PER_DAY
+func inviteQuotaFromEnv() inviteQuota {
+	return inviteQuota{
+		maxPending: envInt("INVITE_MAX_PENDING", 100),
+		maxPerHour: envInt("INVITE_MAX_PER_HOUR", 20),
+		maxPerDay:  envInt("INVITE_MAX_PER_DAY", 100),
+	}
+}
+
+// windows returns the enabled quota windows
+func (quota inviteQuota) windows() []quotaWindow {
+	var windows []quotaWindow
+	if quota.maxPerHour > 0 {
+		windows = append(windows, quotaWindow{name: "hourly", length: time.Hour, limit: quota.maxPerHour})
+	}
+	if quota.maxPerDay > 0 {
+		windows = append(windows, quotaWindow{name: "daily", length: 24 * time.Hour, limit: quota.maxPerDay})
+	}
+	return windows
+}
+
+// key identifies the counter of a user for the window containing now
+func (window quotaWindow) key(userID string, now time.Time) string {
+	return userID + ":" + window.name + ":" + now.Truncate(window.length).Format(time.RFC3339)
+}
+
+// end returns the end of the window containing now
+func (window quotaWindow) end(now time.Time) time.Time {
+	return now.Truncate(window.length).Add(window.length)
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+)
+
+// recentMatchIDs is the number of matches remembered for each co-player, to ignore a roster sent again
+const recentMatchIDs = 20
+
+// weights of the friend suggestion score
+const (
+	mutualFriendScore = 2 // each friend in common
+	matchScore        = 1 // each match played together
+)
+
+// suggestionCandidates bounds the recent co-players and friends read to find suggestions
+const suggestionCandidates = 100
+
+// recentPlayersTTL is the time a co-player is kept after the last match played together
+func recentPlayersTTL() time.Duration {
+	return envDuration("RECENT_PLAYERS_TTL", 14*24*time.Hour)
+}
+
+// recentPlayerID identifies a co-player of a user
+func recentPlayerID(userID string, playerID string) string {
+	return userID + ":" + playerID
+}
+
+// withoutFrozenPlayers removes the co-players who are banned, they are hidden like their relationships
+func withoutFrozenPlayers(recentPlayers data.RecentPlayers, frozenUserIDs map[string]bool) data.RecentPlayers {
+	if len(frozenUserIDs) == 0 {
+		return recentPlayers
+	}
+	kept := data.RecentPlayers{}
+	for _, recentPlayer := range recentPlayers {
+		if !frozenUserIDs[recentPlayer.PlayerID] {
+			kept = append(kept, recentPlayer)
+		}
+	}
+	return kept
+}
+
+// suggestFriends returns the users the user may know, best first
+// Candidates are the recent co-players and the friends of friends the user has no relationship with, and who accept friend requests from the user
+// Banned users are left out by GetRecentPlayersByUserID and GetFriendIDsByUserID
+func suggestFriends(ctx context.Context, db RelationshipDB, userID string, limit int) (data.FriendSuggestions, error) {
+	// Users already related to the user, friends, pending requests and blocks included, a follow doesn't prevent a suggestion
+	related := map[string]bool{userID: true}
+	err := db.ExportRelationshipsByUserID(ctx, userID, func(relationship *data.Relationship) error {
+		if !relationship.IsFollow() {
+			related[relationship.OtherUser(userID).UserID] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := map[string]*data.FriendSuggestion{}
+	candidate := func(candidateID string) *data.FriendSuggestion {
+		suggestion, ok := candidates[candidateID]
+		if !ok {
+			suggestion = &data.FriendSuggestion{UserID: candidateID}
+			candidates[candidateID] = suggestion
+		}
+		return suggestion
+	}
+
+	recentPlayers, err := db.GetRecentPlayersByUserID(ctx, userID, 0, suggestionCandidates)
+	if err != nil {
+		return nil, err
+	}
+	for _, recentPlayer := range recentPlayers {
+		if !related[recentPlayer.PlayerID] {
+			candidate(recentPlayer.PlayerID).MatchesTogether = recentPlayer.Matches
+		}
+	}
+
+	friendIDs, err := db.GetFriendIDsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(friendIDs) > suggestionCandidates {
+		friendIDs = friendIDs[:suggestionCandidates]
+	}
+	for _, friendID := range friendIDs {
+		friendsOfFriend, err := db.GetFriendIDsByUserID(ctx, friendID)
+		if err != nil {
+			return nil, err
+		}
+		for _, friendOfFriendID := range friendsOfFriend {
+			if !related[friendOfFriendID] {
+				candidate(friendOfFriendID).MutualFriends++
+			}
+		}
+	}
+
+	ranked := make(data.FriendSuggestions, 0, len(candidates))
+	for _, suggestion := range candidates {
+		suggestion.Score = suggestion.MutualFriends*mutualFriendScore + suggestion.MatchesTogether*matchScore
+		ranked = append(ranked, suggestion)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].UserID < ranked[j].UserID
+	})
+
+	suggestions := data.FriendSuggestions{}
+	for _, suggestion := range ranked {
+		if len(suggestions) == limit {
+			break
+		}
+		err = checkInvitePolicy(ctx, db, data.NewInvite(userID, suggestion.UserID))
+		if err == data.ErrorInvitesNotAllowed {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, nil
+}
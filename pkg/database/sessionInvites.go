@@ -0,0 +1,43 @@
+package database
+
+import (
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
+)
+
+// sessionInviteTTL is the time a session invite can be answered, games start quickly
+func sessionInviteTTL() time.Duration {
+	return envDuration("SESSION_INVITE_TTL", 15*time.Minute)
+}
+
+// checkFriends refuses session invites between users who aren't friends, a missing or blocked relationship included
+func checkFriends(relationship *data.Relationship) error {
+	if relationship == nil || !relationship.IsFriendship() {
+		return data.ErrorNotFriends
+	}
+	return nil
+}
+
+// answerSessionInvite applies the answer of the user to a session invite, and returns the type of its event
+// Session invites of other users are hidden from the user
+func answerSessionInvite(sessionInvite *data.SessionInvite, userID string, accept bool, now time.Time) (events.Type, error) {
+	if sessionInvite.InviteeID != userID {
+		return "", data.ErrorSessionInviteNotFound
+	}
+	if sessionInvite.Status != data.SessionInvitePending {
+		return "", data.ErrorSessionInviteAnswered
+	}
+	if sessionInvite.IsExpired(now) {
+		return "", data.ErrorSessionInviteExpired
+	}
+
+	sessionInvite.UpdatedOn = now
+	if accept {
+		sessionInvite.Status = data.SessionInviteAccepted
+		return events.SessionInviteAccepted, nil
+	}
+	sessionInvite.Status = data.SessionInviteDeclined
+	return events.SessionInviteDeclined, nil
+}
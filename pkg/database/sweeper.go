@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// StartInviteSweeper periodically deletes the expired friend requests until the context is cancelled
+// The interval between sweeps is read from INVITE_SWEEP_INTERVAL and defaults to an hour
+func StartInviteSweeper(ctx context.Context, db RelationshipDB) {
+	interval := envDuration("INVITE_SWEEP_INTERVAL", time.Hour)
+	if interval == 0 {
+		log.Info("Invite sweeper disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		log.Info("Starting invite sweeper", "interval", interval.String())
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("Invite sweeper stopped")
+				return
+			case <-ticker.C:
+				deleted, err := db.DeleteExpiredInvites(ctx)
+				if err != nil {
+					log.Error(err, "Error sweeping expired invites")
+					continue
+				}
+				if deleted > 0 {
+					log.Info("Swept expired invites", "delete_count", deleted)
+				}
+			}
+		}
+	}()
+}
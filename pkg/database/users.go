@@ -0,0 +1,36 @@
+package database
+
+import (
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+)
+
+// userDetailsTTL is the time the details of a user fetched from microservice-user are cached
+// A USER_DETAILS_CACHE_TTL of 0 disables the cache
+func userDetailsTTL() time.Duration {
+	return envDuration("USER_DETAILS_CACHE_TTL", 5*time.Minute)
+}
+
+// withoutFrozen removes the relationships of the user with frozen users, they are hidden while the other user is banned
+func withoutFrozen(userID string, relationships data.Relationships, frozenUserIDs map[string]bool) data.Relationships {
+	if len(frozenUserIDs) == 0 {
+		return relationships
+	}
+	kept := data.Relationships{}
+	for _, relationship := range relationships {
+		if !frozenUserIDs[relationship.OtherUser(userID).UserID] {
+			kept = append(kept, relationship)
+		}
+	}
+	return kept
+}
+
+// otherUserIDs returns the other user of each relationship of the user
+func otherUserIDs(userID string, relationships data.Relationships) []string {
+	userIDs := make([]string, 0, len(relationships))
+	for _, relationship := range relationships {
+		userIDs = append(userIDs, relationship.OtherUser(userID).UserID)
+	}
+	return userIDs
+}
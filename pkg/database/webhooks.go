@@ -0,0 +1,217 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
+)
+
+// webhookClaimTimeout is the time a claimed delivery is hidden from the other replicas while it is sent
+const webhookClaimTimeout = time.Minute
+
+// webhookBatchSize is the maximum number of deliveries sent in a pass of the dispatcher
+const webhookBatchSize = 50
+
+// WebhookDispatcher saves the events for each webhook subscribed to them, and sends the saved deliveries
+// It is a publisher, registered next to the event broker
+type WebhookDispatcher struct {
+	db          RelationshipDB
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+// NewWebhookDispatcher reads its retry policy from WEBHOOK_MAX_ATTEMPTS, WEBHOOK_RETRY_BACKOFF and WEBHOOK_MAX_BACKOFF
+// and the timeout of a delivery from WEBHOOK_TIMEOUT
+func NewWebhookDispatcher(db RelationshipDB) *WebhookDispatcher {
+	maxAttempts := envInt("WEBHOOK_MAX_ATTEMPTS", 8)
+	if maxAttempts == 0 {
+		maxAttempts = 1
+	}
+	return &WebhookDispatcher{
+		db:          db,
+		client:      &http.Client{Timeout: envDuration("WEBHOOK_TIMEOUT", 10*time.Second)},
+		maxAttempts: maxAttempts,
+		backoff:     envDuration("WEBHOOK_RETRY_BACKOFF", 10*time.Second),
+		maxBackoff:  envDuration("WEBHOOK_MAX_BACKOFF", time.Hour),
+	}
+}
+
+// Publish saves a delivery of the event for each webhook accepting its type
+// A delivery is identified by its event and webhook, so publishing an event again doesn't deliver it twice
+func (dispatcher *WebhookDispatcher) Publish(ctx context.Context, event *events.Event) error {
+	webhooks, err := dispatcher.db.GetWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	deliveries := data.WebhookDeliveries{}
+	for _, webhook := range webhooks {
+		if !webhook.Accepts(string(event.Type)) {
+			continue
+		}
+		deliveries = append(deliveries, &data.WebhookDelivery{
+			ID:            event.ID + ":" + webhook.ID,
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			EventType:     string(event.Type),
+			Payload:       string(payload),
+			Status:        data.WebhookDeliveryPending,
+			NextAttemptAt: now,
+			CreatedOn:     now,
+		})
+	}
+	return dispatcher.db.AddWebhookDeliveries(ctx, deliveries)
+}
+
+// Close has nothing to release, the deliveries are saved in the database
+func (dispatcher *WebhookDispatcher) Close() error {
+	return nil
+}
+
+// Dispatch sends the deliveries due for an attempt, and returns how many were delivered
+// A failed delivery is retried with an exponential backoff, and becomes a dead letter after its last attempt
+func (dispatcher *WebhookDispatcher) Dispatch(ctx context.Context) (int, error) {
+	deliveries, err := dispatcher.db.ClaimWebhookDeliveries(ctx, webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, delivery := range deliveries {
+		webhook, err := dispatcher.db.GetWebhook(ctx, delivery.WebhookID)
+		if err == data.ErrorWebhookNotFound {
+			err = dispatcher.db.DeleteWebhookDelivery(ctx, delivery.ID)
+			if err != nil {
+				return delivered, err
+			}
+			continue
+		}
+		if err != nil {
+			return delivered, err
+		}
+
+		statusCode, err := dispatcher.send(ctx, webhook, delivery)
+		if err == nil {
+			err = dispatcher.db.DeleteWebhookDelivery(ctx, delivery.ID)
+			if err != nil {
+				return delivered, err
+			}
+			delivered++
+			continue
+		}
+
+		delivery.Attempts++
+		delivery.LastError = err.Error()
+		delivery.LastStatusCode = statusCode
+		if delivery.Attempts >= dispatcher.maxAttempts {
+			delivery.Status = data.WebhookDeliveryDead
+			log.Info("Webhook delivery moved to the dead letters", "webhook_id", webhook.ID, "delivery_id", delivery.ID, "attempts", delivery.Attempts, "error", delivery.LastError)
+		} else {
+			delivery.NextAttemptAt = time.Now().UTC().Add(dispatcher.retryDelay(delivery.Attempts))
+		}
+		err = dispatcher.db.UpdateWebhookDelivery(ctx, delivery)
+		if err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+// send posts the payload of a delivery to its webhook, any status other than 2xx is a failure
+func (dispatcher *WebhookDispatcher) send(ctx context.Context, webhook *data.Webhook, delivery *data.WebhookDelivery) (int, error) {
+	timestamp := time.Now().Unix()
+	payload := []byte(delivery.Payload)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Friendslist-Event", delivery.EventType)
+	request.Header.Set("X-Friendslist-Delivery", delivery.ID)
+	request.Header.Set("X-Friendslist-Timestamp", strconv.FormatInt(timestamp, 10))
+	request.Header.Set("X-Friendslist-Signature", "sha256="+data.SignWebhookPayload(webhook.Secret, timestamp, payload))
+
+	response, err := dispatcher.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("webhook responded with status %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// retryDelay doubles the backoff after each failed attempt, up to the maximum backoff
+func (dispatcher *WebhookDispatcher) retryDelay(attempts int) time.Duration {
+	delay := dispatcher.backoff
+	for i := 1; i < attempts && delay < dispatcher.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > dispatcher.maxBackoff {
+		return dispatcher.maxBackoff
+	}
+	return delay
+}
+
+// StartWebhookDispatcher periodically sends the webhook deliveries until the context is cancelled
+// The interval between passes is read from WEBHOOK_DISPATCH_INTERVAL and defaults to a second
+func StartWebhookDispatcher(ctx context.Context, dispatcher *WebhookDispatcher) {
+	interval := envDuration("WEBHOOK_DISPATCH_INTERVAL", time.Second)
+	if interval == 0 {
+		log.Info("Webhook dispatcher disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		log.Info("Starting webhook dispatcher", "interval", interval.String())
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("Webhook dispatcher stopped")
+				return
+			case <-ticker.C:
+				delivered, err := dispatcher.Dispatch(ctx)
+				if err != nil {
+					log.Error(err, "Error dispatching webhook deliveries")
+				}
+				if delivered > 0 {
+					log.Info("Dispatched webhook deliveries", "delivery_count", delivered)
+				}
+			}
+		}
+	}()
+}
+
+// containsString returns true when the value is in the list
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
+)
+
+func TestWebhookDispatcherSignsPayloads(t *testing.T) {
+	secret := "0123456789abcdef"
+	failures := 1
+	var signatureErr string
+	receiver := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		payload, _ := ioutil.ReadAll(request.Body)
+		timestamp, _ := strconv.ParseInt(request.Header.Get("X-Friendslist-Timestamp"), 10, 64)
+		if request.Header.Get("X-Friendslist-Signature") != "sha256="+data.SignWebhookPayload(secret, timestamp, payload) {
+			signatureErr = "invalid signature " + request.Header.Get("X-Friendslist-Signature")
+		}
+		if failures > 0 {
+			failures--
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	db := NewMockRelationships(events.NewMemoryBroker())
+	webhook := &data.Webhook{URL: receiver.URL, Secret: secret}
+	err := db.AddWebhook(context.Background(), webhook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.DeleteWebhook(context.Background(), webhook.ID)
+
+	dispatcher := NewWebhookDispatcher(db)
+	dispatcher.backoff = 0
+	err = dispatcher.Publish(context.Background(), &events.Event{ID: "f3a4b5c6-8a7e-11eb-8dcd-0242ac130003", Type: events.FriendAdded})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first attempt fails and is retried on the next pass
+	delivered, err := dispatcher.Dispatch(context.Background())
+	if err != nil || delivered != 0 {
+		t.Fatalf("Expected the first attempt to fail but got %d delivered, error %v", delivered, err)
+	}
+	delivered, err = dispatcher.Dispatch(context.Background())
+	if err != nil || delivered != 1 {
+		t.Fatalf("Expected the retry to be delivered but got %d delivered, error %v", delivered, err)
+	}
+	if signatureErr != "" {
+		t.Error(signatureErr)
+	}
+	if len(deliveryList) != 0 {
+		t.Errorf("Expected delivered events to be removed but got %d deliveries", len(deliveryList))
+	}
+}
+
+func TestWebhookDispatcherPublishesAnEventOnce(t *testing.T) {
+	db := NewMockRelationships(events.NewMemoryBroker())
+	webhook := &data.Webhook{URL: "http://localhost/webhook", Secret: "0123456789abcdef"}
+	err := db.AddWebhook(context.Background(), webhook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.DeleteWebhook(context.Background(), webhook.ID)
+
+	// A publication retried after a failure of another publisher sends the event again
+	dispatcher := NewWebhookDispatcher(db)
+	event := &events.Event{ID: "a4b5c6d7-8a84-11eb-8dcd-0242ac130003", Type: events.FriendAdded}
+	for i := 0; i < 2; i++ {
+		err = dispatcher.Publish(context.Background(), event)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deliveries := 0
+	for _, delivery := range deliveryList {
+		if delivery.EventID == event.ID {
+			deliveries++
+		}
+	}
+	if deliveries != 1 {
+		t.Errorf("Expected one delivery of the event but got %d", deliveries)
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	dispatcher := &WebhookDispatcher{backoff: 10 * time.Second, maxBackoff: time.Minute}
+	expected := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for index, delay := range expected {
+		if got := dispatcher.retryDelay(index + 1); got != delay {
+			t.Errorf("Expected a delay of %s after %d attempts but got %s", delay, index+1, got)
+		}
+	}
+}
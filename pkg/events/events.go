@@ -0,0 +1,183 @@
+package events
+
+import (
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/google/uuid"
+)
+
+// Type is the kind of relationship change an event describes
+type Type string
+
+// types of the relationship events
+const (
+	InviteSent          Type = "InviteSent"          // user sent a friend request to the other user
+	InviteAccepted      Type = "InviteAccepted"      // user accepted the friend request of the other user
+	InviteDeclined      Type = "InviteDeclined"      // user declined the friend request of the other user
+	InviteRemoved       Type = "InviteRemoved"       // the friend request of user to the other user was deleted
+	FriendAdded         Type = "FriendAdded"         // users became friends without a friend request, like a party or an auto accepted friend code
+	FriendRemoved       Type = "FriendRemoved"       // users are no longer friends
+	UserBlocked         Type = "UserBlocked"         // user blocked the other user
+	UserUnblocked       Type = "UserUnblocked"       // user unblocked the other user
+	RelationshipChanged Type = "RelationshipChanged" // any other change, like a relationship type declared by the game
+
+	SessionInviteSent     Type = "SessionInviteSent"     // user invited the other user, a friend, to join a game session
+	SessionInviteAccepted Type = "SessionInviteAccepted" // user accepted the session invite of the other user
+	SessionInviteDeclined Type = "SessionInviteDeclined" // user declined the session invite of the other user
+)
+
+// Types lists every type of relationship event, in the order they are documented
+var Types = []Type{InviteSent, InviteAccepted, InviteDeclined, InviteRemoved, FriendAdded, FriendRemoved, UserBlocked, UserUnblocked, RelationshipChanged, SessionInviteSent, SessionInviteAccepted, SessionInviteDeclined}
+
+// IsType returns true when name is the name of a type of relationship event
+func IsType(name string) bool {
+	for _, eventType := range Types {
+		if string(eventType) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is a change of a relationship published to the other services
+type Event struct {
+	ID             string              `json:"id" bson:"_id"`
+	Type           Type                `json:"type" bson:"type"`
+	UserID         string              `json:"user_id" bson:"user_id"` // user who made the change, or the first user when it isn't known
+	OtherUserID    string              `json:"other_user_id" bson:"other_user_id"`
+	RelationshipID string              `json:"relationship_id" bson:"relationship_id"`
+	Relationship   *data.Relationship  `json:"relationship,omitempty" bson:"relationship,omitempty"`     // relationship after the change, nil once deleted
+	SessionInvite  *data.SessionInvite `json:"session_invite,omitempty" bson:"session_invite,omitempty"` // session invite of the session invite events, which have no relationship
+	OccurredAt     time.Time           `json:"occurred_at" bson:"occurred_at"`
+}
+
+// Events is a collection of Event
+type Events []*Event
+
+// FromChange returns the events describing the change of a relationship from previous to current
+// previous is nil for a new relationship and current is nil for a deleted one
+func FromChange(previous *data.Relationship, current *data.Relationship) Events {
+	if previous == nil && current == nil {
+		return nil
+	}
+	change := &relationshipChange{previous: previous, current: current}
+	return change.events()
+}
+
+// FromSessionInvite returns the event of a change of a session invite made by a user
+func FromSessionInvite(eventType Type, sessionInvite *data.SessionInvite, userID string) *Event {
+	otherUserID := sessionInvite.InviteeID
+	if userID == sessionInvite.InviteeID {
+		otherUserID = sessionInvite.HostID
+	}
+	invite := *sessionInvite
+	return &Event{
+		ID:            uuid.NewString(),
+		Type:          eventType,
+		UserID:        userID,
+		OtherUserID:   otherUserID,
+		SessionInvite: &invite,
+		OccurredAt:    time.Now().UTC(),
+	}
+}
+
+// relationshipChange builds the events of a change, the private preferences of the users are never published
+type relationshipChange struct {
+	previous *data.Relationship
+	current  *data.Relationship
+	result   Events
+}
+
+func (change *relationshipChange) events() Events {
+	wasPending := change.previous != nil && change.previous.IsPending()
+	isPending := change.current != nil && change.current.IsPending()
+	wasFriendship := change.previous != nil && change.previous.IsFriendship()
+	isFriendship := change.current != nil && change.current.IsFriendship()
+
+	switch {
+	case isPending && !wasPending:
+		sender := change.current.Sender()
+		change.add(InviteSent, sender, change.current.OtherUser(sender).UserID)
+	case wasPending && !isPending:
+		sender := change.previous.Sender()
+		receiver := change.previous.OtherUser(sender).UserID
+		switch {
+		case isFriendship:
+			change.add(InviteAccepted, receiver, sender)
+		case change.current == nil:
+			change.add(InviteRemoved, sender, receiver)
+		default:
+			change.add(InviteDeclined, receiver, sender)
+		}
+	}
+
+	userID1, userID2 := change.userIDs()
+	switch {
+	case isFriendship && !wasFriendship && !wasPending:
+		change.add(FriendAdded, userID1, userID2)
+	case wasFriendship && !isFriendship:
+		if change.current != nil && change.current.IsBlockedBy(userID2) {
+			userID1, userID2 = userID2, userID1
+		}
+		change.add(FriendRemoved, userID1, userID2)
+	}
+
+	for _, pair := range [][2]string{{userID1, userID2}, {userID2, userID1}} {
+		wasBlocked := change.previous != nil && change.previous.IsBlockedBy(pair[0])
+		isBlocked := change.current != nil && change.current.IsBlockedBy(pair[0])
+		switch {
+		case isBlocked && !wasBlocked:
+			change.add(UserBlocked, pair[0], pair[1])
+		case wasBlocked && !isBlocked:
+			change.add(UserUnblocked, pair[0], pair[1])
+		}
+	}
+
+	if len(change.result) == 0 && change.typesChanged() {
+		change.add(RelationshipChanged, userID1, userID2)
+	}
+	return change.result
+}
+
+// userIDs returns the users of the relationship
+func (change *relationshipChange) userIDs() (string, string) {
+	relationship := change.current
+	if relationship == nil {
+		relationship = change.previous
+	}
+	return relationship.User1.UserID, relationship.User2.UserID
+}
+
+// typesChanged returns true when a user of the relationship has a new relationship type
+func (change *relationshipChange) typesChanged() bool {
+	if change.previous == nil || change.current == nil {
+		return true
+	}
+	for _, side := range []data.User{change.current.User1, change.current.User2} {
+		previousSide := change.previous.Side(side.UserID)
+		if previousSide == nil || previousSide.RelationshipType != side.RelationshipType {
+			return true
+		}
+	}
+	return false
+}
+
+func (change *relationshipChange) add(eventType Type, userID string, otherUserID string) {
+	event := &Event{
+		ID:          uuid.NewString(),
+		Type:        eventType,
+		UserID:      userID,
+		OtherUserID: otherUserID,
+		OccurredAt:  time.Now().UTC(),
+	}
+	if change.current != nil {
+		relationship := *change.current
+		relationship.ClearPreferences()
+		event.Relationship = &relationship
+		event.RelationshipID = relationship.ID
+	} else {
+		event.RelationshipID = change.previous.ID
+	}
+	change.result = append(change.result, event)
+}
@@ -0,0 +1,202 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+)
+
+func relationship(type1 data.RelationshipType, type2 data.RelationshipType) *data.Relationship {
+	return &data.Relationship{
+		ID:    "a2181017-5c53-422b-b6bc-036b27c04fc8",
+		User1: data.User{UserID: "c5825d3e-8a77-11eb-8dcd-0242ac130003", RelationshipType: type1},
+		User2: data.User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: type2},
+	}
+}
+
+func TestFromChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous *data.Relationship
+		current  *data.Relationship
+		expected []Type
+		userID   string
+	}{
+		{"invite", nil, relationship(data.PendingOutgoing, data.PendingIncoming), []Type{InviteSent}, "c5825d3e-8a77-11eb-8dcd-0242ac130003"},
+		{"accept", relationship(data.PendingOutgoing, data.PendingIncoming), relationship(data.Friend, data.Friend), []Type{InviteAccepted}, "e2382ea2-b5fa-4506-aa9d-d338aa52af44"},
+		{"decline", relationship(data.PendingOutgoing, data.PendingIncoming), relationship(data.None, data.None), []Type{InviteDeclined}, "e2382ea2-b5fa-4506-aa9d-d338aa52af44"},
+		{"cancel", relationship(data.PendingOutgoing, data.PendingIncoming), nil, []Type{InviteRemoved}, "c5825d3e-8a77-11eb-8dcd-0242ac130003"},
+		{"party", nil, relationship(data.Friend, data.Friend), []Type{FriendAdded}, "c5825d3e-8a77-11eb-8dcd-0242ac130003"},
+		{"unfriend", relationship(data.Friend, data.Friend), nil, []Type{FriendRemoved}, "c5825d3e-8a77-11eb-8dcd-0242ac130003"},
+		{"block friend", relationship(data.Friend, data.Friend), relationship(data.None, data.Blocked), []Type{FriendRemoved, UserBlocked}, "e2382ea2-b5fa-4506-aa9d-d338aa52af44"},
+		{"unblock", relationship(data.Blocked, data.None), relationship(data.None, data.None), []Type{UserUnblocked}, "c5825d3e-8a77-11eb-8dcd-0242ac130003"},
+		{"custom type", relationship(data.None, data.None), relationship("Rival", "Rival"), []Type{RelationshipChanged}, "c5825d3e-8a77-11eb-8dcd-0242ac130003"},
+		{"no change", relationship(data.Friend, data.Friend), relationship(data.Friend, data.Friend), []Type{}, ""},
+	}
+
+	for _, test := range tests {
+		events := FromChange(test.previous, test.current)
+
+		if len(events) != len(test.expected) {
+			t.Errorf("%s: expected %d events but got %d", test.name, len(test.expected), len(events))
+			continue
+		}
+		for i, event := range events {
+			if event.Type != test.expected[i] {
+				t.Errorf("%s: expected event %s but got %s", test.name, test.expected[i], event.Type)
+			}
+		}
+		if len(events) > 0 && events[0].UserID != test.userID {
+			t.Errorf("%s: expected the change to be made by %s but got %s", test.name, test.userID, events[0].UserID)
+		}
+	}
+}
+
+func TestFromChangeHidesPreferences(t *testing.T) {
+	current := relationship(data.Friend, data.Friend)
+	current.User1.Nickname = "Healer"
+
+	events := FromChange(nil, current)
+
+	if len(events) != 1 || events[0].Relationship.User1.Nickname != "" {
+		t.Error("Expected the preferences of the users to be removed from the event")
+	}
+	if current.User1.Nickname != "Healer" {
+		t.Error("Expected the saved relationship to keep its preferences")
+	}
+}
+
+func TestNATSPublisher(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// Fake NATS server accepting a connection and reading one message
+	published := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		conn.Write([]byte("INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n"))
+
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case line == "PING\r\n":
+				conn.Write([]byte("PONG\r\n"))
+			case strings.HasPrefix(line, "PUB "):
+				payload, _ := reader.ReadString('\n')
+				published <- line + payload
+				return
+			}
+		}
+	}()
+
+	publisher, err := NewNATSPublisher("nats://"+listener.Addr().String(), "friendslist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+
+	event := FromChange(nil, relationship(data.PendingOutgoing, data.PendingIncoming))[0]
+	err = publisher.Publish(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := <-published
+	lines := strings.SplitN(message, "\r\n", 2)
+	if !strings.HasPrefix(lines[0], "PUB friendslist.InviteSent ") {
+		t.Errorf("Expected the event to be published on its type subject but got %q", lines[0])
+	}
+	received := &Event{}
+	err = json.Unmarshal([]byte(strings.TrimSpace(lines[1])), received)
+	if err != nil || received.ID != event.ID {
+		t.Errorf("Expected the event as JSON payload but got %q", lines[1])
+	}
+}
+
+func TestNATSSubscriber(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// Fake NATS server sending back every published message to the subscription
+	subscribed := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		conn.Write([]byte("INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n"))
+
+		sid := ""
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case line == "PING\r\n":
+				conn.Write([]byte("PONG\r\n"))
+			case strings.HasPrefix(line, "SUB "):
+				fields := strings.Fields(line)
+				sid = fields[2]
+				subscribed <- fields[1]
+			case strings.HasPrefix(line, "PUB "):
+				fields := strings.Fields(line)
+				payload, _ := reader.ReadString('\n')
+				fmt.Fprintf(conn, "MSG %s %s %s\r\n%s", fields[1], sid, fields[2], payload)
+			}
+		}
+	}()
+
+	publisher, err := NewNATSPublisher("nats://"+listener.Addr().String(), "friendslist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+
+	received := make(chan *Event, 1)
+	unsubscribe := publisher.Subscribe(func(event *Event) {
+		received <- event
+	})
+	defer unsubscribe()
+	if subject := <-subscribed; subject != "friendslist.>" {
+		t.Errorf("Expected a subscription to every event of the service but got %s", subject)
+	}
+
+	event := FromChange(nil, relationship(data.PendingOutgoing, data.PendingIncoming))[0]
+	err = publisher.Publish(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-received:
+		if got.ID != event.ID || got.Type != InviteSent {
+			t.Errorf("Expected the published event but got %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the subscriber to receive the published event")
+	}
+}
@@ -0,0 +1,48 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// FanOut publishes every event to several publishers, like the broker and the webhooks
+// An event failing on one publisher is published again to all of them on the next attempt
+type FanOut struct {
+	mutex      sync.RWMutex
+	publishers []Publisher
+}
+
+// Add registers a publisher receiving the next events
+func (fanOut *FanOut) Add(publisher Publisher) {
+	fanOut.mutex.Lock()
+	defer fanOut.mutex.Unlock()
+	fanOut.publishers = append(fanOut.publishers, publisher)
+}
+
+// Publish publishes the event to every publisher, returning the first error
+func (fanOut *FanOut) Publish(ctx context.Context, event *Event) error {
+	fanOut.mutex.RLock()
+	defer fanOut.mutex.RUnlock()
+	var firstErr error
+	for _, publisher := range fanOut.publishers {
+		err := publisher.Publish(ctx, event)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close closes every publisher, returning the first error
+func (fanOut *FanOut) Close() error {
+	fanOut.mutex.RLock()
+	defer fanOut.mutex.RUnlock()
+	var firstErr error
+	for _, publisher := range fanOut.publishers {
+		err := publisher.Close()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,7 @@
+package events
+
+import (
+	baselog "github.com/Ubivius/microservice-friendslist/pkg/log"
+)
+
+var log = baselog.MLog.WithName("events")
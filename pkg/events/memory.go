@@ -0,0 +1,52 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBroker delivers the events to subscribers of the same process
+// It is used by the tests and when no broker is configured
+type MemoryBroker struct {
+	mutex       sync.RWMutex
+	subscribers map[int]func(*Event)
+	nextID      int
+}
+
+// NewMemoryBroker returns a broker without subscribers
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subscribers: map[int]func(*Event){}}
+}
+
+// Publish calls every subscriber with the event before returning
+func (broker *MemoryBroker) Publish(ctx context.Context, event *Event) error {
+	broker.mutex.RLock()
+	defer broker.mutex.RUnlock()
+	for _, subscriber := range broker.subscribers {
+		subscriber(event)
+	}
+	return nil
+}
+
+// Subscribe registers a function called with every published event, until the returned function is called
+func (broker *MemoryBroker) Subscribe(subscriber func(*Event)) (unsubscribe func()) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	id := broker.nextID
+	broker.nextID++
+	broker.subscribers[id] = subscriber
+
+	return func() {
+		broker.mutex.Lock()
+		defer broker.mutex.Unlock()
+		delete(broker.subscribers, id)
+	}
+}
+
+// Close removes every subscriber
+func (broker *MemoryBroker) Close() error {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	broker.subscribers = map[int]func(*Event){}
+	return nil
+}
@@ -0,0 +1,51 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Publisher sends the relationship events to the other services
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+	Close() error
+}
+
+// Subscriber receives the events published by every replica of the service
+// The memory broker only receives the events of its own replica
+type Subscriber interface {
+	Subscribe(subscriber func(*Event)) (unsubscribe func())
+}
+
+// NewPublisherFromEnv selects the event broker with EVENT_BROKER, memory or nats
+// The nats broker connects to NATS_URL and publishes each event on EVENT_SUBJECT_PREFIX.<type>
+func NewPublisherFromEnv() (Publisher, error) {
+	switch broker := os.Getenv("EVENT_BROKER"); broker {
+	case "", "memory":
+		return NewMemoryBroker(), nil
+	case "nats":
+		natsURL := os.Getenv("NATS_URL")
+		if natsURL == "" {
+			natsURL = "nats://nats:4222"
+		}
+		subjectPrefix := os.Getenv("EVENT_SUBJECT_PREFIX")
+		if subjectPrefix == "" {
+			subjectPrefix = "friendslist"
+		}
+		return NewNATSPublisher(natsURL, subjectPrefix)
+	default:
+		return nil, fmt.Errorf("unknown event broker %s", broker)
+	}
+}
+
+// PublishAll publishes the events in order, stopping at the first error
+func PublishAll(ctx context.Context, publisher Publisher, events Events) error {
+	for _, event := range events {
+		err := publisher.Publish(ctx, event)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+// errorAffinityNotAllowed : Friends list sort specific error
+var errorAffinityNotAllowed = fmt.Errorf("affinity is only shown to the owner of the friends list")
+
+// HandleChatEvent counts a message sent in a conversation in the affinity of its sender with the recipients
+func (relationshipHandler *RelationshipsHandler) HandleChatEvent(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "handleChatEvent")
+	defer span.End()
+
+	chatEvent := &data.ChatEvent{}
+	err := json.NewDecoder(request.Body).Decode(chatEvent)
+	if err != nil {
+		log.Error(err, "Error deserializing chat event")
+		http.Error(responseWriter, "Error reading chat event", http.StatusBadRequest)
+		return
+	}
+
+	err = chatEvent.ValidateChatEvent()
+	if err != nil {
+		log.Error(err, "Error validating chat event")
+		http.Error(responseWriter, fmt.Sprintf("Error validating chat event: %s", err), http.StatusBadRequest)
+		return
+	}
+	if chatEvent.OccurredAt.IsZero() {
+		chatEvent.OccurredAt = time.Now().UTC()
+	}
+
+	log.Info("HandleChatEvent request for userID", "id", chatEvent.SenderID, "type", chatEvent.Type, "event_id", chatEvent.ID)
+
+	err = relationshipHandler.db.AddAffinitySignal(request.Context(), &data.AffinitySignal{
+		ID:         "message:" + chatEvent.ID,
+		Type:       data.AffinityMessage,
+		UserIDs:    chatEvent.RecipientIDs,
+		SenderID:   chatEvent.SenderID,
+		OccurredAt: chatEvent.OccurredAt,
+	})
+	if err != nil {
+		log.Error(err, "Error handling chat event", "event_id", chatEvent.ID)
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// sortFriendsList orders the friends list of a user by the sort key of the request
+// Only the owner of the list can sort it by affinity, the affinities are then added to the friends
+func (relationshipHandler *RelationshipsHandler) sortFriendsList(request *http.Request, id string, friends *data.DetailedRelationships) error {
+	sortKey := request.URL.Query().Get("sort")
+	if sortKey == "affinity" {
+		if !isOwnerOrAdmin(request, id) {
+			return errorAffinityNotAllowed
+		}
+		err := relationshipHandler.applyAffinity(request.Context(), id, friends)
+		if err != nil {
+			return err
+		}
+	}
+	return sortFriends(friends, sortKey)
+}
+
+// applyAffinity sets the current affinity of the user with each friend
+func (relationshipHandler *RelationshipsHandler) applyAffinity(ctx context.Context, userID string, friends *data.DetailedRelationships) error {
+	friendIDs := make([]string, 0, len(*friends))
+	for _, friend := range *friends {
+		friendIDs = append(friendIDs, friend.User.ID)
+	}
+	affinities, err := relationshipHandler.db.GetAffinities(ctx, userID, friendIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, friend := range *friends {
+		friend.Affinity = affinities[friend.User.ID]
+	}
+	return nil
+}
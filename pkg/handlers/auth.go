@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// RoleAdmin is the realm role given to the Ubivius staff accounts
+const RoleAdmin = "admin"
+
+// tokenClaims holds the access token claims used by the handlers
+type tokenClaims struct {
+	Subject     string `json:"sub"`
+	RealmAccess struct {
+		Roles []string `json:"roles"`
+	} `json:"realm_access"`
+}
+
+// getTokenClaims extracts the claims from the bearer token of the request
+// The token signature is verified by the authentication middleware before reaching the handlers,
+// so we only need to decode its payload here
+func getTokenClaims(request *http.Request) *tokenClaims {
+	claims := &tokenClaims{}
+
+	parts := strings.Split(request.Header.Get("Authorization"), " ")
+	if len(parts) != 2 {
+		return claims
+	}
+
+	tokenParts := strings.Split(parts[1], ".")
+	if len(tokenParts) != 3 {
+		return claims
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(tokenParts[1], "="))
+	if err != nil {
+		log.Error(err, "Error decoding access token payload")
+		return claims
+	}
+
+	err = json.Unmarshal(payload, claims)
+	if err != nil {
+		log.Error(err, "Error deserializing access token claims")
+	}
+	return claims
+}
+
+// getCallerID returns the user ID of the authenticated caller, or an empty string if there is none
+func getCallerID(request *http.Request) string {
+	return getTokenClaims(request).Subject
+}
+
+// hasRole returns true when the authenticated caller has the specified realm role
+func hasRole(request *http.Request, role string) bool {
+	for _, callerRole := range getTokenClaims(request).RealmAccess.Roles {
+		if callerRole == role {
+			return true
+		}
+	}
+	return false
+}
+
+// isOwnerOrAdmin returns true when the caller is the specified user or a staff account
+func isOwnerOrAdmin(request *http.Request, userID string) bool {
+	return getCallerID(request) == userID || hasRole(request, RoleAdmin)
+}
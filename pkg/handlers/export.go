@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+// ExportRelationshipsByUserID streams every relationship involving a user as a downloadable JSON or CSV document
+// The format is selected with the format query parameter, JSON being the default
+func (relationshipHandler *RelationshipsHandler) ExportRelationshipsByUserID(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "exportRelationshipsByUserID")
+	defer span.End()
+	id := getUserID(request)
+
+	log.Info("ExportRelationshipsByUserID request for userID", "id", id)
+
+	if !isOwnerOrAdmin(request, id) {
+		log.Info("Export refused, caller is not the owner of the data", "id", id)
+		http.Error(responseWriter, "Not allowed to export the relationships of this user", http.StatusForbidden)
+		return
+	}
+
+	var err error
+	switch format := request.URL.Query().Get("format"); format {
+	case "", "json":
+		err = relationshipHandler.exportJSON(responseWriter, request, id)
+	case "csv":
+		err = relationshipHandler.exportCSV(responseWriter, request, id)
+	default:
+		http.Error(responseWriter, "Unsupported export format", http.StatusBadRequest)
+		return
+	}
+
+	// Headers are already sent at this point, the document is left truncated
+	if err != nil {
+		log.Error(err, "Error exporting relationships", "id", id)
+	}
+}
+
+func (relationshipHandler *RelationshipsHandler) exportJSON(responseWriter http.ResponseWriter, request *http.Request, userID string) error {
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"relationships-%s.json\"", userID))
+
+	header, _ := json.Marshal(userID)
+	exportedOn, _ := json.Marshal(time.Now().UTC().String())
+	_, err := fmt.Fprintf(responseWriter, "{\"user_id\":%s,\"exported_on\":%s,\"relationships\":[", header, exportedOn)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(responseWriter)
+	first := true
+	err = relationshipHandler.db.ExportRelationshipsByUserID(request.Context(), userID, func(relationship *data.Relationship) error {
+		if !first {
+			if _, err := responseWriter.Write([]byte(",")); err != nil {
+				return err
+			}
+		}
+		first = false
+		return encoder.Encode(relationship.ExportedBy(userID))
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = responseWriter.Write([]byte("]}\n"))
+	return err
+}
+
+func (relationshipHandler *RelationshipsHandler) exportCSV(responseWriter http.ResponseWriter, request *http.Request, userID string) error {
+	responseWriter.Header().Set("Content-Type", "text/csv")
+	responseWriter.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"relationships-%s.csv\"", userID))
+
+	writer := csv.NewWriter(responseWriter)
+	err := writer.Write(data.RelationshipExportHeader)
+	if err != nil {
+		return err
+	}
+
+	err = relationshipHandler.db.ExportRelationshipsByUserID(request.Context(), userID, func(relationship *data.Relationship) error {
+		return writer.Write(relationship.ExportRecord(userID))
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+// GetFeedByUserID returns a page of the activity feed of a user, newest first
+// Activities about users blocking or blocked by the user, or that the privacy settings of their friend hide, are left out
+func (relationshipHandler *RelationshipsHandler) GetFeedByUserID(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "getFeedByUserID")
+	defer span.End()
+	id := getUserID(request)
+
+	log.Info("GetFeedByUserID request for userID", "id", id)
+
+	if !isOwnerOrAdmin(request, id) {
+		http.Error(responseWriter, "Not allowed to read the feed of this user", http.StatusForbidden)
+		return
+	}
+
+	offset, limit, err := getPage(request, data.DefaultFeedPageSize, data.MaxFeedPageSize)
+	if err != nil {
+		http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	items, err := relationshipHandler.db.GetFeedByUserID(request.Context(), id, offset, limit)
+	if err != nil {
+		log.Error(err, "Error fetching feed")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	feed := &data.Feed{UserID: id}
+	if len(items) == limit {
+		feed.NextOffset = offset + limit
+	}
+	feed.Items, err = relationshipHandler.visibleFeedItems(request.Context(), id, items)
+	if err != nil {
+		log.Error(err, "Error filtering feed")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	relationshipHandler.addFeedUserDetails(feed.Items)
+
+	err = json.NewEncoder(responseWriter).Encode(feed)
+	if err != nil {
+		log.Error(err, "Error serializing feed")
+	}
+}
+
+// visibleFeedItems removes the activities the user isn't allowed to see anymore
+// Blocks and privacy settings are checked when the feed is read, they can change after the activity is saved
+func (relationshipHandler *RelationshipsHandler) visibleFeedItems(ctx context.Context, userID string, items data.FeedItems) (data.FeedItems, error) {
+	isBlocked := relationshipHandler.blockChecker(ctx, userID)
+
+	views := map[string]data.FriendsListView{}
+	var friends map[string]bool
+	visibleItems := data.FeedItems{}
+	for _, item := range items {
+		hidden, err := isBlocked(item.ActorID)
+		if err == nil && !hidden && item.Type == data.FeedFriendAdded {
+			hidden, err = isBlocked(item.SubjectID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hidden {
+			continue
+		}
+		if item.Type != data.FeedFriendAdded {
+			visibleItems = append(visibleItems, item)
+			continue
+		}
+
+		// The new friend of the actor is part of the friends list of the actor, shown as its privacy settings allow
+		view, ok := views[item.ActorID]
+		if !ok {
+			view, err = relationshipHandler.friendsListView(ctx, item.ActorID, userID)
+			if err != nil {
+				return nil, err
+			}
+			views[item.ActorID] = view
+		}
+		if view == data.FriendsListMutual && friends == nil {
+			friendIDs, err := relationshipHandler.db.GetFriendIDsByUserID(ctx, userID)
+			if err != nil {
+				return nil, err
+			}
+			friends = make(map[string]bool, len(friendIDs))
+			for _, friendID := range friendIDs {
+				friends[friendID] = true
+			}
+		}
+		if view == data.FriendsListFull || (view == data.FriendsListMutual && friends[item.SubjectID]) {
+			visibleItems = append(visibleItems, item)
+		}
+	}
+	return visibleItems, nil
+}
+
+// blockChecker returns a function telling if a user blocks or is blocked by the user, each user is checked once
+func (relationshipHandler *RelationshipsHandler) blockChecker(ctx context.Context, userID string) func(otherUserID string) (bool, error) {
+	blocked := map[string]bool{}
+	return func(otherUserID string) (bool, error) {
+		isBlocked, ok := blocked[otherUserID]
+		if ok {
+			return isBlocked, nil
+		}
+		relationship, err := relationshipHandler.db.GetRelationshipByUserIDs(ctx, userID, otherUserID)
+		if err != nil && err != data.ErrorRelationshipNotFound {
+			return false, err
+		}
+		blocked[otherUserID] = relationship != nil && (relationship.IsBlockedBy(userID) || relationship.IsBlockedBy(otherUserID))
+		return blocked[otherUserID], nil
+	}
+}
+
+// addFeedUserDetails adds the details of the users of the activities, an activity keeps only the user IDs when they can't be fetched
+func (relationshipHandler *RelationshipsHandler) addFeedUserDetails(items data.FeedItems) {
+	users := map[string]*data.DetailedUser{}
+	userDetails := func(userID string) *data.DetailedUser {
+		user, ok := users[userID]
+		if !ok {
+			var err error
+			user, err = relationshipHandler.db.GetUserByID(userID)
+			if err != nil {
+				log.Error(err, "Error fetching user details of a feed item", "user_id", userID)
+			}
+			users[userID] = user
+		}
+		return user
+	}
+	for _, item := range items {
+		item.Actor = userDetails(item.ActorID)
+		item.Subject = userDetails(item.SubjectID)
+	}
+}
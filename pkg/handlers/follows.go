@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+// Follow makes the caller follow a user, no acceptance is needed
+func (relationshipHandler *RelationshipsHandler) Follow(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "follow")
+	defer span.End()
+	id := getUserID(request)
+	callerID := getCallerID(request)
+
+	log.Info("Follow request for userID", "id", id)
+
+	if callerID == "" {
+		http.Error(responseWriter, "Missing user in access token", http.StatusUnauthorized)
+		return
+	}
+
+	follow, err := relationshipHandler.db.AddFollow(request.Context(), callerID, id)
+	switch err {
+	case nil:
+		responseWriter.WriteHeader(http.StatusCreated)
+		err = json.NewEncoder(responseWriter).Encode(follow)
+		if err != nil {
+			log.Error(err, "Error serializing follow")
+		}
+		return
+	case data.ErrorUserNotFound:
+		log.Error(err, "UserID doesn't exist")
+		http.Error(responseWriter, "UserID doesn't exist", http.StatusBadRequest)
+		return
+	case data.ErrorSameUserID:
+		log.Error(err, "User can't follow themselves")
+		http.Error(responseWriter, "Users can't follow themselves", http.StatusBadRequest)
+		return
+	case data.ErrorFollowExist:
+		log.Error(err, "Follow already exist")
+		http.Error(responseWriter, "Already following this user", http.StatusBadRequest)
+		return
+	case data.ErrorUserBlocked:
+		log.Info("Follow refused, one of the users blocked the other", "id", id)
+		http.Error(responseWriter, "Can't follow this user", http.StatusForbidden)
+		return
+	default:
+		log.Error(err, "Error adding follow")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// Unfollow makes the caller stop following a user
+func (relationshipHandler *RelationshipsHandler) Unfollow(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "unfollow")
+	defer span.End()
+	id := getUserID(request)
+	callerID := getCallerID(request)
+
+	log.Info("Unfollow request for userID", "id", id)
+
+	if callerID == "" {
+		http.Error(responseWriter, "Missing user in access token", http.StatusUnauthorized)
+		return
+	}
+
+	err := relationshipHandler.db.DeleteFollow(request.Context(), callerID, id)
+	switch err {
+	case nil:
+		responseWriter.WriteHeader(http.StatusNoContent)
+		return
+	case data.ErrorFollowNotFound:
+		log.Error(err, "Error deleting follow, the caller doesn't follow the user")
+		http.Error(responseWriter, "Follow not found", http.StatusNotFound)
+		return
+	default:
+		log.Error(err, "Error deleting follow")
+		http.Error(responseWriter, "Error deleting follow", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetFollowersByUserID returns a page of the users following a user, newest first
+func (relationshipHandler *RelationshipsHandler) GetFollowersByUserID(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "getFollowersByUserID")
+	defer span.End()
+	id := getUserID(request)
+
+	log.Info("GetFollowersByUserID request for userID", "id", id)
+
+	relationshipHandler.writeFollowList(responseWriter, request, id, relationshipHandler.db.GetFollowersByUserID, func(followCounts *data.FollowCounts) int64 {
+		return followCounts.Followers
+	})
+}
+
+// GetFollowingByUserID returns a page of the users followed by a user, newest first
+func (relationshipHandler *RelationshipsHandler) GetFollowingByUserID(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "getFollowingByUserID")
+	defer span.End()
+	id := getUserID(request)
+
+	log.Info("GetFollowingByUserID request for userID", "id", id)
+
+	relationshipHandler.writeFollowList(responseWriter, request, id, relationshipHandler.db.GetFollowingByUserID, func(followCounts *data.FollowCounts) int64 {
+		return followCounts.Following
+	})
+}
+
+// writeFollowList answers with a page of follows and the total from the follow counters
+func (relationshipHandler *RelationshipsHandler) writeFollowList(
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userID string,
+	getFollows func(ctx context.Context, userID string, offset int, limit int) (*data.DetailedRelationships, error),
+	total func(*data.FollowCounts) int64,
+) {
+	offset, limit, err := getPage(request, data.DefaultFollowPageSize, data.MaxFollowPageSize)
+	if err != nil {
+		http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	relationships, err := getFollows(request.Context(), userID, offset, limit)
+	if err != nil {
+		log.Error(err, "Error fetching follows")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	followCounts, err := relationshipHandler.db.GetFollowCounts(request.Context(), userID)
+	if err != nil {
+		log.Error(err, "Error fetching follow counts")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	followList := &data.FollowList{UserID: userID, Total: total(followCounts), Relationships: relationships}
+	err = json.NewEncoder(responseWriter).Encode(followList)
+	if err != nil {
+		log.Error(err, "Error serializing follows")
+	}
+}
+
+// getPage reads the offset and limit query parameters of a paginated list
+func getPage(request *http.Request, defaultLimit int, maxLimit int) (int, int, error) {
+	offset, limit := 0, defaultLimit
+	query := request.URL.Query()
+
+	if value := query.Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("offset must be a positive integer")
+		}
+		offset = parsed
+	}
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+		limit = parsed
+	}
+	return offset, limit, nil
+}
+
+// getLimit reads the limit query parameter of a list returned in a single page, an offset is refused
+func getLimit(request *http.Request, defaultLimit int, maxLimit int) (int, error) {
+	if request.URL.Query().Get("offset") != "" {
+		return 0, fmt.Errorf("offset isn't supported, the list is returned in a single page")
+	}
+	_, limit, err := getPage(request, defaultLimit, maxLimit)
+	return limit, err
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+)
+
+// AddFriendCode generates a new friend code for a user, with the options of the received JSON if there is one
+func (relationshipHandler *RelationshipsHandler) AddFriendCode(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "addFriendCode")
+	defer span.End()
+	id := getUserID(request)
+
+	log.Info("AddFriendCode request for userID", "id", id)
+
+	if !isOwnerOrAdmin(request, id) {
+		http.Error(responseWriter, "Not allowed to create friend codes for this user", http.StatusForbidden)
+		return
+	}
+
+	friendCodeRequest := &data.FriendCodeRequest{}
+	err := json.NewDecoder(request.Body).Decode(friendCodeRequest)
+	if err != nil && err != io.EOF {
+		log.Error(err, "Error deserializing friend code request")
+		http.Error(responseWriter, "Error reading friend code request", http.StatusBadRequest)
+		return
+	}
+
+	err = friendCodeRequest.ValidateFriendCodeRequest()
+	if err != nil {
+		log.Error(err, "Error validating friend code request")
+		http.Error(responseWriter, fmt.Sprintf("Error validating friend code request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	friendCode := friendCodeRequest.NewFriendCode(id)
+	err = relationshipHandler.db.AddFriendCode(request.Context(), friendCode)
+	switch err {
+	case nil:
+		responseWriter.WriteHeader(http.StatusCreated)
+		err = json.NewEncoder(responseWriter).Encode(friendCode)
+		if err != nil {
+			log.Error(err, "Error serializing friend code")
+		}
+		return
+	case data.ErrorUserNotFound:
+		log.Error(err, "UserID doesn't exist")
+		http.Error(responseWriter, "UserID doesn't exist", http.StatusBadRequest)
+		return
+	default:
+		log.Error(err, "Error adding friend code")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetFriendCodesByUserID returns the friend codes of a user that can still be redeemed
+func (relationshipHandler *RelationshipsHandler) GetFriendCodesByUserID(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "getFriendCodesByUserID")
+	defer span.End()
+	id := getUserID(request)
+
+	log.Info("GetFriendCodesByUserID request for userID", "id", id)
+
+	if !isOwnerOrAdmin(request, id) {
+		http.Error(responseWriter, "Not allowed to read the friend codes of this user", http.StatusForbidden)
+		return
+	}
+
+	friendCodes, err := relationshipHandler.db.GetFriendCodesByUserID(request.Context(), id)
+	if err != nil {
+		log.Error(err, "Error fetching friend codes")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(responseWriter).Encode(friendCodes)
+	if err != nil {
+		log.Error(err, "Error serializing friend codes")
+	}
+}
+
+// DeleteFriendCode revokes a friend code
+func (relationshipHandler *RelationshipsHandler) DeleteFriendCode(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "deleteFriendCode")
+	defer span.End()
+	code := getFriendCode(request)
+
+	log.Info("DeleteFriendCode request", "code", code)
+
+	friendCode, err := relationshipHandler.db.GetFriendCode(request.Context(), code)
+	if err == nil && !isOwnerOrAdmin(request, friendCode.UserID) {
+		http.Error(responseWriter, "Not allowed to revoke this friend code", http.StatusForbidden)
+		return
+	}
+	if err == nil {
+		err = relationshipHandler.db.DeleteFriendCode(request.Context(), code)
+	}
+
+	switch err {
+	case nil:
+		responseWriter.WriteHeader(http.StatusNoContent)
+		return
+	case data.ErrorFriendCodeNotFound:
+		log.Error(err, "Error deleting friend code, code does not exist")
+		http.Error(responseWriter, "Friend code not found", http.StatusNotFound)
+		return
+	default:
+		log.Error(err, "Error deleting friend code")
+		http.Error(responseWriter, "Error deleting friend code", http.StatusInternalServerError)
+		return
+	}
+}
+
+// RedeemFriendCode sends a friend request from the caller to the owner of the friend code,
+// or makes them friends directly when the code is auto accepted
+func (relationshipHandler *RelationshipsHandler) RedeemFriendCode(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "redeemFriendCode")
+	defer span.End()
+	code := getFriendCode(request)
+	callerID := getCallerID(request)
+
+	log.Info("RedeemFriendCode request", "code", code, "user_id", callerID)
+
+	if callerID == "" {
+		http.Error(responseWriter, "Missing user in access token", http.StatusUnauthorized)
+		return
+	}
+
+	relationship, err := relationshipHandler.db.RedeemFriendCode(request.Context(), code, callerID)
+	if err == data.ErrorFriendCodeNotFound {
+		log.Error(err, "Error redeeming friend code, code does not exist or expired")
+		http.Error(responseWriter, "Friend code not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeAddRelationshipError(responseWriter, err)
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(responseWriter).Encode(relationship)
+	if err != nil {
+		log.Error(err, "Error serializing relationship")
+	}
+}
+
+// getFriendCode extracts the friend code from the URL
+// The verification of this variable is handled by gorilla/mux
+func getFriendCode(request *http.Request) string {
+	vars := mux.Vars(request)
+	code := vars["code"]
+
+	return code
+}
@@ -2,13 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"sort"
 
 	"github.com/Ubivius/microservice-friendslist/pkg/data"
 	"go.opentelemetry.io/otel"
 )
 
-// GetFriendsListByUserID returns all the relationships of a user from the database
+// GetFriendsListByUserID returns the friends of a user as seen by the caller
+// The owner sees every friend, other users see what the privacy settings of the owner allow
 func (relationshipHandler *RelationshipsHandler) GetFriendsListByUserID(responseWriter http.ResponseWriter, request *http.Request) {
 	_, span := otel.Tracer("friendslist").Start(request.Context(), "getFriendsListByUserID")
 	defer span.End()
@@ -16,24 +19,144 @@ func (relationshipHandler *RelationshipsHandler) GetFriendsListByUserID(response
 
 	log.Info("GetFriendsListByUserID request for userID", "id", id)
 
-	friends, err := relationshipHandler.db.GetFriendsListByUserID(request.Context(), id)
+	statuses, err := parseStatusFilter(request.URL.Query().Get("status"), nil)
+	if err != nil {
+		http.Error(responseWriter, "Unsupported status", http.StatusBadRequest)
+		return
+	}
+
+	friends, view, ok := relationshipHandler.readFriendsList(responseWriter, request, id)
+	if !ok {
+		return
+	}
+
+	// Statuses aren't shown to viewers only allowed to count the friends
+	if statuses != nil && view == data.FriendsListCount {
+		http.Error(responseWriter, "Friends list is private", http.StatusForbidden)
+		return
+	}
+	if statuses != nil {
+		err = relationshipHandler.applyPresence(request.Context(), friends)
+		if err == nil {
+			friends = filterFriendsByStatus(friends, statuses)
+		}
+	}
+	if err == nil {
+		err = relationshipHandler.sortFriendsList(request, id, friends)
+	}
 	switch err {
 	case nil:
-		err = json.NewEncoder(responseWriter).Encode(friends)
+		if view == data.FriendsListCount {
+			err = json.NewEncoder(responseWriter).Encode(&data.FriendsCount{UserID: id, Count: len(*friends)})
+		} else {
+			err = json.NewEncoder(responseWriter).Encode(friends)
+		}
 		if err != nil {
 			log.Error(err, "Error serializing friends")
 		}
 		return
+	case errorUnsupportedSort:
+		http.Error(responseWriter, "Unsupported sort", http.StatusBadRequest)
+		return
+	case errorAffinityNotAllowed:
+		http.Error(responseWriter, "Not allowed to sort the friends of this user by affinity", http.StatusForbidden)
+		return
+	default:
+		log.Error(err, "Error fetching friends")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+}
+
+// readFriendsList returns the friends of a user the caller is allowed to see, with the view of the caller
+// The request is answered when the friends can't be read
+func (relationshipHandler *RelationshipsHandler) readFriendsList(responseWriter http.ResponseWriter, request *http.Request, id string) (*data.DetailedRelationships, data.FriendsListView, bool) {
+	viewerID := getCallerID(request)
+	isOwner := isOwnerOrAdmin(request, id)
+	view := data.FriendsListFull
+	if !isOwner {
+		var err error
+		view, err = relationshipHandler.friendsListView(request.Context(), id, viewerID)
+		if err != nil {
+			log.Error(err, "Error fetching user settings")
+			http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+			return nil, view, false
+		}
+	}
+	if view == data.FriendsListHidden {
+		log.Info("Friends list hidden from the caller", "id", id)
+		http.Error(responseWriter, "Friends list is private", http.StatusForbidden)
+		return nil, view, false
+	}
+
+	// Friend groups are private, only their owner can filter by group
+	var friendGroup *data.FriendGroup
+	if groupID := request.URL.Query().Get("group"); groupID != "" {
+		if !isOwner {
+			http.Error(responseWriter, "Not allowed to read the friend groups of this user", http.StatusForbidden)
+			return nil, view, false
+		}
+		var err error
+		friendGroup, err = relationshipHandler.getFriendGroup(request.Context(), id, groupID)
+		if err == data.ErrorFriendGroupNotFound {
+			http.Error(responseWriter, "Friend group not found", http.StatusNotFound)
+			return nil, view, false
+		}
+		if err != nil {
+			log.Error(err, "Error fetching friend group")
+			http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+			return nil, view, false
+		}
+	}
+
+	friends, err := relationshipHandler.db.GetFriendsListByUserID(request.Context(), id)
+	if err == nil && !isOwner {
+		friends, err = relationshipHandler.filterFriendsForViewer(request.Context(), friends, viewerID, view)
+	}
+	if err == nil && friendGroup != nil {
+		friends = filterFriendsByGroup(friends, friendGroup)
+	}
+	// Viewers only allowed to count the friends see a count of 0 rather than an error
+	if err == data.ErrorRelationshipNotFound && view == data.FriendsListCount {
+		friends, err = &data.DetailedRelationships{}, nil
+	}
+	switch err {
+	case nil:
+		return friends, view, true
 	case data.ErrorRelationshipNotFound:
 		log.Error(err, "Friends not found")
 		http.Error(responseWriter, "Friends not found", http.StatusNotFound)
-		return
+		return nil, view, false
 	default:
 		log.Error(err, "Error fetching friends")
 		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, view, false
 	}
+}
 
+// errorUnsupportedSort : Friends list sort specific error
+var errorUnsupportedSort = fmt.Errorf("unsupported sort")
+
+// sortFriends orders a friends list by the sort key of the request, the stored order being kept otherwise
+func sortFriends(friends *data.DetailedRelationships, sortKey string) error {
+	switch sortKey {
+	case "":
+		return nil
+	case "favourite":
+		// Favourites first
+		sort.SliceStable(*friends, func(i, j int) bool {
+			return (*friends)[i].Favourite && !(*friends)[j].Favourite
+		})
+		return nil
+	case "affinity":
+		// Closest friends first, the affinities are set by applyAffinity
+		sort.SliceStable(*friends, func(i, j int) bool {
+			return (*friends)[i].Affinity > (*friends)[j].Affinity
+		})
+		return nil
+	}
+	return errorUnsupportedSort
 }
 
 // GetInvitesListByUserID returns all the invites of a user from the database
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+)
+
+// AddFriendGroup creates a new friend group for a user from the received JSON
+func (relationshipHandler *RelationshipsHandler) AddFriendGroup(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "addFriendGroup")
+	defer span.End()
+	id := getUserID(request)
+
+	log.Info("AddFriendGroup request for userID", "id", id)
+
+	if !isOwnerOrAdmin(request, id) {
+		http.Error(responseWriter, "Not allowed to create friend groups for this user", http.StatusForbidden)
+		return
+	}
+
+	friendGroup, ok := decodeFriendGroup(responseWriter, request)
+	if !ok {
+		return
+	}
+	friendGroup.UserID = id
+
+	err := relationshipHandler.db.AddFriendGroup(request.Context(), friendGroup)
+	switch err {
+	case nil:
+		responseWriter.WriteHeader(http.StatusCreated)
+		err = json.NewEncoder(responseWriter).Encode(friendGroup)
+		if err != nil {
+			log.Error(err, "Error serializing friend group")
+		}
+		return
+	case data.ErrorInvalidGroupMember:
+		log.Error(err, "Friend group member isn't a friend of the user")
+		http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		log.Error(err, "Error adding friend group")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetFriendGroupsByUserID returns the friend groups of a user
+func (relationshipHandler *RelationshipsHandler) GetFriendGroupsByUserID(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "getFriendGroupsByUserID")
+	defer span.End()
+	id := getUserID(request)
+
+	log.Info("GetFriendGroupsByUserID request for userID", "id", id)
+
+	if !isOwnerOrAdmin(request, id) {
+		http.Error(responseWriter, "Not allowed to read the friend groups of this user", http.StatusForbidden)
+		return
+	}
+
+	friendGroups, err := relationshipHandler.db.GetFriendGroupsByUserID(request.Context(), id)
+	if err != nil {
+		log.Error(err, "Error fetching friend groups")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(responseWriter).Encode(friendGroups)
+	if err != nil {
+		log.Error(err, "Error serializing friend groups")
+	}
+}
+
+// UpdateFriendGroup renames a friend group and replaces its members with the received JSON
+func (relationshipHandler *RelationshipsHandler) UpdateFriendGroup(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "updateFriendGroup")
+	defer span.End()
+	id := getUserID(request)
+	groupID := getGroupID(request)
+
+	log.Info("UpdateFriendGroup request", "id", id, "group_id", groupID)
+
+	if !isOwnerOrAdmin(request, id) {
+		http.Error(responseWriter, "Not allowed to update the friend groups of this user", http.StatusForbidden)
+		return
+	}
+
+	friendGroup, ok := decodeFriendGroup(responseWriter, request)
+	if !ok {
+		return
+	}
+	friendGroup.ID = groupID
+	friendGroup.UserID = id
+
+	err := relationshipHandler.db.UpdateFriendGroup(request.Context(), friendGroup)
+	switch err {
+	case nil:
+		responseWriter.WriteHeader(http.StatusNoContent)
+		return
+	case data.ErrorFriendGroupNotFound:
+		log.Error(err, "Friend group not found")
+		http.Error(responseWriter, "Friend group not found", http.StatusNotFound)
+		return
+	case data.ErrorInvalidGroupMember:
+		log.Error(err, "Friend group member isn't a friend of the user")
+		http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		log.Error(err, "Error updating friend group")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteFriendGroup deletes a friend group, the friendships in it are kept
+func (relationshipHandler *RelationshipsHandler) DeleteFriendGroup(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "deleteFriendGroup")
+	defer span.End()
+	id := getUserID(request)
+	groupID := getGroupID(request)
+
+	log.Info("DeleteFriendGroup request", "id", id, "group_id", groupID)
+
+	if !isOwnerOrAdmin(request, id) {
+		http.Error(responseWriter, "Not allowed to delete the friend groups of this user", http.StatusForbidden)
+		return
+	}
+
+	_, err := relationshipHandler.getFriendGroup(request.Context(), id, groupID)
+	if err == nil {
+		err = relationshipHandler.db.DeleteFriendGroup(request.Context(), groupID)
+	}
+
+	switch err {
+	case nil:
+		responseWriter.WriteHeader(http.StatusNoContent)
+		return
+	case data.ErrorFriendGroupNotFound:
+		log.Error(err, "Error deleting friend group, group does not exist")
+		http.Error(responseWriter, "Friend group not found", http.StatusNotFound)
+		return
+	default:
+		log.Error(err, "Error deleting friend group")
+		http.Error(responseWriter, "Error deleting friend group", http.StatusInternalServerError)
+		return
+	}
+}
+
+// getFriendGroup returns a friend group of the user, groups of other users are reported as not found
+func (relationshipHandler *RelationshipsHandler) getFriendGroup(ctx context.Context, userID string, groupID string) (*data.FriendGroup, error) {
+	friendGroup, err := relationshipHandler.db.GetFriendGroup(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if friendGroup.UserID != userID {
+		return nil, data.ErrorFriendGroupNotFound
+	}
+	return friendGroup, nil
+}
+
+// filterFriendsByGroup keeps the friends that are members of the friend group
+func filterFriendsByGroup(friends *data.DetailedRelationships, friendGroup *data.FriendGroup) *data.DetailedRelationships {
+	groupFriends := data.DetailedRelationships{}
+	for _, friend := range *friends {
+		if friendGroup.Contains(friend.ID) {
+			groupFriends = append(groupFriends, friend)
+		}
+	}
+	return &groupFriends
+}
+
+// decodeFriendGroup reads and validates the friend group of the request body
+// The request is answered when the friend group is invalid
+func decodeFriendGroup(responseWriter http.ResponseWriter, request *http.Request) (*data.FriendGroup, bool) {
+	friendGroup := &data.FriendGroup{}
+	err := json.NewDecoder(request.Body).Decode(friendGroup)
+	if err != nil {
+		log.Error(err, "Error deserializing friend group")
+		http.Error(responseWriter, "Error reading friend group", http.StatusBadRequest)
+		return nil, false
+	}
+
+	err = friendGroup.ValidateFriendGroup()
+	if err != nil {
+		log.Error(err, "Error validating friend group")
+		http.Error(responseWriter, fmt.Sprintf("Error validating friend group: %s", err), http.StatusBadRequest)
+		return nil, false
+	}
+	return friendGroup, true
+}
+
+// getGroupID extracts the friend group ID from the URL
+// The verification of this variable is handled by gorilla/mux
+func getGroupID(request *http.Request) string {
+	vars := mux.Vars(request)
+	groupID := vars["group_id"]
+
+	return groupID
+}
@@ -2,22 +2,55 @@ package handlers
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Ubivius/microservice-friendslist/pkg/data"
 	"github.com/Ubivius/microservice-friendslist/pkg/database"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
 	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
 )
 
+// testEvents receives the events published by the mock database
+var testEvents = events.NewMemoryBroker()
+
 func newRelationshipDB() database.RelationshipDB {
-	return database.NewMockRelationships()
+	return database.NewMockRelationships(testEvents)
+}
+
+// failingDeleteDB is a database failing to delete the relationships of a user, like when the outbox can't be written
+type failingDeleteDB struct {
+	database.RelationshipDB
+}
+
+func (db *failingDeleteDB) DeleteUserRelationships(ctx context.Context, userID string) (int64, error) {
+	return 0, errors.New("outbox unavailable")
+}
+
+// withBearerToken adds an unsigned access token for the user to the request
+// Signatures are checked by the authentication middleware, which is not part of these tests
+func withBearerToken(request *http.Request, userID string, roles ...string) *http.Request {
+	claims := map[string]interface{}{
+		"sub":          userID,
+		"realm_access": map[string][]string{"roles": roles},
+	}
+	payload, _ := json.Marshal(claims)
+	token := "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".c2lnbmF0dXJl"
+	request.Header.Set("Authorization", "Bearer "+token)
+	return request
 }
 
 func TestGetExistingFriendsListByUserID(t *testing.T) {
 	request := httptest.NewRequest(http.MethodGet, "/friends/a2181017-5c53-422b-b6bc-036b27c04fc8", nil)
+	request = withBearerToken(request, "a2181017-5c53-422b-b6bc-036b27c04fc8")
 	response := httptest.NewRecorder()
 
 	productHandler := NewRelationshipsHandler(newRelationshipDB())
@@ -40,6 +73,7 @@ func TestGetExistingFriendsListByUserID(t *testing.T) {
 
 func TestGetNonExistingFriendsListByUserID(t *testing.T) {
 	request := httptest.NewRequest(http.MethodGet, "/friends/e2382ea2-b5fa-4506-aa9d-d338aa52af44", nil)
+	request = withBearerToken(request, "e2382ea2-b5fa-4506-aa9d-d338aa52af44")
 	response := httptest.NewRecorder()
 
 	productHandler := NewRelationshipsHandler(newRelationshipDB())
@@ -130,8 +164,8 @@ func TestAddRelationship(t *testing.T) {
 func TestAddRelationshipThatAlreadyExists(t *testing.T) {
 	// Creating request body
 	body := &data.Relationship{
-		User1: 			data.User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: data.PendingIncoming},
-		User2:       	data.User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: data.PendingOutgoing},
+		User1: 			data.User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: data.PendingOutgoing},
+		User2:       	data.User{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44", RelationshipType: data.PendingIncoming},
 		ConversationID: "",
 	}
 
@@ -322,3 +356,1676 @@ func TestDeleteNonExistantRelationship(t *testing.T) {
 		t.Error("Expected response : Relationship not found")
 	}
 }
+
+func TestExportRelationshipsByUserIDAsJSON(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/users/a2181017-5c53-422b-b6bc-036b27c04fc8/relationships/export", nil)
+	request = withBearerToken(request, "a2181017-5c53-422b-b6bc-036b27c04fc8")
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	// Mocking gorilla/mux vars
+	vars := map[string]string{
+		"user_id": "a2181017-5c53-422b-b6bc-036b27c04fc8",
+	}
+	request = mux.SetURLVars(request, vars)
+
+	relationshipHandler.ExportRelationshipsByUserID(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected status code %d but got : %d", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Header().Get("Content-Disposition"), "attachment") {
+		t.Error("Expected the export to be sent as an attachment")
+	}
+
+	export := struct {
+		UserID        string             `json:"user_id"`
+		Relationships data.Relationships `json:"relationships"`
+	}{}
+	err := json.Unmarshal(response.Body.Bytes(), &export)
+	if err != nil {
+		t.Fatal("Export is not a valid json document : ", err)
+	}
+	if len(export.Relationships) == 0 {
+		t.Error("Expected relationships in the export")
+	}
+	for _, relationship := range export.Relationships {
+		if relationship.User1.UserID != export.UserID && relationship.User2.UserID != export.UserID {
+			t.Errorf("Relationship %s does not involve the exported user", relationship.ID)
+		}
+	}
+}
+
+func TestExportRelationshipsByUserIDAsCSV(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/users/a2181017-5c53-422b-b6bc-036b27c04fc8/relationships/export?format=csv", nil)
+	request = withBearerToken(request, "a2181017-5c53-422b-b6bc-036b27c04fc8")
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	// Mocking gorilla/mux vars
+	vars := map[string]string{
+		"user_id": "a2181017-5c53-422b-b6bc-036b27c04fc8",
+	}
+	request = mux.SetURLVars(request, vars)
+
+	relationshipHandler.ExportRelationshipsByUserID(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected status code %d but got : %d", http.StatusOK, response.Code)
+	}
+	if !strings.HasPrefix(response.Body.String(), "id,user_1_id,user_1_relationship_type") {
+		t.Error("Missing CSV header from export")
+	}
+	if !strings.Contains(response.Body.String(), "f171ea04-8a77-11eb-8dcd-0242ac130003") {
+		t.Error("Missing elements from expected results")
+	}
+}
+
+func TestExportRelationshipsIncludesFollows(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	userID := "3e4f5a6b-8a83-11eb-8dcd-0242ac130003"
+	_, err := relationshipHandler.db.AddFollow(context.Background(), userID, "4f5a6b7c-8a83-11eb-8dcd-0242ac130003")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/users/"+userID+"/relationships/export?format=csv", nil)
+	request = withBearerToken(request, userID)
+	request = mux.SetURLVars(request, map[string]string{"user_id": userID})
+	response := httptest.NewRecorder()
+	relationshipHandler.ExportRelationshipsByUserID(response, request)
+
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), userID+",Following,4f5a6b7c-8a83-11eb-8dcd-0242ac130003,Follower") {
+		t.Errorf("Expected the follow in the export but got %d : %s", response.Code, response.Body.String())
+	}
+}
+
+func TestExportRelationshipsOfAnotherUser(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/users/a2181017-5c53-422b-b6bc-036b27c04fc8/relationships/export", nil)
+	request = withBearerToken(request, "e2382ea2-b5fa-4506-aa9d-d338aa52af44")
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	// Mocking gorilla/mux vars
+	vars := map[string]string{
+		"user_id": "a2181017-5c53-422b-b6bc-036b27c04fc8",
+	}
+	request = mux.SetURLVars(request, vars)
+
+	relationshipHandler.ExportRelationshipsByUserID(response, request)
+
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d but got : %d", http.StatusForbidden, response.Code)
+	}
+}
+
+func TestMergeUserRelationships(t *testing.T) {
+	// Creating request body
+	body := &data.MergeRequest{
+		SourceUserID: "0af831ea-8a78-11eb-8dcd-0242ac130003",
+		TargetUserID: "f171ea04-8a77-11eb-8dcd-0242ac130003",
+	}
+	bodyBytes, _ := json.Marshal(body)
+
+	request := httptest.NewRequest(http.MethodPost, "/admin/merges", strings.NewReader(string(bodyBytes)))
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	relationshipHandler.MergeUserRelationships(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected status code %d but got : %d", http.StatusOK, response.Code)
+	}
+
+	// Both users were friends with the same user, the friendship of the target user is kept
+	result := &data.MergeResult{}
+	err := json.Unmarshal(response.Body.Bytes(), result)
+	if err != nil {
+		t.Fatal("Merge result is not a valid json struct : ", err)
+	}
+	if result.Dropped != 1 || result.Moved != 0 || result.Replaced != 0 {
+		t.Errorf("Unexpected merge result %+v", result)
+	}
+}
+
+func TestMergeUserRelationshipsMovesFollowsAndFriendGroups(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	ctx := context.Background()
+	sourceUserID := "5a6b7c8d-8a84-11eb-8dcd-0242ac130003"
+	targetUserID := "6b7c8d9e-8a84-11eb-8dcd-0242ac130003"
+	otherUserID := "7c8d9eaf-8a84-11eb-8dcd-0242ac130003"
+
+	// The source user follows the other user and the target user, the target user already follows the other user
+	for _, follow := range [][2]string{{sourceUserID, otherUserID}, {sourceUserID, targetUserID}, {targetUserID, otherUserID}, {otherUserID, sourceUserID}} {
+		_, err := relationshipHandler.db.AddFollow(ctx, follow[0], follow[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := relationshipHandler.db.AddFriendGroup(ctx, &data.FriendGroup{UserID: sourceUserID, Name: "Raid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bodyBytes, _ := json.Marshal(&data.MergeRequest{SourceUserID: sourceUserID, TargetUserID: targetUserID})
+	request := httptest.NewRequest(http.MethodPost, "/admin/merges", strings.NewReader(string(bodyBytes)))
+	response := httptest.NewRecorder()
+	relationshipHandler.MergeUserRelationships(response, request)
+
+	result := &data.MergeResult{}
+	err = json.Unmarshal(response.Body.Bytes(), result)
+	if err != nil {
+		t.Fatal("Merge result is not a valid json struct : ", err)
+	}
+	if result.MovedFollows != 1 || result.DroppedFollows != 2 || result.MovedGroups != 1 {
+		t.Errorf("Unexpected merge result %+v", result)
+	}
+
+	followCounts, err := relationshipHandler.db.GetFollowCounts(ctx, targetUserID)
+	if err != nil || followCounts.Following != 1 || followCounts.Followers != 1 {
+		t.Errorf("Unexpected follow counts of the target user %+v, %v", followCounts, err)
+	}
+	friendGroups, err := relationshipHandler.db.GetFriendGroupsByUserID(ctx, targetUserID)
+	if err != nil || len(friendGroups) != 1 {
+		t.Errorf("Expected the friend group of the source user but got %+v, %v", friendGroups, err)
+	}
+}
+
+func TestMergeUserRelationshipsWithoutAdminRole(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPost, "/admin/merges", strings.NewReader("{}"))
+	request = withBearerToken(request, "a2181017-5c53-422b-b6bc-036b27c04fc8")
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	// Create a router for middleware because function attachment is handled by gorilla/mux
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/merges", relationshipHandler.MergeUserRelationships)
+	router.Use(relationshipHandler.MiddlewareAdminOnly)
+
+	// Server http on our router
+	router.ServeHTTP(response, request)
+
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d but got : %d", http.StatusForbidden, response.Code)
+	}
+}
+
+func TestAddRelationshipOverInviteQuota(t *testing.T) {
+	os.Setenv("INVITE_MAX_PER_HOUR", "1")
+	defer os.Unsetenv("INVITE_MAX_PER_HOUR")
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	receivers := []string{"2bb0e3a4-8a79-11eb-8dcd-0242ac130003", "3c1b5d7e-8a79-11eb-8dcd-0242ac130003"}
+	var response *httptest.ResponseRecorder
+	for _, receiver := range receivers {
+		// Creating request body
+		body := &data.Relationship{
+			User1:          data.User{UserID: "1a6f1c5e-8a79-11eb-8dcd-0242ac130003", RelationshipType: data.PendingOutgoing},
+			User2:          data.User{UserID: receiver, RelationshipType: data.PendingIncoming},
+			ConversationID: "",
+		}
+
+		request := httptest.NewRequest(http.MethodPost, "/relationships", nil)
+		response = httptest.NewRecorder()
+
+		// Add the body to the context since we arent passing through middleware
+		ctx := context.WithValue(request.Context(), KeyRelationship{}, body)
+		request = request.WithContext(ctx)
+
+		relationshipHandler.AddRelationship(response, request)
+	}
+
+	if response.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status code %d but got : %d", http.StatusTooManyRequests, response.Code)
+	}
+	if response.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+}
+
+func TestAddRelationshipOverFriendLimit(t *testing.T) {
+	os.Setenv("MAX_FRIENDS", "1")
+	defer os.Unsetenv("MAX_FRIENDS")
+
+	// Creating request body
+	body := &data.Relationship{
+		User1:          data.User{UserID: "a2181017-5c53-422b-b6bc-036b27c04fc8", RelationshipType: data.Friend},
+		User2:          data.User{UserID: "4d2c6e8f-8a79-11eb-8dcd-0242ac130003", RelationshipType: data.Friend},
+		ConversationID: "",
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/relationships", nil)
+	response := httptest.NewRecorder()
+
+	// Add the body to the context since we arent passing through middleware
+	ctx := context.WithValue(request.Context(), KeyRelationship{}, body)
+	request = request.WithContext(ctx)
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	relationshipHandler.AddRelationship(response, request)
+
+	if response.Code != http.StatusConflict {
+		t.Errorf("Expected status code %d but got : %d", http.StatusConflict, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "maximum of 1 friends") {
+		t.Error("Expected response : maximum of 1 friends")
+	}
+}
+
+func TestAddFriendCode(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPost, "/friend-codes/a2181017-5c53-422b-b6bc-036b27c04fc8", strings.NewReader(`{"single_use": true, "expires_in": 3600}`))
+	request = withBearerToken(request, "a2181017-5c53-422b-b6bc-036b27c04fc8")
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	// Mocking gorilla/mux vars
+	vars := map[string]string{
+		"user_id": "a2181017-5c53-422b-b6bc-036b27c04fc8",
+	}
+	request = mux.SetURLVars(request, vars)
+
+	relationshipHandler.AddFriendCode(response, request)
+
+	if response.Code != http.StatusCreated {
+		t.Errorf("Expected status code %d but got : %d", http.StatusCreated, response.Code)
+	}
+
+	friendCode := &data.FriendCode{}
+	err := json.Unmarshal(response.Body.Bytes(), friendCode)
+	if err != nil {
+		t.Fatal("Friend code is not a valid json struct : ", err)
+	}
+	if len(friendCode.Code) != 9 || !friendCode.SingleUse || friendCode.ExpiresAt == nil {
+		t.Errorf("Unexpected friend code %+v", friendCode)
+	}
+}
+
+func TestRedeemSingleUseFriendCode(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	expectedCodes := []int{http.StatusCreated, http.StatusNotFound}
+	for _, expectedCode := range expectedCodes {
+		request := httptest.NewRequest(http.MethodPost, "/friend-codes/7KQ4-MX2B/redeem", nil)
+		request = withBearerToken(request, "5e3d7f9a-8a79-11eb-8dcd-0242ac130003")
+		response := httptest.NewRecorder()
+
+		// Mocking gorilla/mux vars
+		vars := map[string]string{
+			"code": "7KQ4-MX2B",
+		}
+		request = mux.SetURLVars(request, vars)
+
+		relationshipHandler.RedeemFriendCode(response, request)
+
+		if response.Code != expectedCode {
+			t.Errorf("Expected status code %d but got : %d", expectedCode, response.Code)
+		}
+		if expectedCode == http.StatusCreated && !strings.Contains(response.Body.String(), "\"relationship_type\":\"PendingIncoming\"") {
+			t.Error("Expected a friend request to the owner of the code")
+		}
+	}
+}
+
+func TestDeleteFriendCodeOfAnotherUser(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	friendCode := &data.FriendCode{UserID: "e2382ea2-b5fa-4506-aa9d-d338aa52af44"}
+	err := newRelationshipDB().AddFriendCode(context.Background(), friendCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodDelete, "/friend-codes/"+friendCode.Code, nil)
+	request = withBearerToken(request, "a2181017-5c53-422b-b6bc-036b27c04fc8")
+	response := httptest.NewRecorder()
+
+	// Mocking gorilla/mux vars
+	vars := map[string]string{
+		"code": friendCode.Code,
+	}
+	request = mux.SetURLVars(request, vars)
+
+	relationshipHandler.DeleteFriendCode(response, request)
+
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d but got : %d", http.StatusForbidden, response.Code)
+	}
+}
+
+func TestSendInviteByUsername(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPost, "/invites", strings.NewReader(`{"username": "sickboy"}`))
+	request = withBearerToken(request, "6b9c3d1e-8a79-11eb-8dcd-0242ac130003")
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	relationshipHandler.SendInvite(response, request)
+
+	if response.Code != http.StatusCreated {
+		t.Errorf("Expected status code %d but got : %d", http.StatusCreated, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "\"user_id\":\"e2382ea2-b5fa-4506-aa9d-d338aa52af44\",\"relationship_type\":\"PendingIncoming\"") {
+		t.Error("Expected a friend request to the user found by username")
+	}
+}
+
+func TestSendInviteLookupErrors(t *testing.T) {
+	tests := []struct {
+		body         string
+		expectedCode int
+	}{
+		{body: `{"username": "Test"}`, expectedCode: http.StatusConflict},
+		{body: `{"username": "nobody"}`, expectedCode: http.StatusNotFound},
+		{body: `{"username": "sickboy", "email": "sickboy@ubivius.tk"}`, expectedCode: http.StatusBadRequest},
+		{body: `{"email": "not-an-email"}`, expectedCode: http.StatusBadRequest},
+	}
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodPost, "/invites", strings.NewReader(test.body))
+		request = withBearerToken(request, "6b9c3d1e-8a79-11eb-8dcd-0242ac130003")
+		response := httptest.NewRecorder()
+
+		relationshipHandler.SendInvite(response, request)
+
+		if response.Code != test.expectedCode {
+			t.Errorf("Expected status code %d for %s but got : %d", test.expectedCode, test.body, response.Code)
+		}
+	}
+}
+
+func TestSendInviteToUserRefusingInvites(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPost, "/invites", strings.NewReader(`{"user_id": "d4e5f6a7-8a79-11eb-8dcd-0242ac130003"}`))
+	request = withBearerToken(request, "6b9c3d1e-8a79-11eb-8dcd-0242ac130003")
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	relationshipHandler.SendInvite(response, request)
+
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d but got : %d", http.StatusForbidden, response.Code)
+	}
+}
+
+func TestGetPrivateFriendsListByUserID(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/friends/d4e5f6a7-8a79-11eb-8dcd-0242ac130003", nil)
+	request = withBearerToken(request, "a2181017-5c53-422b-b6bc-036b27c04fc8")
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	// Mocking gorilla/mux vars
+	vars := map[string]string{
+		"user_id": "d4e5f6a7-8a79-11eb-8dcd-0242ac130003",
+	}
+	request = mux.SetURLVars(request, vars)
+
+	relationshipHandler.GetFriendsListByUserID(response, request)
+
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d but got : %d", http.StatusForbidden, response.Code)
+	}
+}
+
+func TestUpdateUserSettingsToFriendsOfFriends(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	request := httptest.NewRequest(http.MethodPut, "/settings/0af831ea-8a78-11eb-8dcd-0242ac130003", strings.NewReader(`{"invite_policy": "friends_of_friends", "friends_list_visible": true}`))
+	request = withBearerToken(request, "0af831ea-8a78-11eb-8dcd-0242ac130003")
+	response := httptest.NewRecorder()
+
+	// Mocking gorilla/mux vars
+	vars := map[string]string{
+		"user_id": "0af831ea-8a78-11eb-8dcd-0242ac130003",
+	}
+	request = mux.SetURLVars(request, vars)
+
+	relationshipHandler.UpdateUserSettings(response, request)
+
+	if response.Code != http.StatusNoContent {
+		t.Errorf("Expected status code %d but got : %d", http.StatusNoContent, response.Code)
+	}
+
+	// Users without a mutual friend can't send friend requests anymore
+	request = httptest.NewRequest(http.MethodPost, "/invites", strings.NewReader(`{"user_id": "0af831ea-8a78-11eb-8dcd-0242ac130003"}`))
+	request = withBearerToken(request, "6b9c3d1e-8a79-11eb-8dcd-0242ac130003")
+	response = httptest.NewRecorder()
+
+	relationshipHandler.SendInvite(response, request)
+
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d but got : %d", http.StatusForbidden, response.Code)
+	}
+}
+
+func TestUpdateUserSettingsOfAnotherUser(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPut, "/settings/0af831ea-8a78-11eb-8dcd-0242ac130003", strings.NewReader(`{"invite_policy": "nobody"}`))
+	request = withBearerToken(request, "a2181017-5c53-422b-b6bc-036b27c04fc8")
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	// Mocking gorilla/mux vars
+	vars := map[string]string{
+		"user_id": "0af831ea-8a78-11eb-8dcd-0242ac130003",
+	}
+	request = mux.SetURLVars(request, vars)
+
+	relationshipHandler.UpdateUserSettings(response, request)
+
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d but got : %d", http.StatusForbidden, response.Code)
+	}
+}
+
+func getFriendsListAs(relationshipHandler *RelationshipsHandler, userID string, viewerID string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/friends/"+userID, nil)
+	request = withBearerToken(request, viewerID)
+	response := httptest.NewRecorder()
+
+	// Mocking gorilla/mux vars
+	vars := map[string]string{
+		"user_id": userID,
+	}
+	request = mux.SetURLVars(request, vars)
+
+	relationshipHandler.GetFriendsListByUserID(response, request)
+	return response
+}
+
+func TestGetFriendsListByUserIDAsFriend(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	// 7a5b6c8d blocked the viewer and is left out of the list
+	response := getFriendsListAs(relationshipHandler, "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003", "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003")
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected status code %d but got : %d", http.StatusOK, response.Code)
+	}
+	body := response.Body.String()
+	if !strings.Contains(body, "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003") || !strings.Contains(body, "5e3f4a6b-8a7a-11eb-8dcd-0242ac130003") {
+		t.Error("Missing friends from expected results")
+	}
+	if strings.Contains(body, "7a5b6c8d-8a7a-11eb-8dcd-0242ac130003") {
+		t.Error("Expected users blocking the viewer to be omitted")
+	}
+
+	request := httptest.NewRequest(http.MethodPut, "/settings/3c1d2e4f-8a7a-11eb-8dcd-0242ac130003", strings.NewReader(`{"invite_policy": "everyone", "friends_list_visible": true, "friends_view": "mutual"}`))
+	request = withBearerToken(request, "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003")
+	request = mux.SetURLVars(request, map[string]string{"user_id": "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003"})
+	relationshipHandler.UpdateUserSettings(httptest.NewRecorder(), request)
+
+	// Only the friends shared with the viewer are left
+	response = getFriendsListAs(relationshipHandler, "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003", "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003")
+	body = response.Body.String()
+	if !strings.Contains(body, "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003") || strings.Contains(body, "5e3f4a6b-8a7a-11eb-8dcd-0242ac130003") {
+		t.Errorf("Expected only mutual friends but got : %s", body)
+	}
+}
+
+func TestGetFriendsListByUserIDAsStranger(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	response := getFriendsListAs(relationshipHandler, "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003", "9c7d8e0f-8a7a-11eb-8dcd-0242ac130003")
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected status code %d but got : %d", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "\"count\":4") {
+		t.Errorf("Expected only the number of friends but got : %s", response.Body.String())
+	}
+}
+
+func TestGetFriendsListByUserIDAsBlockedUser(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	response := getFriendsListAs(relationshipHandler, "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003", "8b6c7d9e-8a7a-11eb-8dcd-0242ac130003")
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d but got : %d", http.StatusForbidden, response.Code)
+	}
+}
+
+func TestGetFriendsListByGroup(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/friends/3c1d2e4f-8a7a-11eb-8dcd-0242ac130003?group=0c1d2e3f-8a7c-11eb-8dcd-0242ac130003", nil)
+	request = withBearerToken(request, "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003")
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	// Mocking gorilla/mux vars
+	vars := map[string]string{
+		"user_id": "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003",
+	}
+	request = mux.SetURLVars(request, vars)
+
+	relationshipHandler.GetFriendsListByUserID(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected status code %d but got : %d", http.StatusOK, response.Code)
+	}
+	body := response.Body.String()
+	if !strings.Contains(body, "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003") || !strings.Contains(body, "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003") {
+		t.Error("Missing group members from expected results")
+	}
+	if strings.Contains(body, "5e3f4a6b-8a7a-11eb-8dcd-0242ac130003") {
+		t.Error("Expected friends outside of the group to be omitted")
+	}
+}
+
+func TestAddFriendGroupWithoutFriendship(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPost, "/groups/3c1d2e4f-8a7a-11eb-8dcd-0242ac130003", strings.NewReader(`{"name": "IRL", "relationship_ids": ["7f8a9b0c-8a7b-11eb-8dcd-0242ac130003"]}`))
+	request = withBearerToken(request, "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003")
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	// Mocking gorilla/mux vars
+	vars := map[string]string{
+		"user_id": "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003",
+	}
+	request = mux.SetURLVars(request, vars)
+
+	relationshipHandler.AddFriendGroup(response, request)
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d but got : %d", http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestDeleteRelationshipRemovesItFromFriendGroups(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	request := httptest.NewRequest(http.MethodPost, "/groups/3c1d2e4f-8a7a-11eb-8dcd-0242ac130003", strings.NewReader(`{"name": "IRL", "relationship_ids": ["2a3b4c5d-8a7b-11eb-8dcd-0242ac130003"]}`))
+	request = withBearerToken(request, "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003")
+	request = mux.SetURLVars(request, map[string]string{"user_id": "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003"})
+	response := httptest.NewRecorder()
+
+	relationshipHandler.AddFriendGroup(response, request)
+
+	if response.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusCreated, response.Code)
+	}
+	friendGroup := &data.FriendGroup{}
+	err := json.NewDecoder(response.Body).Decode(friendGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request = httptest.NewRequest(http.MethodDelete, "/relationships/2a3b4c5d-8a7b-11eb-8dcd-0242ac130003", nil)
+	request = mux.SetURLVars(request, map[string]string{"id": "2a3b4c5d-8a7b-11eb-8dcd-0242ac130003"})
+	relationshipHandler.Delete(httptest.NewRecorder(), request)
+
+	request = httptest.NewRequest(http.MethodGet, "/groups/3c1d2e4f-8a7a-11eb-8dcd-0242ac130003", nil)
+	request = withBearerToken(request, "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003")
+	request = mux.SetURLVars(request, map[string]string{"user_id": "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003"})
+	response = httptest.NewRecorder()
+
+	relationshipHandler.GetFriendGroupsByUserID(response, request)
+
+	if !strings.Contains(response.Body.String(), friendGroup.ID) {
+		t.Fatal("Missing created friend group from expected results")
+	}
+	if strings.Contains(response.Body.String(), "2a3b4c5d-8a7b-11eb-8dcd-0242ac130003") {
+		t.Error("Expected the deleted relationship to be removed from the friend group")
+	}
+}
+
+func TestUpdateRelationshipPreferencesAndSortByFavourite(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	request := httptest.NewRequest(http.MethodPut, "/relationships/3b4c5d6e-8a7b-11eb-8dcd-0242ac130003/preferences", strings.NewReader(`{"nickname": "Healer", "favourite": true}`))
+	request = withBearerToken(request, "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003")
+	request = mux.SetURLVars(request, map[string]string{"id": "3b4c5d6e-8a7b-11eb-8dcd-0242ac130003"})
+	response := httptest.NewRecorder()
+
+	relationshipHandler.UpdateRelationshipPreferences(response, request)
+
+	if response.Code != http.StatusNoContent {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusNoContent, response.Code)
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/friends/3c1d2e4f-8a7a-11eb-8dcd-0242ac130003?sort=favourite", nil)
+	request = withBearerToken(request, "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003")
+	request = mux.SetURLVars(request, map[string]string{"user_id": "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003"})
+	response = httptest.NewRecorder()
+
+	relationshipHandler.GetFriendsListByUserID(response, request)
+
+	friends := data.DetailedRelationships{}
+	err := json.NewDecoder(response.Body).Decode(&friends)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(friends) == 0 || friends[0].User.ID != "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003" || friends[0].Nickname != "Healer" {
+		t.Errorf("Expected the favourite friend with its nickname first but got : %+v", friends)
+	}
+}
+
+func TestUpdateRelationshipPreferencesOfAnotherUser(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPut, "/relationships/3b4c5d6e-8a7b-11eb-8dcd-0242ac130003/preferences", strings.NewReader(`{"note": "Not my relationship"}`))
+	request = withBearerToken(request, "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003")
+	request = mux.SetURLVars(request, map[string]string{"id": "3b4c5d6e-8a7b-11eb-8dcd-0242ac130003"})
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	relationshipHandler.UpdateRelationshipPreferences(response, request)
+
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d but got : %d", http.StatusNotFound, response.Code)
+	}
+}
+
+func TestFollowAndGetFollowers(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	request := httptest.NewRequest(http.MethodPost, "/follows/e2382ea2-b5fa-4506-aa9d-d338aa52af45", nil)
+	request = withBearerToken(request, "5e3f4a6b-8a7a-11eb-8dcd-0242ac130003")
+	request = mux.SetURLVars(request, map[string]string{"user_id": "e2382ea2-b5fa-4506-aa9d-d338aa52af45"})
+	response := httptest.NewRecorder()
+
+	relationshipHandler.Follow(response, request)
+
+	if response.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusCreated, response.Code)
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/followers/e2382ea2-b5fa-4506-aa9d-d338aa52af45", nil)
+	request = withBearerToken(request, "5e3f4a6b-8a7a-11eb-8dcd-0242ac130003")
+	request = mux.SetURLVars(request, map[string]string{"user_id": "e2382ea2-b5fa-4506-aa9d-d338aa52af45"})
+	response = httptest.NewRecorder()
+
+	relationshipHandler.GetFollowersByUserID(response, request)
+
+	followList := &data.FollowList{}
+	err := json.NewDecoder(response.Body).Decode(followList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if followList.Total != 2 || len(*followList.Relationships) != 2 || (*followList.Relationships)[0].User.ID != "5e3f4a6b-8a7a-11eb-8dcd-0242ac130003" {
+		t.Errorf("Expected both followers, newest first, but got : %+v", followList)
+	}
+}
+
+func TestFollowUserBlockingTheCaller(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPost, "/follows/7a5b6c8d-8a7a-11eb-8dcd-0242ac130003", nil)
+	request = withBearerToken(request, "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003")
+	request = mux.SetURLVars(request, map[string]string{"user_id": "7a5b6c8d-8a7a-11eb-8dcd-0242ac130003"})
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	relationshipHandler.Follow(response, request)
+
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d but got : %d", http.StatusForbidden, response.Code)
+	}
+}
+
+func TestUnfollowWithoutCaller(t *testing.T) {
+	request := httptest.NewRequest(http.MethodDelete, "/follows/e2382ea2-b5fa-4506-aa9d-d338aa52af45", nil)
+	request = mux.SetURLVars(request, map[string]string{"user_id": "e2382ea2-b5fa-4506-aa9d-d338aa52af45"})
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	relationshipHandler.Unfollow(response, request)
+
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d but got : %d", http.StatusUnauthorized, response.Code)
+	}
+}
+
+func TestGetFollowingWithInvalidLimit(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/following/4d2e3f5a-8a7a-11eb-8dcd-0242ac130003?limit=1000", nil)
+	request = withBearerToken(request, "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003")
+	request = mux.SetURLVars(request, map[string]string{"user_id": "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003"})
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	relationshipHandler.GetFollowingByUserID(response, request)
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d but got : %d", http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestGetRelationshipTypes(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/relationship-types", nil)
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	relationshipHandler.GetRelationshipTypes(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected status code %d but got : %d", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "{\"name\":\"PendingIncoming\"") {
+		t.Error("Missing builtin relationship type from expected results")
+	}
+}
+
+func TestGetRelationshipsByUserIDAndType(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	for relationshipType, expectedCode := range map[string]int{"Blocked": http.StatusOK, "Rival": http.StatusBadRequest} {
+		request := httptest.NewRequest(http.MethodGet, "/users/3c1d2e4f-8a7a-11eb-8dcd-0242ac130003/relationships?type="+relationshipType, nil)
+		request = withBearerToken(request, "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003")
+		request = mux.SetURLVars(request, map[string]string{"user_id": "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003"})
+		response := httptest.NewRecorder()
+
+		relationshipHandler.GetRelationshipsByUserIDAndType(response, request)
+
+		if response.Code != expectedCode {
+			t.Errorf("Expected status code %d for type %s but got : %d", expectedCode, relationshipType, response.Code)
+		}
+		if expectedCode == http.StatusOK && !strings.Contains(response.Body.String(), "8b6c7d9e-8a7a-11eb-8dcd-0242ac130003") {
+			t.Error("Missing blocked user from expected results")
+		}
+	}
+}
+
+func TestAddRelationshipCrossingFriendRequest(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	senders := []struct {
+		senderID     string
+		receiverID   string
+		expectedCode int
+	}{
+		{"8b6c7d9e-8a7a-11eb-8dcd-0242ac130003", "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003", http.StatusNoContent},
+		{"6f4a5b7c-8a7a-11eb-8dcd-0242ac130003", "8b6c7d9e-8a7a-11eb-8dcd-0242ac130003", http.StatusOK},
+	}
+
+	for _, sender := range senders {
+		body := &data.Relationship{
+			User1: data.User{UserID: sender.receiverID, RelationshipType: data.PendingIncoming},
+			User2: data.User{UserID: sender.senderID, RelationshipType: data.PendingOutgoing},
+		}
+		request := httptest.NewRequest(http.MethodPost, "/relationships", nil)
+		request = request.WithContext(context.WithValue(request.Context(), KeyRelationship{}, body))
+		response := httptest.NewRecorder()
+
+		relationshipHandler.AddRelationship(response, request)
+
+		if response.Code != sender.expectedCode {
+			t.Fatalf("Expected status code %d but got : %d", sender.expectedCode, response.Code)
+		}
+	}
+
+	relationship, err := newRelationshipDB().GetRelationshipByUserIDs(context.Background(), "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003", "8b6c7d9e-8a7a-11eb-8dcd-0242ac130003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !relationship.IsFriendship() || relationship.ExpiresAt != nil {
+		t.Errorf("Expected the crossing friend requests to make a friendship, got %+v", relationship)
+	}
+}
+
+func TestAddRelationshipCrossingFriendRequestHidesPreferences(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	senderID := "1a2b3c4d-8a81-11eb-8dcd-0242ac130003"
+	receiverID := "2b3c4d5e-8a81-11eb-8dcd-0242ac130003"
+	pending := &data.Relationship{
+		User1: data.User{UserID: senderID, RelationshipType: data.PendingOutgoing},
+		User2: data.User{UserID: receiverID, RelationshipType: data.PendingIncoming},
+	}
+	err := newRelationshipDB().AddRelationship(context.Background(), pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = newRelationshipDB().UpdateRelationshipPreferences(context.Background(), pending.ID, senderID, &data.RelationshipPreferences{Nickname: "Rival", Note: "Keeps rage quitting"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The receiver sends a request back, the friendship answered holds none of the preferences of the sender
+	body := &data.Relationship{
+		User1: data.User{UserID: senderID, RelationshipType: data.PendingIncoming},
+		User2: data.User{UserID: receiverID, RelationshipType: data.PendingOutgoing},
+	}
+	request := httptest.NewRequest(http.MethodPost, "/relationships", nil)
+	request = request.WithContext(context.WithValue(request.Context(), KeyRelationship{}, body))
+	response := httptest.NewRecorder()
+	relationshipHandler.AddRelationship(response, request)
+
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), `"relationship_type":"Friend"`) {
+		t.Fatalf("Expected the friendship but got %d : %s", response.Code, response.Body.String())
+	}
+	if strings.Contains(response.Body.String(), "Rival") || strings.Contains(response.Body.String(), "rage quitting") {
+		t.Errorf("Expected the preferences of the other user to be hidden but got : %s", response.Body.String())
+	}
+}
+
+func TestSendInviteAutoAcceptedGuildMate(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	request := httptest.NewRequest(http.MethodPut, "/settings/5e3f4a6b-8a7a-11eb-8dcd-0242ac130003", strings.NewReader(`{"invite_policy": "everyone", "friends_list_visible": true, "auto_accept": ["guild_mates"]}`))
+	request = withBearerToken(request, "5e3f4a6b-8a7a-11eb-8dcd-0242ac130003")
+	response := httptest.NewRecorder()
+
+	// Mocking gorilla/mux vars
+	vars := map[string]string{
+		"user_id": "5e3f4a6b-8a7a-11eb-8dcd-0242ac130003",
+	}
+	request = mux.SetURLVars(request, vars)
+
+	relationshipHandler.UpdateUserSettings(response, request)
+
+	if response.Code != http.StatusNoContent {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusNoContent, response.Code)
+	}
+
+	// A friend request from a guild mate is accepted right away
+	request = httptest.NewRequest(http.MethodPost, "/invites", strings.NewReader(`{"user_id": "5e3f4a6b-8a7a-11eb-8dcd-0242ac130003"}`))
+	request = withBearerToken(request, "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003")
+	response = httptest.NewRecorder()
+
+	relationshipHandler.SendInvite(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected status code %d but got : %d", http.StatusOK, response.Code)
+	}
+	if strings.Contains(response.Body.String(), "Pending") {
+		t.Error("Expected the friend request to be auto accepted")
+	}
+}
+
+func TestAddPartyFriendships(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPost, "/internal/friendships", strings.NewReader(`{"user_ids": ["6f4a5b7c-8a7a-11eb-8dcd-0242ac130003", "7a5b6c8d-8a7a-11eb-8dcd-0242ac130003", "8b6c7d9e-8a7a-11eb-8dcd-0242ac130003"]}`))
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	relationshipHandler.AddPartyFriendships(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusOK, response.Code)
+	}
+
+	result := &data.PartyFriendshipResult{}
+	err := json.Unmarshal(response.Body.Bytes(), result)
+	if err != nil {
+		t.Fatal("Party friendship result is not a valid json struct : ", err)
+	}
+	if len(result.Friendships) != 1 || !result.Friendships[0].IsFriendship() {
+		t.Errorf("Expected one new friendship but got %+v", result.Friendships)
+	}
+
+	reasons := map[string]bool{}
+	for _, skipped := range result.Skipped {
+		reasons[skipped.Reason] = true
+	}
+	if len(result.Skipped) != 2 || !reasons[data.SkippedBlocked] || !reasons[data.SkippedAlreadyFriends] {
+		t.Errorf("Expected the blocked users and the friends to be skipped but got %+v", result.Skipped)
+	}
+}
+
+func TestAddPartyFriendshipsWithUnknownUser(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPost, "/internal/friendships", strings.NewReader(`{"user_ids": ["b0c1d2e3-8a84-11eb-8dcd-0242ac130003", "b1c2d3e4-8a84-11eb-8dcd-0242ac130003", "c0d1e2f3-8a84-11eb-8dcd-0242ac130003"]}`))
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	relationshipHandler.AddPartyFriendships(response, request)
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d but got : %d", http.StatusBadRequest, response.Code)
+	}
+	// The party is refused before any friendship is saved
+	_, err := relationshipHandler.db.GetRelationshipByUserIDs(context.Background(), "b0c1d2e3-8a84-11eb-8dcd-0242ac130003", "b1c2d3e4-8a84-11eb-8dcd-0242ac130003")
+	if err != data.ErrorRelationshipNotFound {
+		t.Errorf("Expected no friendship between the known users but got %v", err)
+	}
+}
+
+func TestSendInvitePublishesEvent(t *testing.T) {
+	published := events.Events{}
+	unsubscribe := testEvents.Subscribe(func(event *events.Event) {
+		published = append(published, event)
+	})
+	defer unsubscribe()
+
+	request := httptest.NewRequest(http.MethodPost, "/invites", strings.NewReader(`{"user_id": "5e3f4a6b-8a7a-11eb-8dcd-0242ac130003"}`))
+	request = withBearerToken(request, "7a5b6c8d-8a7a-11eb-8dcd-0242ac130003")
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	relationshipHandler.SendInvite(response, request)
+
+	if response.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusCreated, response.Code)
+	}
+	if len(published) != 1 || published[0].Type != events.InviteSent || published[0].UserID != "7a5b6c8d-8a7a-11eb-8dcd-0242ac130003" {
+		t.Errorf("Expected an InviteSent event from the caller but got %+v", published)
+	}
+}
+
+func TestMergeUserRelationshipsPublishesEvents(t *testing.T) {
+	sourceUserID := "8d9eafb0-8a84-11eb-8dcd-0242ac130003"
+	targetUserID := "9eafb0c1-8a84-11eb-8dcd-0242ac130003"
+	otherUserID := "afb0c1d2-8a84-11eb-8dcd-0242ac130003"
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	request := httptest.NewRequest(http.MethodPost, "/invites", strings.NewReader(`{"user_id": "`+otherUserID+`"}`))
+	request = withBearerToken(request, sourceUserID)
+	response := httptest.NewRecorder()
+	relationshipHandler.SendInvite(response, request)
+	if response.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusCreated, response.Code)
+	}
+
+	published := events.Events{}
+	unsubscribe := testEvents.Subscribe(func(event *events.Event) {
+		published = append(published, event)
+	})
+	defer unsubscribe()
+
+	bodyBytes, _ := json.Marshal(&data.MergeRequest{SourceUserID: sourceUserID, TargetUserID: targetUserID})
+	request = httptest.NewRequest(http.MethodPost, "/admin/merges", strings.NewReader(string(bodyBytes)))
+	response = httptest.NewRecorder()
+	relationshipHandler.MergeUserRelationships(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusOK, response.Code)
+	}
+	// The invite of the source user is removed and sent again by the target user
+	if len(published) != 2 ||
+		published[0].Type != events.InviteRemoved || published[0].UserID != sourceUserID ||
+		published[1].Type != events.InviteSent || published[1].UserID != targetUserID || published[1].OtherUserID != otherUserID {
+		t.Errorf("Expected the invite to move from the source user to the target user but got %+v", published)
+	}
+}
+
+func TestAddWebhookAndReplayDeadLetters(t *testing.T) {
+	os.Setenv("WEBHOOK_MAX_ATTEMPTS", "1")
+	defer os.Unsetenv("WEBHOOK_MAX_ATTEMPTS")
+
+	received := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		received++
+		responseWriter.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	db := newRelationshipDB()
+	relationshipHandler := NewRelationshipsHandler(db)
+
+	// Create a router because the webhook ID is extracted by gorilla/mux
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/webhooks", relationshipHandler.AddWebhook).Methods(http.MethodPost)
+	router.HandleFunc("/admin/webhooks/{webhook_id:[0-9a-z-]+}", relationshipHandler.DeleteWebhook).Methods(http.MethodDelete)
+	router.HandleFunc("/admin/webhooks/{webhook_id:[0-9a-z-]+}/dead-letters", relationshipHandler.GetDeadWebhookDeliveries).Methods(http.MethodGet)
+	router.HandleFunc("/admin/webhooks/{webhook_id:[0-9a-z-]+}/replay", relationshipHandler.ReplayWebhookDeliveries).Methods(http.MethodPost)
+
+	body := `{"url": "` + receiver.URL + `", "event_types": ["InviteSent"], "secret": "0123456789abcdef"}`
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader(body)))
+	if response.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusCreated, response.Code)
+	}
+	webhook := &data.Webhook{}
+	err := json.Unmarshal(response.Body.Bytes(), webhook)
+	if err != nil {
+		t.Fatal("Webhook is not a valid json struct : ", err)
+	}
+	if webhook.ID == "" || webhook.Secret != "" {
+		t.Errorf("Expected a webhook with an ID and without its secret but got %+v", webhook)
+	}
+	defer router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/admin/webhooks/"+webhook.ID, nil))
+
+	// The only attempt fails, the delivery becomes a dead letter
+	dispatcher := database.NewWebhookDispatcher(db)
+	event := &events.Event{ID: "d1e2f3a4-8a7e-11eb-8dcd-0242ac130003", Type: events.InviteSent}
+	err = dispatcher.Publish(context.Background(), event)
+	if err != nil {
+		t.Fatal("Error publishing event to the webhooks : ", err)
+	}
+	err = dispatcher.Publish(context.Background(), &events.Event{ID: "e2f3a4b5-8a7e-11eb-8dcd-0242ac130003", Type: events.FriendRemoved})
+	if err != nil {
+		t.Fatal("Error publishing event to the webhooks : ", err)
+	}
+	delivered, err := dispatcher.Dispatch(context.Background())
+	if err != nil || delivered != 0 || received != 1 {
+		t.Fatalf("Expected a single failed delivery but got %d delivered, %d received, error %v", delivered, received, err)
+	}
+
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/admin/webhooks/"+webhook.ID+"/dead-letters", nil))
+	deadLetters := data.WebhookDeliveries{}
+	err = json.Unmarshal(response.Body.Bytes(), &deadLetters)
+	if err != nil {
+		t.Fatal("Dead letters are not a valid json struct : ", err)
+	}
+	if len(deadLetters) != 1 || deadLetters[0].EventID != event.ID || deadLetters[0].LastStatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected the failed InviteSent delivery in the dead letters but got %+v", deadLetters)
+	}
+
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/admin/webhooks/"+webhook.ID+"/replay", nil))
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), `"replayed":1`) {
+		t.Errorf("Expected one replayed delivery but got %s", response.Body.String())
+	}
+
+	_, err = dispatcher.Dispatch(context.Background())
+	if err != nil || received != 2 {
+		t.Errorf("Expected the replayed delivery to be sent again but got %d received, error %v", received, err)
+	}
+}
+
+func TestAddWebhookWithUnknownEventType(t *testing.T) {
+	body := `{"url": "http://game-backend/hooks", "event_types": ["InviteSent", "PlayerJoined"], "secret": "0123456789abcdef"}`
+	request := httptest.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader(body))
+	response := httptest.NewRecorder()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	relationshipHandler.AddWebhook(response, request)
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d but got : %d", http.StatusBadRequest, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "PlayerJoined") {
+		t.Errorf("Expected error to name the unknown event type but got %s", response.Body.String())
+	}
+}
+
+func TestStreamServerSentEvents(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	broker := events.NewMemoryBroker()
+	unsubscribe := relationshipHandler.SubscribeStream(broker)
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	request := httptest.NewRequest(http.MethodGet, "/stream", nil).WithContext(ctx)
+	request = withBearerToken(request, "5e3f4a6b-8a7a-11eb-8dcd-0242ac130003")
+	response := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		relationshipHandler.Stream(response, request)
+	}()
+
+	// Wait for the client to be connected before publishing
+	for connected := false; !connected; {
+		relationshipHandler.stream.mutex.Lock()
+		connected = len(relationshipHandler.stream.clients["5e3f4a6b-8a7a-11eb-8dcd-0242ac130003"]) == 1
+		relationshipHandler.stream.mutex.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	_ = broker.Publish(context.Background(), &events.Event{ID: "b5c6d7e8-8a7e-11eb-8dcd-0242ac130003", Type: events.InviteSent, UserID: "7a5b6c8d-8a7a-11eb-8dcd-0242ac130003", OtherUserID: "5e3f4a6b-8a7a-11eb-8dcd-0242ac130003"})
+	_ = broker.Publish(context.Background(), &events.Event{ID: "c6d7e8f9-8a7e-11eb-8dcd-0242ac130003", Type: events.FriendRemoved, UserID: "7a5b6c8d-8a7a-11eb-8dcd-0242ac130003", OtherUserID: "5e3f4a6b-8a7a-11eb-8dcd-0242ac130003"})
+	_ = broker.Publish(context.Background(), &events.Event{ID: "d7e8f9a0-8a7e-11eb-8dcd-0242ac130003", Type: events.InviteSent, UserID: "7a5b6c8d-8a7a-11eb-8dcd-0242ac130003", OtherUserID: "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003"})
+
+	// The stream ends with the request once its events are written
+	for pending := 1; pending > 0; {
+		relationshipHandler.stream.mutex.Lock()
+		for client := range relationshipHandler.stream.clients["5e3f4a6b-8a7a-11eb-8dcd-0242ac130003"] {
+			pending = len(client.events)
+		}
+		relationshipHandler.stream.mutex.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if response.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected an event stream but got %s", response.Header().Get("Content-Type"))
+	}
+	body := response.Body.String()
+	if !strings.Contains(body, "id: b5c6d7e8-8a7e-11eb-8dcd-0242ac130003\nevent: InviteSent\ndata: {") {
+		t.Errorf("Expected the received invite in the stream but got %q", body)
+	}
+	if strings.Contains(body, "c6d7e8f9") || strings.Contains(body, "d7e8f9a0") {
+		t.Errorf("Expected only the invite events of the caller in the stream but got %q", body)
+	}
+}
+
+func TestStreamWebSocket(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	broker := events.NewMemoryBroker()
+	unsubscribe := relationshipHandler.SubscribeStream(broker)
+	defer unsubscribe()
+
+	server := httptest.NewServer(http.HandlerFunc(relationshipHandler.Stream))
+	defer server.Close()
+
+	header := withBearerToken(httptest.NewRequest(http.MethodGet, "/stream", nil), "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003").Header
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), header)
+	if err != nil {
+		t.Fatal("Error opening the stream WebSocket : ", err)
+	}
+	defer conn.Close()
+
+	// The client is registered before the upgrade is answered
+	_ = broker.Publish(context.Background(), &events.Event{ID: "e8f9a0b1-8a7e-11eb-8dcd-0242ac130003", Type: events.InviteAccepted, UserID: "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003", OtherUserID: "8b6c7d9e-8a7a-11eb-8dcd-0242ac130003"})
+
+	received := &events.Event{}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	err = conn.ReadJSON(received)
+	if err != nil {
+		t.Fatal("Error reading event from the stream : ", err)
+	}
+	if received.ID != "e8f9a0b1-8a7e-11eb-8dcd-0242ac130003" || received.Type != events.InviteAccepted {
+		t.Errorf("Expected the accepted invite but got %+v", received)
+	}
+}
+
+func postUserEvent(relationshipHandler *RelationshipsHandler, eventType data.UserEventType, userID string) *httptest.ResponseRecorder {
+	body := `{"id": "` + string(eventType) + `-` + userID + `", "type": "` + string(eventType) + `", "user_id": "` + userID + `"}`
+	request := httptest.NewRequest(http.MethodPost, "/internal/user-events", strings.NewReader(body))
+	response := httptest.NewRecorder()
+	relationshipHandler.HandleUserEvent(response, request)
+	return response
+}
+
+func TestHandleUserEventBanAndDeletion(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	viewerID := "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003"
+	bannedID := "8b6c7d9e-8a7a-11eb-8dcd-0242ac130003"
+	friendIDs := func() map[string]bool {
+		friends := &data.DetailedRelationships{}
+		_ = json.Unmarshal(getFriendsListAs(relationshipHandler, viewerID, viewerID).Body.Bytes(), friends)
+		ids := map[string]bool{}
+		for _, friend := range *friends {
+			ids[friend.User.ID] = true
+		}
+		return ids
+	}
+	if !friendIDs()[bannedID] {
+		t.Fatal("Expected the users to be friends before the ban")
+	}
+
+	// Relationships of a banned user are hidden and can't change
+	response := postUserEvent(relationshipHandler, data.UserBanned, bannedID)
+	if response.Code != http.StatusNoContent {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusNoContent, response.Code)
+	}
+	if friendIDs()[bannedID] {
+		t.Error("Expected the banned friend to be hidden from the friends list")
+	}
+	request := httptest.NewRequest(http.MethodPost, "/invites", strings.NewReader(`{"user_id": "`+viewerID+`"}`))
+	request = withBearerToken(request, bannedID)
+	inviteResponse := httptest.NewRecorder()
+	relationshipHandler.SendInvite(inviteResponse, request)
+	if inviteResponse.Code != http.StatusConflict {
+		t.Errorf("Expected status code %d for an invite of a banned user but got : %d", http.StatusConflict, inviteResponse.Code)
+	}
+
+	postUserEvent(relationshipHandler, data.UserUnbanned, bannedID)
+	if !friendIDs()[bannedID] {
+		t.Error("Expected the friendship to be back once the ban is lifted")
+	}
+
+	// Deleting an account twice deletes its relationships once
+	for i := 0; i < 2; i++ {
+		response = postUserEvent(relationshipHandler, data.UserDeleted, bannedID)
+		if response.Code != http.StatusNoContent {
+			t.Fatalf("Expected status code %d but got : %d", http.StatusNoContent, response.Code)
+		}
+	}
+	if friendIDs()[bannedID] {
+		t.Error("Expected the relationships of the deleted user to be deleted")
+	}
+	relationships, _ := relationshipHandler.db.GetRelationshipsByUserIDAndType(context.Background(), bannedID, data.Friend)
+	if len(*relationships) != 0 {
+		t.Errorf("Expected no relationship left for the deleted user but got %d", len(*relationships))
+	}
+}
+
+func TestHandleUnknownUserEvent(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	response := postUserEvent(relationshipHandler, "UserPromoted", "8b6c7d9e-8a7a-11eb-8dcd-0242ac130003")
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d but got : %d", http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestHandleUserEventWithFailedDelete(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(&failingDeleteDB{RelationshipDB: newRelationshipDB()})
+
+	// microservice-user sends the event again when it isn't answered with a 2xx
+	response := postUserEvent(relationshipHandler, data.UserDeleted, "d1e2f3a4-8a84-11eb-8dcd-0242ac130003")
+	if response.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d but got : %d", http.StatusInternalServerError, response.Code)
+	}
+}
+
+func TestHandleUserEventIgnoresLateBan(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	viewerID := "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003"
+	userID := "7a5b6c8d-8a7a-11eb-8dcd-0242ac130003"
+	now := time.Now().UTC()
+
+	// The unban is received before the ban it lifts
+	for _, event := range []struct {
+		eventType  data.UserEventType
+		occurredAt time.Time
+	}{
+		{data.UserUnbanned, now},
+		{data.UserBanned, now.Add(-time.Hour)},
+	} {
+		body := `{"id": "` + string(event.eventType) + `-late-` + userID + `", "type": "` + string(event.eventType) + `", "user_id": "` + userID + `", "occurred_at": "` + event.occurredAt.Format(time.RFC3339) + `"}`
+		request := httptest.NewRequest(http.MethodPost, "/internal/user-events", strings.NewReader(body))
+		response := httptest.NewRecorder()
+		relationshipHandler.HandleUserEvent(response, request)
+		if response.Code != http.StatusNoContent {
+			t.Fatalf("Expected status code %d but got : %d", http.StatusNoContent, response.Code)
+		}
+	}
+
+	friends := &data.DetailedRelationships{}
+	_ = json.Unmarshal(getFriendsListAs(relationshipHandler, viewerID, viewerID).Body.Bytes(), friends)
+	found := false
+	for _, friend := range *friends {
+		found = found || friend.User.ID == userID
+	}
+	if !found {
+		t.Error("Expected a ban older than the unban to be ignored")
+	}
+}
+
+func postPresence(relationshipHandler *RelationshipsHandler, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/internal/presence", strings.NewReader(body))
+	response := httptest.NewRecorder()
+	relationshipHandler.UpdatePresence(response, request)
+	return response
+}
+
+func TestGetOnlineFriendsByUserID(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	userID := "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003"
+	now := time.Now().UTC()
+
+	response := postPresence(relationshipHandler, `{"user_id": "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003", "status": "InGame", "updated_on": "`+now.Format(time.RFC3339)+`"}`)
+	if response.Code != http.StatusNoContent {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusNoContent, response.Code)
+	}
+	// An update received late doesn't replace the newer status
+	postPresence(relationshipHandler, `{"user_id": "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003", "status": "Offline", "updated_on": "`+now.Add(-time.Minute).Format(time.RFC3339)+`"}`)
+
+	request := httptest.NewRequest(http.MethodGet, "/friends/"+userID+"/online", nil)
+	request = withBearerToken(request, userID)
+	request = mux.SetURLVars(request, map[string]string{"user_id": userID})
+	response = httptest.NewRecorder()
+	relationshipHandler.GetOnlineFriendsByUserID(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusOK, response.Code)
+	}
+	friendsPresence := &data.FriendsPresence{}
+	_ = json.Unmarshal(response.Body.Bytes(), friendsPresence)
+	if len(friendsPresence.Friends) != 1 || friendsPresence.Friends[0].User.ID != "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003" || friendsPresence.Friends[0].User.Status != data.PresenceInGame {
+		t.Errorf("Expected only the friend in game but got : %s", response.Body.String())
+	}
+	if friendsPresence.Counts[data.PresenceInGame] != 1 || friendsPresence.Counts[data.PresenceOnline] != 0 || friendsPresence.Counts[data.PresenceOffline] == 0 {
+		t.Errorf("Unexpected counts per status : %v", friendsPresence.Counts)
+	}
+
+	// Friends without a recent status are offline
+	request = httptest.NewRequest(http.MethodGet, "/friends/"+userID+"?status=Offline", nil)
+	request = withBearerToken(request, userID)
+	request = mux.SetURLVars(request, map[string]string{"user_id": userID})
+	response = httptest.NewRecorder()
+	relationshipHandler.GetFriendsListByUserID(response, request)
+	body := response.Body.String()
+	if response.Code != http.StatusOK || strings.Contains(body, "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003") || !strings.Contains(body, "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003") {
+		t.Errorf("Expected only the offline friends but got : %s", body)
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/friends/"+userID+"?status=Online,Busy", nil)
+	request = withBearerToken(request, userID)
+	request = mux.SetURLVars(request, map[string]string{"user_id": userID})
+	response = httptest.NewRecorder()
+	relationshipHandler.GetFriendsListByUserID(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for an unknown status but got : %d", http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestUpdatePresenceWithUnknownStatus(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	response := postPresence(relationshipHandler, `{"user_id": "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003", "status": "Busy"}`)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d but got : %d", http.StatusBadRequest, response.Code)
+	}
+}
+
+func getFeed(relationshipHandler *RelationshipsHandler, userID string, query string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/feed/"+userID+query, nil)
+	request = withBearerToken(request, userID)
+	request = mux.SetURLVars(request, map[string]string{"user_id": userID})
+	response := httptest.NewRecorder()
+	relationshipHandler.GetFeedByUserID(response, request)
+	return response
+}
+
+func TestGetFeedByUserID(t *testing.T) {
+	db := newRelationshipDB()
+	relationshipHandler := NewRelationshipsHandler(db)
+	recorder := database.NewFeedRecorder(db)
+	viewerID := "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003"
+	friendID := "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003"
+	now := time.Now().UTC()
+
+	feedEvents := events.Events{
+		// 7a5b6c8d blocked the viewer, the activity is hidden from the viewer
+		{ID: "1a2b3c4d-8a7d-11eb-8dcd-0242ac130003", Type: events.FriendAdded, UserID: friendID, OtherUserID: "7a5b6c8d-8a7a-11eb-8dcd-0242ac130003", OccurredAt: now.Add(-3 * time.Minute)},
+		{ID: "2b3c4d5e-8a7d-11eb-8dcd-0242ac130003", Type: events.FriendAdded, UserID: friendID, OtherUserID: "9c7d8e0f-8a7a-11eb-8dcd-0242ac130003", OccurredAt: now.Add(-2 * time.Minute)},
+		{ID: "3c4d5e6f-8a7d-11eb-8dcd-0242ac130003", Type: events.InviteAccepted, UserID: "ad8e9f1a-8a7a-11eb-8dcd-0242ac130003", OtherUserID: viewerID, OccurredAt: now.Add(-time.Minute)},
+		// Events published again don't add activities
+		{ID: "3c4d5e6f-8a7d-11eb-8dcd-0242ac130003", Type: events.InviteAccepted, UserID: "ad8e9f1a-8a7a-11eb-8dcd-0242ac130003", OtherUserID: viewerID, OccurredAt: now.Add(-time.Minute)},
+	}
+	for _, event := range feedEvents {
+		err := recorder.Publish(context.Background(), event)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	response := getFeed(relationshipHandler, viewerID, "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusOK, response.Code)
+	}
+	feed := &data.Feed{}
+	_ = json.Unmarshal(response.Body.Bytes(), feed)
+	if len(feed.Items) != 2 || feed.Items[0].Type != data.FeedInviteAccepted || feed.Items[1].SubjectID != "9c7d8e0f-8a7a-11eb-8dcd-0242ac130003" {
+		t.Fatalf("Unexpected feed : %s", response.Body.String())
+	}
+	if feed.Items[0].Actor == nil || feed.Items[0].Actor.ID != "ad8e9f1a-8a7a-11eb-8dcd-0242ac130003" {
+		t.Errorf("Expected the details of the actor in the feed : %s", response.Body.String())
+	}
+
+	feed = &data.Feed{}
+	_ = json.Unmarshal(getFeed(relationshipHandler, viewerID, "?limit=1").Body.Bytes(), feed)
+	if len(feed.Items) != 1 || feed.NextOffset != 1 {
+		t.Errorf("Expected a page of one item followed by another page, got %d items and next offset %d", len(feed.Items), feed.NextOffset)
+	}
+
+	// Activities of a friendship that ended are removed
+	err := recorder.Publish(context.Background(), &events.Event{ID: "4d5e6f7a-8a7d-11eb-8dcd-0242ac130003", Type: events.FriendRemoved, UserID: "9c7d8e0f-8a7a-11eb-8dcd-0242ac130003", OtherUserID: friendID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed = &data.Feed{}
+	_ = json.Unmarshal(getFeed(relationshipHandler, viewerID, "").Body.Bytes(), feed)
+	if len(feed.Items) != 1 || feed.Items[0].Type != data.FeedInviteAccepted {
+		t.Errorf("Expected only the accepted invite left in the feed, got %d items", len(feed.Items))
+	}
+}
+
+func TestGetFeedOfAnotherUser(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	request := httptest.NewRequest(http.MethodGet, "/feed/6f4a5b7c-8a7a-11eb-8dcd-0242ac130003", nil)
+	request = withBearerToken(request, "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003")
+	request = mux.SetURLVars(request, map[string]string{"user_id": "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003"})
+	response := httptest.NewRecorder()
+	relationshipHandler.GetFeedByUserID(response, request)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d but got : %d", http.StatusForbidden, response.Code)
+	}
+}
+
+func answerSessionInviteAs(relationshipHandler *RelationshipsHandler, id string, userID string, answer string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/session-invites/"+id+"/"+answer, nil)
+	request = withBearerToken(request, userID)
+	request = mux.SetURLVars(request, map[string]string{"session_invite_id": id})
+	response := httptest.NewRecorder()
+	if answer == "accept" {
+		relationshipHandler.AcceptSessionInvite(response, request)
+	} else {
+		relationshipHandler.DeclineSessionInvite(response, request)
+	}
+	return response
+}
+
+func TestSendAndAcceptSessionInvite(t *testing.T) {
+	published := events.Events{}
+	unsubscribe := testEvents.Subscribe(func(event *events.Event) {
+		published = append(published, event)
+	})
+	defer unsubscribe()
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	hostID := "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003"
+	inviteeID := "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003"
+	body := `{"session_id": "match-42", "invitee_id": "` + inviteeID + `"}`
+
+	request := httptest.NewRequest(http.MethodPost, "/session-invites", strings.NewReader(body))
+	request = withBearerToken(request, hostID)
+	response := httptest.NewRecorder()
+	relationshipHandler.SendSessionInvite(response, request)
+	if response.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusCreated, response.Code)
+	}
+	sessionInvite := &data.SessionInvite{}
+	_ = json.Unmarshal(response.Body.Bytes(), sessionInvite)
+	if sessionInvite.HostID != hostID || sessionInvite.Status != data.SessionInvitePending || sessionInvite.ExpiresAt.IsZero() {
+		t.Errorf("Unexpected session invite : %s", response.Body.String())
+	}
+
+	// A second invite to the same session waits for the first one
+	request = httptest.NewRequest(http.MethodPost, "/session-invites", strings.NewReader(body))
+	request = withBearerToken(request, hostID)
+	response = httptest.NewRecorder()
+	relationshipHandler.SendSessionInvite(response, request)
+	if response.Code != http.StatusConflict {
+		t.Errorf("Expected status code %d for a duplicate invite but got : %d", http.StatusConflict, response.Code)
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/session-invites/"+inviteeID, nil)
+	request = withBearerToken(request, inviteeID)
+	request = mux.SetURLVars(request, map[string]string{"user_id": inviteeID})
+	response = httptest.NewRecorder()
+	relationshipHandler.GetSessionInvitesByUserID(response, request)
+	if !strings.Contains(response.Body.String(), sessionInvite.ID) {
+		t.Errorf("Expected the pending session invite of the invitee but got : %s", response.Body.String())
+	}
+
+	// Only the invitee can answer
+	response = answerSessionInviteAs(relationshipHandler, sessionInvite.ID, hostID, "accept")
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d for the host but got : %d", http.StatusNotFound, response.Code)
+	}
+	response = answerSessionInviteAs(relationshipHandler, sessionInvite.ID, inviteeID, "accept")
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), `"status":"accepted"`) {
+		t.Errorf("Expected the accepted session invite but got %d : %s", response.Code, response.Body.String())
+	}
+	response = answerSessionInviteAs(relationshipHandler, sessionInvite.ID, inviteeID, "decline")
+	if response.Code != http.StatusConflict {
+		t.Errorf("Expected status code %d for an answered invite but got : %d", http.StatusConflict, response.Code)
+	}
+
+	if len(published) != 2 || published[0].Type != events.SessionInviteSent || published[1].Type != events.SessionInviteAccepted || published[1].OtherUserID != hostID {
+		t.Errorf("Expected the sent and accepted session invite events but got %+v", published)
+	}
+}
+
+func TestSendSessionInviteToStranger(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	request := httptest.NewRequest(http.MethodPost, "/session-invites", strings.NewReader(`{"session_id": "match-42", "invitee_id": "9c7d8e0f-8a7a-11eb-8dcd-0242ac130003"}`))
+	request = withBearerToken(request, "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003")
+	response := httptest.NewRecorder()
+	relationshipHandler.SendSessionInvite(response, request)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d but got : %d", http.StatusForbidden, response.Code)
+	}
+}
+
+func TestIngestMatchRosterAndGetRecentPlayers(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	userID := "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003"
+	strangerID := "9c7d8e0f-8a7a-11eb-8dcd-0242ac130003"
+	body := `{"match_id": "match-7", "user_ids": ["` + userID + `", "` + strangerID + `", "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003"]}`
+
+	// A roster sent again by the game server isn't counted twice
+	for i := 0; i < 2; i++ {
+		request := httptest.NewRequest(http.MethodPost, "/internal/matches", strings.NewReader(body))
+		response := httptest.NewRecorder()
+		relationshipHandler.IngestMatchRoster(response, request)
+		if response.Code != http.StatusNoContent {
+			t.Fatalf("Expected status code %d but got : %d", http.StatusNoContent, response.Code)
+		}
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/recent/"+userID, nil)
+	request = withBearerToken(request, userID)
+	request = mux.SetURLVars(request, map[string]string{"user_id": userID})
+	response := httptest.NewRecorder()
+	relationshipHandler.GetRecentPlayersByUserID(response, request)
+	recentPlayers := data.RecentPlayers{}
+	_ = json.Unmarshal(response.Body.Bytes(), &recentPlayers)
+	if response.Code != http.StatusOK || len(recentPlayers) != 2 || recentPlayers[0].Matches != 1 {
+		t.Errorf("Expected the two co-players of the match but got %d : %s", response.Code, response.Body.String())
+	}
+
+	// The co-player the user isn't friend with is suggested, the friend isn't
+	request = httptest.NewRequest(http.MethodGet, "/suggestions/"+userID, nil)
+	request = withBearerToken(request, userID)
+	request = mux.SetURLVars(request, map[string]string{"user_id": userID})
+	response = httptest.NewRecorder()
+	relationshipHandler.GetFriendSuggestionsByUserID(response, request)
+	suggestions := data.FriendSuggestions{}
+	_ = json.Unmarshal(response.Body.Bytes(), &suggestions)
+	suggested := false
+	for _, suggestion := range suggestions {
+		suggested = suggested || (suggestion.UserID == strangerID && suggestion.MatchesTogether == 1 && suggestion.Score == 1)
+	}
+	if !suggested {
+		t.Errorf("Expected the co-player in the suggestions but got : %s", response.Body.String())
+	}
+	if strings.Contains(response.Body.String(), `"user_id":"4d2e3f5a-8a7a-11eb-8dcd-0242ac130003"`) {
+		t.Errorf("Expected no suggestion of a friend but got : %s", response.Body.String())
+	}
+}
+
+func TestGetRecentPlayersOfAnotherUser(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	request := httptest.NewRequest(http.MethodGet, "/recent/3c1d2e4f-8a7a-11eb-8dcd-0242ac130003", nil)
+	request = withBearerToken(request, "9c7d8e0f-8a7a-11eb-8dcd-0242ac130003")
+	request = mux.SetURLVars(request, map[string]string{"user_id": "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003"})
+	response := httptest.NewRecorder()
+	relationshipHandler.GetRecentPlayersByUserID(response, request)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d but got : %d", http.StatusForbidden, response.Code)
+	}
+}
+
+func TestRecentPlayersAndSuggestionsLeaveOutBannedUsers(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	userID := "1c2d3e4f-8a82-11eb-8dcd-0242ac130003"
+	bannedID := "2d3e4f5a-8a82-11eb-8dcd-0242ac130003"
+	body := `{"match_id": "match-9", "user_ids": ["` + userID + `", "` + bannedID + `"]}`
+	request := httptest.NewRequest(http.MethodPost, "/internal/matches", strings.NewReader(body))
+	response := httptest.NewRecorder()
+	relationshipHandler.IngestMatchRoster(response, request)
+	if response.Code != http.StatusNoContent {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusNoContent, response.Code)
+	}
+	getAsUser := func(path string, handle http.HandlerFunc) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path+userID, nil)
+		request = withBearerToken(request, userID)
+		request = mux.SetURLVars(request, map[string]string{"user_id": userID})
+		response := httptest.NewRecorder()
+		handle(response, request)
+		return response
+	}
+	if response = getAsUser("/suggestions/", relationshipHandler.GetFriendSuggestionsByUserID); !strings.Contains(response.Body.String(), bannedID) {
+		t.Fatalf("Expected the co-player to be suggested before the ban but got : %s", response.Body.String())
+	}
+
+	postUserEvent(relationshipHandler, data.UserBanned, bannedID)
+	defer postUserEvent(relationshipHandler, data.UserUnbanned, bannedID)
+
+	response = getAsUser("/recent/", relationshipHandler.GetRecentPlayersByUserID)
+	if response.Code != http.StatusOK || strings.Contains(response.Body.String(), bannedID) {
+		t.Errorf("Expected the banned co-player to be left out but got %d : %s", response.Code, response.Body.String())
+	}
+	response = getAsUser("/suggestions/", relationshipHandler.GetFriendSuggestionsByUserID)
+	if response.Code != http.StatusOK || strings.Contains(response.Body.String(), bannedID) {
+		t.Errorf("Expected no suggestion of a banned user but got %d : %s", response.Code, response.Body.String())
+	}
+}
+
+func TestGetFriendSuggestionsWithOffset(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	request := httptest.NewRequest(http.MethodGet, "/suggestions/3c1d2e4f-8a7a-11eb-8dcd-0242ac130003?offset=10", nil)
+	request = withBearerToken(request, "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003")
+	request = mux.SetURLVars(request, map[string]string{"user_id": "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003"})
+	response := httptest.NewRecorder()
+	relationshipHandler.GetFriendSuggestionsByUserID(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d but got : %d", http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestIngestMatchRosterWithOnePlayer(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	request := httptest.NewRequest(http.MethodPost, "/internal/matches", strings.NewReader(`{"match_id": "match-8", "user_ids": ["3c1d2e4f-8a7a-11eb-8dcd-0242ac130003"]}`))
+	response := httptest.NewRecorder()
+	relationshipHandler.IngestMatchRoster(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d but got : %d", http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestGetFriendsListSortedByAffinity(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	userID := "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003"
+
+	// A match played with the first friend
+	body := `{"match_id": "match-10", "user_ids": ["` + userID + `", "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003"]}`
+	request := httptest.NewRequest(http.MethodPost, "/internal/matches", strings.NewReader(body))
+	response := httptest.NewRecorder()
+	relationshipHandler.IngestMatchRoster(response, request)
+	if response.Code != http.StatusNoContent {
+		t.Fatalf("Expected status code %d but got : %d", http.StatusNoContent, response.Code)
+	}
+
+	// Messages to a friend count once each, a chat event sent again isn't counted twice
+	for _, eventID := range []string{"message-1", "message-1", "message-2"} {
+		body := `{"id": "` + eventID + `", "type": "MessageSent", "sender_id": "` + userID + `", "recipient_ids": ["6f4a5b7c-8a7a-11eb-8dcd-0242ac130003"]}`
+		request := httptest.NewRequest(http.MethodPost, "/internal/chat-events", strings.NewReader(body))
+		response := httptest.NewRecorder()
+		relationshipHandler.HandleChatEvent(response, request)
+		if response.Code != http.StatusNoContent {
+			t.Fatalf("Expected status code %d but got : %d", http.StatusNoContent, response.Code)
+		}
+	}
+
+	// The match played with the first friend weighs more than the messages
+	request = httptest.NewRequest(http.MethodGet, "/friends/"+userID+"?sort=affinity", nil)
+	request = withBearerToken(request, userID)
+	request = mux.SetURLVars(request, map[string]string{"user_id": userID})
+	response = httptest.NewRecorder()
+	relationshipHandler.GetFriendsListByUserID(response, request)
+	friends := data.DetailedRelationships{}
+	_ = json.Unmarshal(response.Body.Bytes(), &friends)
+	if response.Code != http.StatusOK || len(friends) < 3 {
+		t.Fatalf("Expected the friends of the user but got %d : %s", response.Code, response.Body.String())
+	}
+	if friends[0].User.ID != "4d2e3f5a-8a7a-11eb-8dcd-0242ac130003" || friends[1].User.ID != "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003" || friends[1].Affinity < 0.49 || friends[1].Affinity > 0.5 {
+		t.Errorf("Expected the friends sorted by affinity but got : %s", response.Body.String())
+	}
+}
+
+func TestGetFriendsListOfAnotherUserSortedByAffinity(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	request := httptest.NewRequest(http.MethodGet, "/friends/3c1d2e4f-8a7a-11eb-8dcd-0242ac130003?sort=affinity", nil)
+	request = withBearerToken(request, "6f4a5b7c-8a7a-11eb-8dcd-0242ac130003")
+	request = mux.SetURLVars(request, map[string]string{"user_id": "3c1d2e4f-8a7a-11eb-8dcd-0242ac130003"})
+	response := httptest.NewRecorder()
+	relationshipHandler.GetFriendsListByUserID(response, request)
+	if response.Code != http.StatusForbidden || !strings.Contains(response.Body.String(), "affinity") {
+		t.Errorf("Expected the affinity to be refused but got %d : %s", response.Code, response.Body.String())
+	}
+}
+
+func TestCountFriendsOfUserWithoutFriends(t *testing.T) {
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+	request := httptest.NewRequest(http.MethodGet, "/friends/0d1e2f3a-8a80-11eb-8dcd-0242ac130003", nil)
+	request = withBearerToken(request, "9c7d8e0f-8a7a-11eb-8dcd-0242ac130003")
+	request = mux.SetURLVars(request, map[string]string{"user_id": "0d1e2f3a-8a80-11eb-8dcd-0242ac130003"})
+	response := httptest.NewRecorder()
+	relationshipHandler.GetFriendsListByUserID(response, request)
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), `"count":0`) {
+		t.Errorf("Expected a count of 0 but got %d : %s", response.Code, response.Body.String())
+	}
+}
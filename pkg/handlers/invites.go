@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+// SendInvite sends a friend request from the caller to a user found by user ID, username or email
+// When that user already sent a request to the caller or auto accepts the caller's request, the friendship is returned
+func (relationshipHandler *RelationshipsHandler) SendInvite(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "sendInvite")
+	defer span.End()
+	callerID := getCallerID(request)
+
+	log.Info("SendInvite request", "user_id", callerID)
+
+	if callerID == "" {
+		http.Error(responseWriter, "Missing user in access token", http.StatusUnauthorized)
+		return
+	}
+
+	inviteRequest := &data.InviteRequest{}
+	err := json.NewDecoder(request.Body).Decode(inviteRequest)
+	if err != nil {
+		log.Error(err, "Error deserializing invite request")
+		http.Error(responseWriter, "Error reading invite request", http.StatusBadRequest)
+		return
+	}
+
+	err = inviteRequest.ValidateInviteRequest()
+	if err != nil {
+		log.Error(err, "Error validating invite request")
+		http.Error(responseWriter, fmt.Sprintf("Error validating invite request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	receiverID, err := relationshipHandler.resolveReceiver(request, inviteRequest)
+	switch err {
+	case nil:
+	case data.ErrorUserNotFound:
+		log.Info("No user matches the invite request")
+		http.Error(responseWriter, "User not found", http.StatusNotFound)
+		return
+	case data.ErrorAmbiguousUser:
+		log.Info("Several users match the invite request")
+		http.Error(responseWriter, "Several users match, use a more precise lookup", http.StatusConflict)
+		return
+	default:
+		log.Error(err, "Error looking up invited user")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	relationship := data.NewInvite(callerID, receiverID)
+	err = relationshipHandler.db.AddRelationship(request.Context(), relationship)
+	if err != nil {
+		writeAddRelationshipError(responseWriter, err)
+		return
+	}
+
+	// The receiver already sent a request to the caller, they are now friends
+	if relationship.IsFriendship() {
+		responseWriter.WriteHeader(http.StatusOK)
+	} else {
+		responseWriter.WriteHeader(http.StatusCreated)
+	}
+	err = json.NewEncoder(responseWriter).Encode(relationship)
+	if err != nil {
+		log.Error(err, "Error serializing relationship")
+	}
+}
+
+// resolveReceiver returns the user ID of the receiver of the invite, looking it up in microservice-user when needed
+func (relationshipHandler *RelationshipsHandler) resolveReceiver(request *http.Request, inviteRequest *data.InviteRequest) (string, error) {
+	lookup := inviteRequest.Lookup()
+	if lookup == nil {
+		return inviteRequest.UserID, nil
+	}
+
+	users, err := relationshipHandler.db.FindUsers(request.Context(), lookup)
+	if err != nil {
+		return "", err
+	}
+
+	switch len(users) {
+	case 0:
+		return "", data.ErrorUserNotFound
+	case 1:
+		return users[0].ID, nil
+	default:
+		return "", data.ErrorAmbiguousUser
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+// MergeUserRelationships moves every relationship of the source user to the target user of the received merge request
+// Merging again the same accounts is safe and resumes an interrupted merge
+func (relationshipHandler *RelationshipsHandler) MergeUserRelationships(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "mergeUserRelationships")
+	defer span.End()
+
+	mergeRequest := &data.MergeRequest{}
+	err := json.NewDecoder(request.Body).Decode(mergeRequest)
+	if err != nil {
+		log.Error(err, "Error deserializing merge request")
+		http.Error(responseWriter, "Error reading merge request", http.StatusBadRequest)
+		return
+	}
+
+	err = mergeRequest.ValidateMergeRequest()
+	if err != nil {
+		log.Error(err, "Error validating merge request")
+		http.Error(responseWriter, fmt.Sprintf("Error validating merge request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	log.Info("MergeUserRelationships request", "source_user_id", mergeRequest.SourceUserID, "target_user_id", mergeRequest.TargetUserID)
+
+	result, err := relationshipHandler.db.MergeUserRelationships(request.Context(), mergeRequest.SourceUserID, mergeRequest.TargetUserID)
+	switch err {
+	case nil:
+		err = json.NewEncoder(responseWriter).Encode(result)
+		if err != nil {
+			log.Error(err, "Error serializing merge result")
+		}
+		return
+	case data.ErrorUserNotFound:
+		log.Error(err, "Target UserID doesn't exist")
+		http.Error(responseWriter, "Target UserID doesn't exist", http.StatusBadRequest)
+		return
+	case data.ErrorSameUserID:
+		log.Error(err, "Merging a user with itself")
+		http.Error(responseWriter, "Source and target users have the same userID", http.StatusBadRequest)
+		return
+	default:
+		log.Error(err, "Error merging user relationships")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 
 	"github.com/Ubivius/microservice-friendslist/pkg/data"
 )
@@ -37,3 +39,33 @@ func (relationshipHandler *RelationshipsHandler) MiddlewareRelationshipValidatio
 		next.ServeHTTP(responseWriter, request)
 	})
 }
+
+// MiddlewareAdminOnly restricts the routes to callers with the admin realm role
+func (relationshipHandler *RelationshipsHandler) MiddlewareAdminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		if !hasRole(request, RoleAdmin) {
+			log.Info("Admin route refused, caller is missing the admin role", "path", request.URL.Path)
+			http.Error(responseWriter, "Admin role required", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(responseWriter, request)
+	})
+}
+
+// MiddlewareInternalOnly restricts the routes to trusted services sending the shared key from INTERNAL_API_KEY in the X-Internal-Api-Key header
+// Every request is refused when no key is configured
+func (relationshipHandler *RelationshipsHandler) MiddlewareInternalOnly(next http.Handler) http.Handler {
+	internalAPIKey := os.Getenv("INTERNAL_API_KEY")
+
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		key := request.Header.Get("X-Internal-Api-Key")
+		if internalAPIKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(internalAPIKey)) != 1 {
+			log.Info("Internal route refused, invalid internal API key", "path", request.URL.Path)
+			http.Error(responseWriter, "Invalid internal API key", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(responseWriter, request)
+	})
+}
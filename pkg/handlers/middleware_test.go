@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 
@@ -102,3 +103,26 @@ func TestValidationMiddlewareWithNoUser1(t *testing.T) {
 		t.Error("Expected error on field validation for UserID but got : ", response.Body.String())
 	}
 }
+
+func TestInternalMiddlewareWithWrongKey(t *testing.T) {
+	os.Setenv("INTERNAL_API_KEY", "party-service-key")
+	defer os.Unsetenv("INTERNAL_API_KEY")
+
+	relationshipHandler := NewRelationshipsHandler(newRelationshipDB())
+
+	router := mux.NewRouter()
+	router.HandleFunc("/internal/friendships", relationshipHandler.AddPartyFriendships)
+	router.Use(relationshipHandler.MiddlewareInternalOnly)
+
+	for key, expectedCode := range map[string]int{"": http.StatusUnauthorized, "wrong-key": http.StatusUnauthorized, "party-service-key": http.StatusBadRequest} {
+		request := httptest.NewRequest(http.MethodPost, "/internal/friendships", strings.NewReader(`{"user_ids": []}`))
+		request.Header.Set("X-Internal-Api-Key", key)
+		response := httptest.NewRecorder()
+
+		router.ServeHTTP(response, request)
+
+		if response.Code != expectedCode {
+			t.Errorf("Expected status code %d with key %q, but got %d", expectedCode, key, response.Code)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+// AddPartyFriendships makes every user of the received party friends with each other
+// Pairs of users who can't become friends, like blocked users, are skipped and listed in the result
+func (relationshipHandler *RelationshipsHandler) AddPartyFriendships(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "addPartyFriendships")
+	defer span.End()
+
+	partyRequest := &data.PartyFriendshipRequest{}
+	err := json.NewDecoder(request.Body).Decode(partyRequest)
+	if err != nil {
+		log.Error(err, "Error deserializing party friendship request")
+		http.Error(responseWriter, "Error reading party friendship request", http.StatusBadRequest)
+		return
+	}
+
+	err = partyRequest.ValidatePartyFriendshipRequest()
+	if err != nil {
+		log.Error(err, "Error validating party friendship request")
+		http.Error(responseWriter, fmt.Sprintf("Error validating party friendship request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	log.Info("AddPartyFriendships request", "users", len(partyRequest.UserIDs))
+
+	result, err := relationshipHandler.db.AddPartyFriendships(request.Context(), partyRequest.UserIDs)
+	switch err {
+	case nil:
+		err = json.NewEncoder(responseWriter).Encode(result)
+		if err != nil {
+			log.Error(err, "Error serializing party friendship result")
+		}
+		return
+	case data.ErrorUserNotFound:
+		log.Error(err, "A UserID doesn't exist")
+		http.Error(responseWriter, "A UserID doesn't exist", http.StatusBadRequest)
+		return
+	default:
+		log.Error(err, "Error adding party friendships")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
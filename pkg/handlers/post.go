@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/Ubivius/microservice-friendslist/pkg/data"
@@ -8,17 +11,50 @@ import (
 )
 
 // AddRelationship creates a new relationship from the received JSON
+// A friend request crossing a pending request of the other user or auto accepted by them makes them friends, the friendship is then returned
 func (relationshipHandler *RelationshipsHandler) AddRelationship(responseWriter http.ResponseWriter, request *http.Request) {
 	_, span := otel.Tracer("friendslist").Start(request.Context(), "addRelationship")
 	defer span.End()
 	log.Info("AddRelationship request")
 	relationship := request.Context().Value(KeyRelationship{}).(*data.Relationship)
+	wasPending := relationship.IsPending()
 
 	err := relationshipHandler.db.AddRelationship(request.Context(), relationship)
-	switch err {
-	case nil:
-		responseWriter.WriteHeader(http.StatusNoContent)
+	if err != nil {
+		writeAddRelationshipError(responseWriter, err)
+		return
+	}
+
+	if wasPending && relationship.IsFriendship() {
+		log.Info("Friend request accepted", "id", relationship.ID)
+		err = json.NewEncoder(responseWriter).Encode(relationship)
+		if err != nil {
+			log.Error(err, "Error serializing relationship")
+		}
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// writeAddRelationshipError answers a request that failed to create a relationship
+func writeAddRelationshipError(responseWriter http.ResponseWriter, err error) {
+	var quotaError *data.InviteQuotaError
+	if errors.As(err, &quotaError) {
+		log.Info("Friend request quota exceeded", "limit", quotaError.Limit, "retry_after", quotaError.RetryAfter.String())
+		writeRetryAfter(responseWriter, quotaError.RetryAfter)
+		http.Error(responseWriter, fmt.Sprintf("Too many friend requests, %s limit reached", quotaError.Limit), http.StatusTooManyRequests)
 		return
+	}
+
+	var limitError *data.FriendLimitError
+	if errors.As(err, &limitError) {
+		log.Info("Friend limit reached", "user_id", limitError.UserID, "limit", limitError.Limit)
+		http.Error(responseWriter, fmt.Sprintf("User %s reached the maximum of %d friends", limitError.UserID, limitError.Limit), http.StatusConflict)
+		return
+	}
+
+	switch err {
 	case data.ErrorUserNotFound:
 		log.Error(err, "A UserID doesn't exist")
 		http.Error(responseWriter, "A UserID doesn't exist", http.StatusBadRequest)
@@ -31,6 +67,14 @@ func (relationshipHandler *RelationshipsHandler) AddRelationship(responseWriter
 		log.Error(err, "Relationship already exist")
 		http.Error(responseWriter, "Relationship already exist", http.StatusBadRequest)
 		return
+	case data.ErrorInvitesNotAllowed:
+		log.Info("Friend request refused by the privacy settings of the user")
+		http.Error(responseWriter, "The user doesn't accept friend requests from you", http.StatusForbidden)
+		return
+	case data.ErrorUserFrozen:
+		log.Info("Relationship change refused, one of the users is banned")
+		http.Error(responseWriter, "One of the users is banned, their relationships can't change", http.StatusConflict)
+		return
 	default:
 		log.Error(err, "Error adding relationship")
 		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
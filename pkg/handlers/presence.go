@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+// errorUnsupportedStatus : Status filter specific error
+var errorUnsupportedStatus = fmt.Errorf("unsupported status")
+
+// onlineStatuses are the statuses listed by the online friends endpoint when no status is asked
+var onlineStatuses = map[string]bool{data.PresenceOnline: true, data.PresenceInGame: true, data.PresenceAway: true}
+
+// UpdatePresence stores the status of a user sent by the presence source
+func (relationshipHandler *RelationshipsHandler) UpdatePresence(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "updatePresence")
+	defer span.End()
+
+	presence := &data.Presence{}
+	err := json.NewDecoder(request.Body).Decode(presence)
+	if err != nil {
+		log.Error(err, "Error deserializing presence")
+		http.Error(responseWriter, "Error reading presence", http.StatusBadRequest)
+		return
+	}
+
+	err = presence.ValidatePresence()
+	if err != nil {
+		log.Error(err, "Error validating presence")
+		http.Error(responseWriter, fmt.Sprintf("Error validating presence: %s", err), http.StatusBadRequest)
+		return
+	}
+	if presence.UpdatedOn.IsZero() {
+		presence.UpdatedOn = time.Now().UTC()
+	}
+
+	err = relationshipHandler.db.SetPresence(request.Context(), presence)
+	if err != nil {
+		log.Error(err, "Error updating presence", "user_id", presence.UserID)
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// GetOnlineFriendsByUserID returns the friends of a user who aren't offline, with the number of friends in each status
+// The status query parameter lists other statuses, Offline included
+func (relationshipHandler *RelationshipsHandler) GetOnlineFriendsByUserID(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "getOnlineFriendsByUserID")
+	defer span.End()
+	id := getUserID(request)
+
+	log.Info("GetOnlineFriendsByUserID request for userID", "id", id)
+
+	statuses, err := parseStatusFilter(request.URL.Query().Get("status"), onlineStatuses)
+	if err != nil {
+		http.Error(responseWriter, "Unsupported status", http.StatusBadRequest)
+		return
+	}
+
+	friends, view, ok := relationshipHandler.readFriendsList(responseWriter, request, id)
+	if !ok {
+		return
+	}
+	// Statuses aren't shown to viewers only allowed to count the friends
+	if view == data.FriendsListCount {
+		http.Error(responseWriter, "Friends list is private", http.StatusForbidden)
+		return
+	}
+
+	err = relationshipHandler.applyPresence(request.Context(), friends)
+	if err == nil {
+		err = relationshipHandler.sortFriendsList(request, id, friends)
+	}
+	switch err {
+	case nil:
+		friendsPresence := &data.FriendsPresence{
+			UserID:  id,
+			Counts:  countFriendsByStatus(friends),
+			Friends: *filterFriendsByStatus(friends, statuses),
+		}
+		err = json.NewEncoder(responseWriter).Encode(friendsPresence)
+		if err != nil {
+			log.Error(err, "Error serializing online friends")
+		}
+		return
+	case errorUnsupportedSort:
+		http.Error(responseWriter, "Unsupported sort", http.StatusBadRequest)
+		return
+	case errorAffinityNotAllowed:
+		http.Error(responseWriter, "Not allowed to sort the friends of this user by affinity", http.StatusForbidden)
+		return
+	default:
+		log.Error(err, "Error fetching presence")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// applyPresence replaces the status of the friends by their last status received, friends without a recent status are offline
+func (relationshipHandler *RelationshipsHandler) applyPresence(ctx context.Context, friends *data.DetailedRelationships) error {
+	userIDs := make([]string, 0, len(*friends))
+	for _, friend := range *friends {
+		userIDs = append(userIDs, friend.User.ID)
+	}
+	statuses, err := relationshipHandler.db.GetPresenceStatuses(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, friend := range *friends {
+		status, ok := statuses[friend.User.ID]
+		if !ok {
+			status = data.PresenceOffline
+		}
+		friend.User.Status = status
+	}
+	return nil
+}
+
+// parseStatusFilter reads a comma separated list of statuses, the default statuses are returned when the list is empty
+func parseStatusFilter(value string, defaultStatuses map[string]bool) (map[string]bool, error) {
+	if value == "" {
+		return defaultStatuses, nil
+	}
+	statuses := map[string]bool{}
+	for _, status := range strings.Split(value, ",") {
+		status = strings.TrimSpace(status)
+		if !data.IsPresenceStatus(status) {
+			return nil, errorUnsupportedStatus
+		}
+		statuses[status] = true
+	}
+	return statuses, nil
+}
+
+// filterFriendsByStatus keeps the friends with one of the statuses
+func filterFriendsByStatus(friends *data.DetailedRelationships, statuses map[string]bool) *data.DetailedRelationships {
+	statusFriends := data.DetailedRelationships{}
+	for _, friend := range *friends {
+		if statuses[friend.User.Status] {
+			statusFriends = append(statusFriends, friend)
+		}
+	}
+	return &statusFriends
+}
+
+// countFriendsByStatus returns the number of friends in each status, every status being listed
+func countFriendsByStatus(friends *data.DetailedRelationships) map[string]int {
+	counts := make(map[string]int, len(data.PresenceStatuses))
+	for _, status := range data.PresenceStatuses {
+		counts[status] = 0
+	}
+	for _, friend := range *friends {
+		counts[friend.User.Status]++
+	}
+	return counts
+}
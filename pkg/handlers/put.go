@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/Ubivius/microservice-friendslist/pkg/data"
@@ -16,6 +19,14 @@ func (relationshipHandler *RelationshipsHandler) UpdateRelationships(responseWri
 
 	// Update relationship
 	err := relationshipHandler.db.UpdateRelationship(request.Context(), relationship)
+
+	var limitError *data.FriendLimitError
+	if errors.As(err, &limitError) {
+		log.Info("Friend limit reached", "user_id", limitError.UserID, "limit", limitError.Limit)
+		http.Error(responseWriter, fmt.Sprintf("User %s reached the maximum of %d friends", limitError.UserID, limitError.Limit), http.StatusConflict)
+		return
+	}
+
 	switch err {
 	case nil:
 		responseWriter.WriteHeader(http.StatusNoContent)
@@ -36,9 +47,62 @@ func (relationshipHandler *RelationshipsHandler) UpdateRelationships(responseWri
 		log.Error(err, "Relationship not found")
 		http.Error(responseWriter, "Relationship not found", http.StatusNotFound)
 		return
+	case data.ErrorInvalidTransition:
+		log.Error(err, "Relationship type transition not allowed")
+		http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+		return
+	case data.ErrorUserFrozen:
+		log.Info("Relationship change refused, one of the users is banned")
+		http.Error(responseWriter, "One of the users is banned, their relationships can't change", http.StatusConflict)
+		return
 	default:
 		log.Error(err, "Error updating relationship")
 		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
+
+// UpdateRelationshipPreferences replaces the preferences of the caller on a relationship with the received JSON
+func (relationshipHandler *RelationshipsHandler) UpdateRelationshipPreferences(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "updateRelationshipPreferences")
+	defer span.End()
+	id := getRelationshipID(request)
+	callerID := getCallerID(request)
+	log.Info("UpdateRelationshipPreferences request", "id", id)
+
+	if callerID == "" {
+		http.Error(responseWriter, "Missing user in access token", http.StatusUnauthorized)
+		return
+	}
+
+	preferences := &data.RelationshipPreferences{}
+	err := json.NewDecoder(request.Body).Decode(preferences)
+	if err != nil {
+		log.Error(err, "Error deserializing relationship preferences")
+		http.Error(responseWriter, "Error reading relationship preferences", http.StatusBadRequest)
+		return
+	}
+
+	err = preferences.ValidatePreferences()
+	if err != nil {
+		log.Error(err, "Error validating relationship preferences")
+		http.Error(responseWriter, fmt.Sprintf("Error validating relationship preferences: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	// Users can only edit their own side of the relationship
+	err = relationshipHandler.db.UpdateRelationshipPreferences(request.Context(), id, callerID, preferences)
+	switch err {
+	case nil:
+		responseWriter.WriteHeader(http.StatusNoContent)
+		return
+	case data.ErrorRelationshipNotFound:
+		log.Error(err, "Relationship not found")
+		http.Error(responseWriter, "Relationship not found", http.StatusNotFound)
+		return
+	default:
+		log.Error(err, "Error updating relationship preferences")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+// IngestMatchRoster counts a match played together for every pair of users of the roster sent by a game server
+func (relationshipHandler *RelationshipsHandler) IngestMatchRoster(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "ingestMatchRoster")
+	defer span.End()
+
+	matchRoster := &data.MatchRoster{}
+	err := json.NewDecoder(request.Body).Decode(matchRoster)
+	if err != nil {
+		log.Error(err, "Error deserializing match roster")
+		http.Error(responseWriter, "Error reading match roster", http.StatusBadRequest)
+		return
+	}
+
+	err = matchRoster.ValidateMatchRoster()
+	if err != nil {
+		log.Error(err, "Error validating match roster")
+		http.Error(responseWriter, fmt.Sprintf("Error validating match roster: %s", err), http.StatusBadRequest)
+		return
+	}
+	if matchRoster.PlayedAt.IsZero() {
+		matchRoster.PlayedAt = time.Now().UTC()
+	}
+
+	log.Info("IngestMatchRoster request for matchID", "match_id", matchRoster.MatchID, "players", len(matchRoster.UserIDs))
+
+	err = relationshipHandler.db.AddMatchRoster(request.Context(), matchRoster)
+	if err != nil {
+		log.Error(err, "Error adding match roster", "match_id", matchRoster.MatchID)
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Friends who played together get closer, the roster can be sent again if counting fails
+	err = relationshipHandler.db.AddAffinitySignal(request.Context(), &data.AffinitySignal{
+		ID:         "match:" + matchRoster.MatchID,
+		Type:       data.AffinityMatch,
+		UserIDs:    matchRoster.UserIDs,
+		OccurredAt: matchRoster.PlayedAt,
+	})
+	if err != nil {
+		log.Error(err, "Error adding match affinity", "match_id", matchRoster.MatchID)
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// GetRecentPlayersByUserID returns a page of the users who recently played with a user, last played first
+// Users blocking or blocked by the user are left out, banned users are left out by the database
+func (relationshipHandler *RelationshipsHandler) GetRecentPlayersByUserID(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "getRecentPlayersByUserID")
+	defer span.End()
+	id := getUserID(request)
+
+	log.Info("GetRecentPlayersByUserID request for userID", "id", id)
+
+	if !isOwnerOrAdmin(request, id) {
+		http.Error(responseWriter, "Not allowed to read the recent players of this user", http.StatusForbidden)
+		return
+	}
+
+	offset, limit, err := getPage(request, data.DefaultRecentPlayersPageSize, data.MaxRecentPlayersPageSize)
+	if err != nil {
+		http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	recentPlayers, err := relationshipHandler.db.GetRecentPlayersByUserID(request.Context(), id, offset, limit)
+	if err != nil {
+		log.Error(err, "Error fetching recent players")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	isBlocked := relationshipHandler.blockChecker(request.Context(), id)
+	visiblePlayers := data.RecentPlayers{}
+	for _, recentPlayer := range recentPlayers {
+		blocked, err := isBlocked(recentPlayer.PlayerID)
+		if err != nil {
+			log.Error(err, "Error filtering recent players")
+			http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if blocked {
+			continue
+		}
+		recentPlayer.Player, err = relationshipHandler.db.GetUserByID(recentPlayer.PlayerID)
+		if err != nil {
+			log.Error(err, "Error fetching user details of a recent player", "user_id", recentPlayer.PlayerID)
+		}
+		visiblePlayers = append(visiblePlayers, recentPlayer)
+	}
+
+	err = json.NewEncoder(responseWriter).Encode(visiblePlayers)
+	if err != nil {
+		log.Error(err, "Error serializing recent players")
+	}
+}
+
+// GetFriendSuggestionsByUserID returns the users a user may know, ranked by their mutual friends and the matches they played together
+func (relationshipHandler *RelationshipsHandler) GetFriendSuggestionsByUserID(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "getFriendSuggestionsByUserID")
+	defer span.End()
+	id := getUserID(request)
+
+	log.Info("GetFriendSuggestionsByUserID request for userID", "id", id)
+
+	if !isOwnerOrAdmin(request, id) {
+		http.Error(responseWriter, "Not allowed to read the friend suggestions of this user", http.StatusForbidden)
+		return
+	}
+
+	limit, err := getLimit(request, data.DefaultSuggestionsSize, data.MaxSuggestionsSize)
+	if err != nil {
+		http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	suggestions, err := relationshipHandler.db.GetFriendSuggestions(request.Context(), id, limit)
+	if err != nil {
+		log.Error(err, "Error fetching friend suggestions")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, suggestion := range suggestions {
+		suggestion.User, err = relationshipHandler.db.GetUserByID(suggestion.UserID)
+		if err != nil {
+			log.Error(err, "Error fetching user details of a friend suggestion", "user_id", suggestion.UserID)
+		}
+	}
+
+	err = json.NewEncoder(responseWriter).Encode(suggestions)
+	if err != nil {
+		log.Error(err, "Error serializing friend suggestions")
+	}
+}
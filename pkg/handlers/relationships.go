@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/Ubivius/microservice-friendslist/pkg/database"
 	"github.com/gorilla/mux"
@@ -12,12 +15,13 @@ type KeyRelationship struct{}
 
 // RelationshipsHandler contains the items common to all relationship handler functions
 type RelationshipsHandler struct {
-	db database.RelationshipDB
+	db     database.RelationshipDB
+	stream *streamHub
 }
 
 // NewRelationshipsHandler returns a pointer to a RelationshipsHandler with the logger passed as a parameter
 func NewRelationshipsHandler(db database.RelationshipDB) *RelationshipsHandler {
-	return &RelationshipsHandler{db}
+	return &RelationshipsHandler{db: db, stream: newStreamHub()}
 }
 
 // getRelationshipID extracts the relationship ID from the URL
@@ -39,3 +43,12 @@ func getUserID(request *http.Request) string {
 
 	return userID
 }
+
+// writeRetryAfter sets the Retry-After header in seconds, rounded up
+func writeRetryAfter(responseWriter http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	responseWriter.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
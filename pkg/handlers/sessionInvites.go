@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+)
+
+// SendSessionInvite invites a friend of the caller to join the game session of the caller
+func (relationshipHandler *RelationshipsHandler) SendSessionInvite(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "sendSessionInvite")
+	defer span.End()
+	callerID := getCallerID(request)
+
+	log.Info("SendSessionInvite request", "user_id", callerID)
+
+	if callerID == "" {
+		http.Error(responseWriter, "Missing user in access token", http.StatusUnauthorized)
+		return
+	}
+
+	sessionInvite := &data.SessionInvite{}
+	err := json.NewDecoder(request.Body).Decode(sessionInvite)
+	if err != nil {
+		log.Error(err, "Error deserializing session invite")
+		http.Error(responseWriter, "Error reading session invite", http.StatusBadRequest)
+		return
+	}
+	sessionInvite.HostID = callerID
+
+	err = sessionInvite.ValidateSessionInvite()
+	if err != nil {
+		log.Error(err, "Error validating session invite")
+		http.Error(responseWriter, fmt.Sprintf("Error validating session invite: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	err = relationshipHandler.db.AddSessionInvite(request.Context(), sessionInvite)
+	switch err {
+	case nil:
+		responseWriter.WriteHeader(http.StatusCreated)
+		err = json.NewEncoder(responseWriter).Encode(sessionInvite)
+		if err != nil {
+			log.Error(err, "Error serializing session invite")
+		}
+		return
+	case data.ErrorSameUserID:
+		http.Error(responseWriter, "Users can't invite themselves", http.StatusBadRequest)
+		return
+	case data.ErrorNotFriends:
+		log.Info("Session invite refused, the users aren't friends", "invitee_id", sessionInvite.InviteeID)
+		http.Error(responseWriter, "Session invites can only be sent to friends", http.StatusForbidden)
+		return
+	case data.ErrorUserFrozen:
+		http.Error(responseWriter, "One of the users is banned, their relationships can't change", http.StatusConflict)
+		return
+	case data.ErrorSessionInviteExist:
+		http.Error(responseWriter, "The user already has a pending invite to this session", http.StatusConflict)
+		return
+	default:
+		log.Error(err, "Error adding session invite")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetSessionInvitesByUserID returns the session invites a user can still answer, newest first
+func (relationshipHandler *RelationshipsHandler) GetSessionInvitesByUserID(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "getSessionInvitesByUserID")
+	defer span.End()
+	id := getUserID(request)
+
+	log.Info("GetSessionInvitesByUserID request for userID", "id", id)
+
+	if !isOwnerOrAdmin(request, id) {
+		http.Error(responseWriter, "Not allowed to read the session invites of this user", http.StatusForbidden)
+		return
+	}
+
+	sessionInvites, err := relationshipHandler.db.GetSessionInvitesByUserID(request.Context(), id)
+	if err != nil {
+		log.Error(err, "Error fetching session invites")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(responseWriter).Encode(sessionInvites)
+	if err != nil {
+		log.Error(err, "Error serializing session invites")
+	}
+}
+
+// AcceptSessionInvite accepts a session invite of the caller, the response holds the session to join
+func (relationshipHandler *RelationshipsHandler) AcceptSessionInvite(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "acceptSessionInvite")
+	defer span.End()
+
+	relationshipHandler.answerSessionInvite(responseWriter, request, true)
+}
+
+// DeclineSessionInvite declines a session invite of the caller
+func (relationshipHandler *RelationshipsHandler) DeclineSessionInvite(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "declineSessionInvite")
+	defer span.End()
+
+	relationshipHandler.answerSessionInvite(responseWriter, request, false)
+}
+
+// answerSessionInvite saves the answer of the caller to a session invite and answers with the invite
+func (relationshipHandler *RelationshipsHandler) answerSessionInvite(responseWriter http.ResponseWriter, request *http.Request, accept bool) {
+	id := getSessionInviteID(request)
+	callerID := getCallerID(request)
+
+	log.Info("Answer to session invite", "id", id, "accept", accept)
+
+	sessionInvite, err := relationshipHandler.db.AnswerSessionInvite(request.Context(), id, callerID, accept)
+	switch err {
+	case nil:
+		err = json.NewEncoder(responseWriter).Encode(sessionInvite)
+		if err != nil {
+			log.Error(err, "Error serializing session invite")
+		}
+		return
+	case data.ErrorSessionInviteNotFound:
+		http.Error(responseWriter, "Session invite not found", http.StatusNotFound)
+		return
+	case data.ErrorSessionInviteAnswered:
+		http.Error(responseWriter, "Session invite already answered", http.StatusConflict)
+		return
+	case data.ErrorSessionInviteExpired:
+		http.Error(responseWriter, "Session invite expired", http.StatusGone)
+		return
+	case data.ErrorNotFriends:
+		http.Error(responseWriter, "The host is no longer a friend", http.StatusForbidden)
+		return
+	case data.ErrorUserFrozen:
+		http.Error(responseWriter, "One of the users is banned, their relationships can't change", http.StatusConflict)
+		return
+	default:
+		log.Error(err, "Error answering session invite")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// getSessionInviteID extracts the session invite ID from the URL
+// The verification of this variable is handled by gorilla/mux
+func getSessionInviteID(request *http.Request) string {
+	vars := mux.Vars(request)
+	return vars["session_invite_id"]
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+// GetUserSettings returns the privacy settings of a user
+func (relationshipHandler *RelationshipsHandler) GetUserSettings(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "getUserSettings")
+	defer span.End()
+	id := getUserID(request)
+
+	log.Info("GetUserSettings request for userID", "id", id)
+
+	if !isOwnerOrAdmin(request, id) {
+		http.Error(responseWriter, "Not allowed to read the settings of this user", http.StatusForbidden)
+		return
+	}
+
+	settings, err := relationshipHandler.db.GetUserSettings(request.Context(), id)
+	if err != nil {
+		log.Error(err, "Error fetching user settings")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(responseWriter).Encode(settings)
+	if err != nil {
+		log.Error(err, "Error serializing user settings")
+	}
+}
+
+// UpdateUserSettings replaces the privacy settings of a user with the received JSON
+func (relationshipHandler *RelationshipsHandler) UpdateUserSettings(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "updateUserSettings")
+	defer span.End()
+	id := getUserID(request)
+
+	log.Info("UpdateUserSettings request for userID", "id", id)
+
+	if getCallerID(request) != id {
+		http.Error(responseWriter, "Not allowed to update the settings of this user", http.StatusForbidden)
+		return
+	}
+
+	settings := &data.UserSettings{}
+	err := json.NewDecoder(request.Body).Decode(settings)
+	if err != nil {
+		log.Error(err, "Error deserializing user settings")
+		http.Error(responseWriter, "Error reading user settings", http.StatusBadRequest)
+		return
+	}
+	settings.UserID = id
+
+	err = settings.ValidateUserSettings()
+	if err != nil {
+		log.Error(err, "Error validating user settings")
+		http.Error(responseWriter, fmt.Sprintf("Error validating user settings: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	err = relationshipHandler.db.UpdateUserSettings(request.Context(), settings)
+	switch err {
+	case nil:
+		responseWriter.WriteHeader(http.StatusNoContent)
+		return
+	case data.ErrorUserNotFound:
+		log.Error(err, "UserID doesn't exist")
+		http.Error(responseWriter, "UserID doesn't exist", http.StatusBadRequest)
+		return
+	default:
+		log.Error(err, "Error updating user settings")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// friendsListView returns what the viewer sees of the friends list of the user
+// Users blocking the viewer never show their friends list to the viewer
+func (relationshipHandler *RelationshipsHandler) friendsListView(ctx context.Context, userID string, viewerID string) (data.FriendsListView, error) {
+	relationship, err := relationshipHandler.db.GetRelationshipByUserIDs(ctx, userID, viewerID)
+	if err != nil && err != data.ErrorRelationshipNotFound {
+		return data.FriendsListHidden, err
+	}
+	if relationship != nil && relationship.IsBlockedBy(userID) {
+		return data.FriendsListHidden, nil
+	}
+
+	settings, err := relationshipHandler.db.GetUserSettings(ctx, userID)
+	if err != nil {
+		return data.FriendsListHidden, err
+	}
+	return settings.FriendsListViewFor(relationship != nil && relationship.IsFriendship()), nil
+}
+
+// filterFriendsForViewer removes from a friends list the friends the viewer isn't allowed to see
+func (relationshipHandler *RelationshipsHandler) filterFriendsForViewer(ctx context.Context, friends *data.DetailedRelationships, viewerID string, view data.FriendsListView) (*data.DetailedRelationships, error) {
+	blockerIDs, err := relationshipHandler.db.GetBlockerIDsByUserID(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	hidden := make(map[string]bool, len(blockerIDs))
+	for _, blockerID := range blockerIDs {
+		hidden[blockerID] = true
+	}
+
+	var viewerFriends map[string]bool
+	if view == data.FriendsListMutual {
+		friendIDs, err := relationshipHandler.db.GetFriendIDsByUserID(ctx, viewerID)
+		if err != nil {
+			return nil, err
+		}
+		viewerFriends = make(map[string]bool, len(friendIDs))
+		for _, friendID := range friendIDs {
+			viewerFriends[friendID] = true
+		}
+	}
+
+	visibleFriends := data.DetailedRelationships{}
+	for _, friend := range *friends {
+		if hidden[friend.User.ID] {
+			continue
+		}
+		if viewerFriends != nil && !viewerFriends[friend.User.ID] {
+			continue
+		}
+		// Preferences of the owner are private
+		visibleFriend := *friend
+		visibleFriend.RelationshipPreferences = data.RelationshipPreferences{}
+		visibleFriends = append(visibleFriends, &visibleFriend)
+	}
+	return &visibleFriends, nil
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel"
+)
+
+const (
+	streamBufferSize   = 16               // events waiting for a slow client before it is disconnected
+	streamKeepAlive    = 30 * time.Second // time between two pings of an idle connection
+	streamWriteTimeout = 10 * time.Second
+)
+
+// streamedEvents are the events pushed to the clients, they change the friend requests or the session invites of both users
+var streamedEvents = map[events.Type]bool{
+	events.InviteSent:            true,
+	events.InviteAccepted:        true,
+	events.InviteDeclined:        true,
+	events.InviteRemoved:         true,
+	events.SessionInviteSent:     true,
+	events.SessionInviteAccepted: true,
+	events.SessionInviteDeclined: true,
+}
+
+var streamUpgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+// streamClient is a connection of a user to the stream
+// Its events channel is closed by the hub when the client is disconnected
+type streamClient struct {
+	events chan *events.Event
+}
+
+// streamHub sends the events received from the broker to the connected clients of their users
+type streamHub struct {
+	mutex   sync.Mutex
+	clients map[string]map[*streamClient]bool
+}
+
+func newStreamHub() *streamHub {
+	return &streamHub{clients: map[string]map[*streamClient]bool{}}
+}
+
+// register connects a new client of the user
+func (hub *streamHub) register(userID string) *streamClient {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	client := &streamClient{events: make(chan *events.Event, streamBufferSize)}
+	if hub.clients[userID] == nil {
+		hub.clients[userID] = map[*streamClient]bool{}
+	}
+	hub.clients[userID][client] = true
+	return client
+}
+
+// unregister disconnects a client, unless the hub already did
+func (hub *streamHub) unregister(userID string, client *streamClient) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	hub.remove(userID, client)
+}
+
+// remove disconnects a client, the mutex must be held
+func (hub *streamHub) remove(userID string, client *streamClient) {
+	if !hub.clients[userID][client] {
+		return
+	}
+	close(client.events)
+	delete(hub.clients[userID], client)
+	if len(hub.clients[userID]) == 0 {
+		delete(hub.clients, userID)
+	}
+}
+
+// dispatch sends an invite event to the clients of both users without blocking the broker
+// A client too slow to receive its events is disconnected, it reloads the invites when reconnecting
+func (hub *streamHub) dispatch(event *events.Event) {
+	if !streamedEvents[event.Type] {
+		return
+	}
+
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	for _, userID := range []string{event.UserID, event.OtherUserID} {
+		for client := range hub.clients[userID] {
+			select {
+			case client.events <- event:
+			default:
+				log.Info("Disconnecting slow stream client", "user_id", userID)
+				hub.remove(userID, client)
+			}
+		}
+	}
+}
+
+// SubscribeStream pushes the invite events received by the subscriber to the clients of the stream, until the returned function is called
+func (relationshipHandler *RelationshipsHandler) SubscribeStream(subscriber events.Subscriber) (unsubscribe func()) {
+	return subscriber.Subscribe(relationshipHandler.stream.dispatch)
+}
+
+// Stream pushes the invite events of the caller as they happen, over a WebSocket when the client asks for an upgrade
+// and as server-sent events otherwise
+func (relationshipHandler *RelationshipsHandler) Stream(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "stream")
+	defer span.End()
+	userID := getCallerID(request)
+
+	log.Info("Stream request", "user_id", userID, "websocket", websocket.IsWebSocketUpgrade(request))
+
+	if userID == "" {
+		http.Error(responseWriter, "Missing user in access token", http.StatusUnauthorized)
+		return
+	}
+
+	client := relationshipHandler.stream.register(userID)
+	defer relationshipHandler.stream.unregister(userID, client)
+
+	if websocket.IsWebSocketUpgrade(request) {
+		streamWebSocket(responseWriter, request, client)
+		return
+	}
+	streamServerSentEvents(responseWriter, request, client)
+}
+
+// streamWebSocket sends each event as a JSON text message until the client or the hub closes the connection
+func streamWebSocket(responseWriter http.ResponseWriter, request *http.Request, client *streamClient) {
+	conn, err := streamUpgrader.Upgrade(responseWriter, request, nil)
+	if err != nil {
+		// The upgrader already answered the request
+		log.Error(err, "Error upgrading stream to a WebSocket")
+		return
+	}
+	defer conn.Close()
+
+	// Clients only send control frames, reading them detects closed connections
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.SetReadLimit(512)
+		_ = conn.SetReadDeadline(time.Now().Add(2 * streamKeepAlive))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * streamKeepAlive))
+		})
+		for {
+			_, _, err := conn.NextReader()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-client.events:
+			if !ok {
+				closeMessage := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too many pending events")
+				_ = conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(streamWriteTimeout))
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			err = conn.WriteJSON(event)
+			if err != nil {
+				log.Error(err, "Error writing event to the WebSocket")
+				return
+			}
+		case <-ticker.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout))
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// streamServerSentEvents sends each event as a server-sent event named after its type until the client or the hub closes the connection
+func streamServerSentEvents(responseWriter http.ResponseWriter, request *http.Request, client *streamClient) {
+	flusher, ok := responseWriter.(http.Flusher)
+	if !ok {
+		http.Error(responseWriter, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", "text/event-stream")
+	responseWriter.Header().Set("Cache-Control", "no-cache")
+	responseWriter.Header().Set("X-Accel-Buffering", "no")
+	responseWriter.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case event, ok := <-client.events:
+			if !ok {
+				return
+			}
+			payload, err := json.Marshal(event)
+			if err != nil {
+				log.Error(err, "Error serializing streamed event")
+				continue
+			}
+			fmt.Fprintf(responseWriter, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, payload)
+			flusher.Flush()
+		case <-ticker.C:
+			fmt.Fprint(responseWriter, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
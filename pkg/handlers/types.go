@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+// GetRelationshipTypes returns the relationship types known by the service with their rules
+func (relationshipHandler *RelationshipsHandler) GetRelationshipTypes(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "getRelationshipTypes")
+	defer span.End()
+	log.Info("GetRelationshipTypes request")
+
+	err := json.NewEncoder(responseWriter).Encode(data.RelationshipTypes().Definitions())
+	if err != nil {
+		log.Error(err, "Error serializing relationship types")
+	}
+}
+
+// GetRelationshipsByUserIDAndType returns the relationships where the user has the relationship type of the type query parameter
+func (relationshipHandler *RelationshipsHandler) GetRelationshipsByUserIDAndType(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "getRelationshipsByUserIDAndType")
+	defer span.End()
+	id := getUserID(request)
+	relationshipType := data.RelationshipType(request.URL.Query().Get("type"))
+
+	log.Info("GetRelationshipsByUserIDAndType request for userID", "id", id, "type", relationshipType)
+
+	if !isOwnerOrAdmin(request, id) {
+		http.Error(responseWriter, "Not allowed to read the relationships of this user", http.StatusForbidden)
+		return
+	}
+
+	if _, ok := data.RelationshipTypes().Lookup(relationshipType); !ok {
+		http.Error(responseWriter, "Unknown relationship type", http.StatusBadRequest)
+		return
+	}
+
+	relationships, err := relationshipHandler.db.GetRelationshipsByUserIDAndType(request.Context(), id, relationshipType)
+	if err != nil {
+		log.Error(err, "Error fetching relationships")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(responseWriter).Encode(relationships)
+	if err != nil {
+		log.Error(err, "Error serializing relationships")
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"go.opentelemetry.io/otel"
+)
+
+// HandleUserEvent applies an account change received from microservice-user to the relationships of the user
+// An event can be received again, handling it twice has no other effect
+func (relationshipHandler *RelationshipsHandler) HandleUserEvent(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "handleUserEvent")
+	defer span.End()
+
+	userEvent := &data.UserEvent{}
+	err := json.NewDecoder(request.Body).Decode(userEvent)
+	if err != nil {
+		log.Error(err, "Error deserializing user event")
+		http.Error(responseWriter, "Error reading user event", http.StatusBadRequest)
+		return
+	}
+
+	err = userEvent.ValidateUserEvent()
+	if err != nil {
+		log.Error(err, "Error validating user event")
+		http.Error(responseWriter, fmt.Sprintf("Error validating user event: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	log.Info("HandleUserEvent request", "id", userEvent.ID, "type", userEvent.Type, "user_id", userEvent.UserID)
+
+	// Bans and unbans are ordered by the time they occurred, an event received late doesn't undo a newer one
+	if userEvent.OccurredAt.IsZero() {
+		userEvent.OccurredAt = time.Now().UTC()
+	}
+
+	switch userEvent.Type {
+	case data.UserDeleted:
+		var deleted int64
+		deleted, err = relationshipHandler.db.DeleteUserRelationships(request.Context(), userEvent.UserID)
+		log.Info("Deleted relationships of deleted user", "user_id", userEvent.UserID, "delete_count", deleted)
+	case data.UserBanned:
+		err = relationshipHandler.db.SetUserFrozen(request.Context(), userEvent.UserID, true, userEvent.OccurredAt)
+	case data.UserUnbanned:
+		err = relationshipHandler.db.SetUserFrozen(request.Context(), userEvent.UserID, false, userEvent.OccurredAt)
+	}
+
+	// Every account change can change the details shown in the lists of the other users
+	if err == nil {
+		err = relationshipHandler.db.InvalidateUserDetails(request.Context(), userEvent.UserID)
+	}
+	if err != nil {
+		// microservice-user sends the event again after an error
+		log.Error(err, "Error handling user event", "id", userEvent.ID)
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Ubivius/microservice-friendslist/pkg/data"
+	"github.com/Ubivius/microservice-friendslist/pkg/events"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+)
+
+// AddWebhook subscribes a new webhook to the relationship events from the received JSON
+func (relationshipHandler *RelationshipsHandler) AddWebhook(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "addWebhook")
+	defer span.End()
+
+	webhook, ok := decodeWebhook(responseWriter, request)
+	if !ok {
+		return
+	}
+
+	log.Info("AddWebhook request", "url", webhook.URL)
+
+	err := relationshipHandler.db.AddWebhook(request.Context(), webhook)
+	if err != nil {
+		log.Error(err, "Error adding webhook")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(responseWriter).Encode(webhook.Redacted())
+	if err != nil {
+		log.Error(err, "Error serializing webhook")
+	}
+}
+
+// GetWebhooks returns every webhook, without their secrets
+func (relationshipHandler *RelationshipsHandler) GetWebhooks(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "getWebhooks")
+	defer span.End()
+
+	log.Info("GetWebhooks request")
+
+	webhooks, err := relationshipHandler.db.GetWebhooks(request.Context())
+	if err != nil {
+		log.Error(err, "Error fetching webhooks")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redacted := data.Webhooks{}
+	for _, webhook := range webhooks {
+		redacted = append(redacted, webhook.Redacted())
+	}
+	err = json.NewEncoder(responseWriter).Encode(redacted)
+	if err != nil {
+		log.Error(err, "Error serializing webhooks")
+	}
+}
+
+// GetWebhook returns a webhook, without its secret
+func (relationshipHandler *RelationshipsHandler) GetWebhook(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "getWebhook")
+	defer span.End()
+	id := getWebhookID(request)
+
+	log.Info("GetWebhook request", "id", id)
+
+	webhook, err := relationshipHandler.db.GetWebhook(request.Context(), id)
+	switch err {
+	case nil:
+		err = json.NewEncoder(responseWriter).Encode(webhook.Redacted())
+		if err != nil {
+			log.Error(err, "Error serializing webhook")
+		}
+		return
+	case data.ErrorWebhookNotFound:
+		log.Error(err, "Webhook not found")
+		http.Error(responseWriter, "Webhook not found", http.StatusNotFound)
+		return
+	default:
+		log.Error(err, "Error fetching webhook")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// UpdateWebhook replaces the URL, event filter and secret of a webhook with the received JSON
+func (relationshipHandler *RelationshipsHandler) UpdateWebhook(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "updateWebhook")
+	defer span.End()
+	id := getWebhookID(request)
+
+	log.Info("UpdateWebhook request", "id", id)
+
+	webhook, ok := decodeWebhook(responseWriter, request)
+	if !ok {
+		return
+	}
+	webhook.ID = id
+
+	err := relationshipHandler.db.UpdateWebhook(request.Context(), webhook)
+	switch err {
+	case nil:
+		responseWriter.WriteHeader(http.StatusNoContent)
+		return
+	case data.ErrorWebhookNotFound:
+		log.Error(err, "Webhook not found")
+		http.Error(responseWriter, "Webhook not found", http.StatusNotFound)
+		return
+	default:
+		log.Error(err, "Error updating webhook")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteWebhook unsubscribes a webhook, its pending and dead deliveries are dropped
+func (relationshipHandler *RelationshipsHandler) DeleteWebhook(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "deleteWebhook")
+	defer span.End()
+	id := getWebhookID(request)
+
+	log.Info("DeleteWebhook request", "id", id)
+
+	err := relationshipHandler.db.DeleteWebhook(request.Context(), id)
+	switch err {
+	case nil:
+		responseWriter.WriteHeader(http.StatusNoContent)
+		return
+	case data.ErrorWebhookNotFound:
+		log.Error(err, "Error deleting webhook, webhook does not exist")
+		http.Error(responseWriter, "Webhook not found", http.StatusNotFound)
+		return
+	default:
+		log.Error(err, "Error deleting webhook")
+		http.Error(responseWriter, "Error deleting webhook", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetDeadWebhookDeliveries returns the dead letters of a webhook, the deliveries that failed every attempt
+func (relationshipHandler *RelationshipsHandler) GetDeadWebhookDeliveries(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "getDeadWebhookDeliveries")
+	defer span.End()
+	id := getWebhookID(request)
+
+	log.Info("GetDeadWebhookDeliveries request", "id", id)
+
+	_, err := relationshipHandler.db.GetWebhook(request.Context(), id)
+	if err == data.ErrorWebhookNotFound {
+		log.Error(err, "Webhook not found")
+		http.Error(responseWriter, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	deliveries, err := relationshipHandler.db.GetDeadWebhookDeliveries(request.Context(), id)
+	if err != nil {
+		log.Error(err, "Error fetching dead webhook deliveries")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(responseWriter).Encode(deliveries)
+	if err != nil {
+		log.Error(err, "Error serializing dead webhook deliveries")
+	}
+}
+
+// ReplayWebhookDeliveries sends the dead letters of a webhook again, the received JSON can select some of them
+func (relationshipHandler *RelationshipsHandler) ReplayWebhookDeliveries(responseWriter http.ResponseWriter, request *http.Request) {
+	_, span := otel.Tracer("friendslist").Start(request.Context(), "replayWebhookDeliveries")
+	defer span.End()
+	id := getWebhookID(request)
+
+	replayRequest := &data.ReplayRequest{}
+	if request.ContentLength != 0 {
+		err := json.NewDecoder(request.Body).Decode(replayRequest)
+		if err != nil {
+			log.Error(err, "Error deserializing replay request")
+			http.Error(responseWriter, "Error reading replay request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	err := replayRequest.ValidateReplayRequest()
+	if err != nil {
+		log.Error(err, "Error validating replay request")
+		http.Error(responseWriter, fmt.Sprintf("Error validating replay request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	log.Info("ReplayWebhookDeliveries request", "id", id, "delivery_ids", replayRequest.DeliveryIDs)
+
+	_, err = relationshipHandler.db.GetWebhook(request.Context(), id)
+	if err == data.ErrorWebhookNotFound {
+		log.Error(err, "Webhook not found")
+		http.Error(responseWriter, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	replayed, err := relationshipHandler.db.ReplayWebhookDeliveries(request.Context(), id, replayRequest.DeliveryIDs)
+	if err != nil {
+		log.Error(err, "Error replaying webhook deliveries")
+		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(responseWriter).Encode(&data.ReplayResult{Replayed: replayed})
+	if err != nil {
+		log.Error(err, "Error serializing replay result")
+	}
+}
+
+// decodeWebhook reads and validates the webhook of the request body
+// The request is answered when the webhook is invalid
+func decodeWebhook(responseWriter http.ResponseWriter, request *http.Request) (*data.Webhook, bool) {
+	webhook := &data.Webhook{}
+	err := json.NewDecoder(request.Body).Decode(webhook)
+	if err != nil {
+		log.Error(err, "Error deserializing webhook")
+		http.Error(responseWriter, "Error reading webhook", http.StatusBadRequest)
+		return nil, false
+	}
+
+	err = webhook.ValidateWebhook()
+	if err == nil {
+		for _, eventType := range webhook.EventTypes {
+			if !events.IsType(eventType) {
+				err = fmt.Errorf("unknown event type %s", eventType)
+				break
+			}
+		}
+	}
+	if err != nil {
+		log.Error(err, "Error validating webhook")
+		http.Error(responseWriter, fmt.Sprintf("Error validating webhook: %s", err), http.StatusBadRequest)
+		return nil, false
+	}
+	return webhook, true
+}
+
+// getWebhookID extracts the webhook ID from the URL
+// The verification of this variable is handled by gorilla/mux
+func getWebhookID(request *http.Request) string {
+	vars := mux.Vars(request)
+	return vars["webhook_id"]
+}
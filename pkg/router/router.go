@@ -21,7 +21,17 @@ func New(relationshipHandler *handlers.RelationshipsHandler) *mux.Router {
 	getRouter := router.Methods(http.MethodGet).Subrouter()
 	getRouter.Use(tokenValidation.Middleware)
 	getRouter.HandleFunc("/friends/{user_id:[0-9a-z-]+}", relationshipHandler.GetFriendsListByUserID)
+	getRouter.HandleFunc("/friends/{user_id:[0-9a-z-]+}/online", relationshipHandler.GetOnlineFriendsByUserID)
 	getRouter.HandleFunc("/invites/{user_id:[0-9a-z-]+}", relationshipHandler.GetInvitesListByUserID)
+	getRouter.HandleFunc("/users/{user_id:[0-9a-z-]+}/relationships/export", relationshipHandler.ExportRelationshipsByUserID)
+	getRouter.HandleFunc("/users/{user_id:[0-9a-z-]+}/relationships", relationshipHandler.GetRelationshipsByUserIDAndType)
+	getRouter.HandleFunc("/relationship-types", relationshipHandler.GetRelationshipTypes)
+	getRouter.HandleFunc("/followers/{user_id:[0-9a-z-]+}", relationshipHandler.GetFollowersByUserID)
+	getRouter.HandleFunc("/following/{user_id:[0-9a-z-]+}", relationshipHandler.GetFollowingByUserID)
+	getRouter.HandleFunc("/feed/{user_id:[0-9a-z-]+}", relationshipHandler.GetFeedByUserID)
+	getRouter.HandleFunc("/recent/{user_id:[0-9a-z-]+}", relationshipHandler.GetRecentPlayersByUserID)
+	getRouter.HandleFunc("/suggestions/{user_id:[0-9a-z-]+}", relationshipHandler.GetFriendSuggestionsByUserID)
+	getRouter.HandleFunc("/stream", relationshipHandler.Stream)
 
 	//Health Check
 	healthRouter := router.Methods(http.MethodGet).Subrouter()
@@ -34,12 +44,80 @@ func New(relationshipHandler *handlers.RelationshipsHandler) *mux.Router {
 	putRouter.HandleFunc("/relationships", relationshipHandler.UpdateRelationships)
 	putRouter.Use(relationshipHandler.MiddlewareRelationshipValidation)
 
+	// Preferences router
+	preferencesRouter := router.Methods(http.MethodPut).Subrouter()
+	preferencesRouter.Use(tokenValidation.Middleware)
+	preferencesRouter.HandleFunc("/relationships/{id:[0-9a-z-]+}/preferences", relationshipHandler.UpdateRelationshipPreferences)
+
 	// Post router
 	postRouter := router.Methods(http.MethodPost).Subrouter()
 	postRouter.Use(tokenValidation.Middleware)
 	postRouter.HandleFunc("/relationships", relationshipHandler.AddRelationship)
 	postRouter.Use(relationshipHandler.MiddlewareRelationshipValidation)
 
+	// Invites router
+	inviteRouter := router.Methods(http.MethodPost).Subrouter()
+	inviteRouter.Use(tokenValidation.Middleware)
+	inviteRouter.HandleFunc("/invites", relationshipHandler.SendInvite)
+
+	// Settings router
+	settingsRouter := router.PathPrefix("/settings").Subrouter()
+	settingsRouter.Use(tokenValidation.Middleware)
+	settingsRouter.HandleFunc("/{user_id:[0-9a-z-]+}", relationshipHandler.GetUserSettings).Methods(http.MethodGet)
+	settingsRouter.HandleFunc("/{user_id:[0-9a-z-]+}", relationshipHandler.UpdateUserSettings).Methods(http.MethodPut)
+
+	// Friend codes router
+	friendCodeRouter := router.PathPrefix("/friend-codes").Subrouter()
+	friendCodeRouter.Use(tokenValidation.Middleware)
+	friendCodeRouter.HandleFunc("/{user_id:[0-9a-z-]+}", relationshipHandler.GetFriendCodesByUserID).Methods(http.MethodGet)
+	friendCodeRouter.HandleFunc("/{user_id:[0-9a-z-]+}", relationshipHandler.AddFriendCode).Methods(http.MethodPost)
+	friendCodeRouter.HandleFunc("/{code:[0-9A-Z-]+}/redeem", relationshipHandler.RedeemFriendCode).Methods(http.MethodPost)
+	friendCodeRouter.HandleFunc("/{code:[0-9A-Z-]+}", relationshipHandler.DeleteFriendCode).Methods(http.MethodDelete)
+
+	// Friend groups router
+	groupRouter := router.PathPrefix("/groups").Subrouter()
+	groupRouter.Use(tokenValidation.Middleware)
+	groupRouter.HandleFunc("/{user_id:[0-9a-z-]+}", relationshipHandler.GetFriendGroupsByUserID).Methods(http.MethodGet)
+	groupRouter.HandleFunc("/{user_id:[0-9a-z-]+}", relationshipHandler.AddFriendGroup).Methods(http.MethodPost)
+	groupRouter.HandleFunc("/{user_id:[0-9a-z-]+}/{group_id:[0-9a-z-]+}", relationshipHandler.UpdateFriendGroup).Methods(http.MethodPut)
+	groupRouter.HandleFunc("/{user_id:[0-9a-z-]+}/{group_id:[0-9a-z-]+}", relationshipHandler.DeleteFriendGroup).Methods(http.MethodDelete)
+
+	// Follows router
+	followRouter := router.PathPrefix("/follows").Subrouter()
+	followRouter.Use(tokenValidation.Middleware)
+	followRouter.HandleFunc("/{user_id:[0-9a-z-]+}", relationshipHandler.Follow).Methods(http.MethodPost)
+	followRouter.HandleFunc("/{user_id:[0-9a-z-]+}", relationshipHandler.Unfollow).Methods(http.MethodDelete)
+
+	// Session invites router
+	sessionInviteRouter := router.PathPrefix("/session-invites").Subrouter()
+	sessionInviteRouter.Use(tokenValidation.Middleware)
+	sessionInviteRouter.HandleFunc("", relationshipHandler.SendSessionInvite).Methods(http.MethodPost)
+	sessionInviteRouter.HandleFunc("/{user_id:[0-9a-z-]+}", relationshipHandler.GetSessionInvitesByUserID).Methods(http.MethodGet)
+	sessionInviteRouter.HandleFunc("/{session_invite_id:[0-9a-z-]+}/accept", relationshipHandler.AcceptSessionInvite).Methods(http.MethodPost)
+	sessionInviteRouter.HandleFunc("/{session_invite_id:[0-9a-z-]+}/decline", relationshipHandler.DeclineSessionInvite).Methods(http.MethodPost)
+
+	// Admin router
+	adminRouter := router.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(tokenValidation.Middleware)
+	adminRouter.Use(relationshipHandler.MiddlewareAdminOnly)
+	adminRouter.HandleFunc("/merges", relationshipHandler.MergeUserRelationships).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/webhooks", relationshipHandler.GetWebhooks).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/webhooks", relationshipHandler.AddWebhook).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/webhooks/{webhook_id:[0-9a-z-]+}", relationshipHandler.GetWebhook).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/webhooks/{webhook_id:[0-9a-z-]+}", relationshipHandler.UpdateWebhook).Methods(http.MethodPut)
+	adminRouter.HandleFunc("/webhooks/{webhook_id:[0-9a-z-]+}", relationshipHandler.DeleteWebhook).Methods(http.MethodDelete)
+	adminRouter.HandleFunc("/webhooks/{webhook_id:[0-9a-z-]+}/dead-letters", relationshipHandler.GetDeadWebhookDeliveries).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/webhooks/{webhook_id:[0-9a-z-]+}/replay", relationshipHandler.ReplayWebhookDeliveries).Methods(http.MethodPost)
+
+	// Internal router, called by trusted services without a user token
+	internalRouter := router.PathPrefix("/internal").Subrouter()
+	internalRouter.Use(relationshipHandler.MiddlewareInternalOnly)
+	internalRouter.HandleFunc("/friendships", relationshipHandler.AddPartyFriendships).Methods(http.MethodPost)
+	internalRouter.HandleFunc("/user-events", relationshipHandler.HandleUserEvent).Methods(http.MethodPost)
+	internalRouter.HandleFunc("/presence", relationshipHandler.UpdatePresence).Methods(http.MethodPost)
+	internalRouter.HandleFunc("/matches", relationshipHandler.IngestMatchRoster).Methods(http.MethodPost)
+	internalRouter.HandleFunc("/chat-events", relationshipHandler.HandleChatEvent).Methods(http.MethodPost)
+
 	// Delete router
 	deleteRouter := router.Methods(http.MethodDelete).Subrouter()
 	deleteRouter.Use(tokenValidation.Middleware)
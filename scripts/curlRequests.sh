@@ -4,6 +4,39 @@
 
 curl localhost:9090/friends/a2181017-5c53-422b-b6bc-036b27c04fc8
 curl localhost:9090/invites/e2382ea2-b5fa-4506-aa9d-d338aa52af44
+curl localhost:9090/users/a2181017-5c53-422b-b6bc-036b27c04fc8/relationships/export?format=csv
 curl localhost:9090/relationships -XPOST -d '{"user_1": {"user_id":"a2181017-5c53-422b-b6bc-036b27c04fc8", "relationship_type":"PendingOutgoing"}, "user_2": {"user_id":"e2382ea2-b5fa-4506-aa9d-d338aa52af44", "relationship_type":"PendingIncoming"}}'
 curl localhost:9090/relationships -XPUT -d '{"id":"eb9aff9f-8c4e-47c3-9f6d-bd9aac3d9f31", "user_1": {"user_id":"a2181017-5c53-422b-b6bc-036b27c04fc8", "relationship_type":"Friend"}, "user_2": {"user_id":"e2382ea2-b5fa-4506-aa9d-d338aa52af44", "relationship_type":"Friend"}}'
 curl localhost:9090/relationships/a2181017-5c53-422b-b6bc-036b27c04fc8 -XDELETE
+curl localhost:9090/admin/merges -XPOST -d '{"source_user_id":"0af831ea-8a78-11eb-8dcd-0242ac130003", "target_user_id":"f171ea04-8a77-11eb-8dcd-0242ac130003"}'
+curl localhost:9090/friend-codes/a2181017-5c53-422b-b6bc-036b27c04fc8 -XPOST -d '{"single_use": true, "expires_in": 86400}'
+curl localhost:9090/friend-codes/7KQ4-MX2B/redeem -XPOST
+curl localhost:9090/invites -XPOST -d '{"username":"sickboy"}'
+curl localhost:9090/settings/a2181017-5c53-422b-b6bc-036b27c04fc8 -XPUT -d '{"invite_policy":"friends_of_friends", "friends_list_visible":false}'
+curl localhost:9090/groups/a2181017-5c53-422b-b6bc-036b27c04fc8 -XPOST -d '{"name":"Raid team", "relationship_ids":["c5825d3e-8a77-11eb-8dcd-0242ac130003"]}'
+curl localhost:9090/friends/a2181017-5c53-422b-b6bc-036b27c04fc8?group=0c1d2e3f-8a7c-11eb-8dcd-0242ac130003
+curl localhost:9090/relationships/c5825d3e-8a77-11eb-8dcd-0242ac130003/preferences -XPUT -d '{"nickname":"Healer", "favourite":true}'
+curl localhost:9090/friends/a2181017-5c53-422b-b6bc-036b27c04fc8?sort=favourite
+curl localhost:9090/follows/e2382ea2-b5fa-4506-aa9d-d338aa52af44 -XPOST
+curl localhost:9090/followers/e2382ea2-b5fa-4506-aa9d-d338aa52af44?limit=20
+curl localhost:9090/relationship-types
+curl localhost:9090/users/a2181017-5c53-422b-b6bc-036b27c04fc8/relationships?type=Blocked
+curl localhost:9090/settings/a2181017-5c53-422b-b6bc-036b27c04fc8 -XPUT -d '{"invite_policy":"everyone", "friends_list_visible":true, "auto_accept":["guild_mates"]}'
+curl localhost:9090/internal/friendships -XPOST -H "X-Internal-Api-Key: $INTERNAL_API_KEY" -d '{"user_ids":["a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44", "c5825d3e-8a77-11eb-8dcd-0242ac130003"]}'
+curl localhost:9090/admin/webhooks -XPOST -d '{"url":"http://game-backend:8080/friendslist-events", "event_types":["FriendAdded","FriendRemoved"], "secret":"change-me-0123456789"}'
+curl localhost:9090/admin/webhooks/9a8b7c6d-8a7e-11eb-8dcd-0242ac130003/dead-letters
+curl localhost:9090/admin/webhooks/9a8b7c6d-8a7e-11eb-8dcd-0242ac130003/replay -XPOST
+curl -N localhost:9090/stream -H "Authorization: Bearer $ACCESS_TOKEN"
+curl localhost:9090/internal/user-events -XPOST -H "X-Internal-Api-Key: $INTERNAL_API_KEY" -d '{"id":"5f8e2c1a-8a7f-11eb-8dcd-0242ac130003", "type":"UserBanned", "user_id":"e2382ea2-b5fa-4506-aa9d-d338aa52af44"}'
+curl localhost:9090/internal/presence -XPOST -H "X-Internal-Api-Key: $INTERNAL_API_KEY" -d '{"user_id":"e2382ea2-b5fa-4506-aa9d-d338aa52af44", "status":"InGame"}'
+curl localhost:9090/friends/a2181017-5c53-422b-b6bc-036b27c04fc8/online
+curl "localhost:9090/friends/a2181017-5c53-422b-b6bc-036b27c04fc8?status=Online,InGame"
+curl "localhost:9090/feed/a2181017-5c53-422b-b6bc-036b27c04fc8?offset=0&limit=20"
+curl localhost:9090/session-invites -XPOST -d '{"session_id":"match-42", "invitee_id":"e2382ea2-b5fa-4506-aa9d-d338aa52af44"}'
+curl localhost:9090/session-invites/e2382ea2-b5fa-4506-aa9d-d338aa52af44
+curl localhost:9090/session-invites/5a6b7c8d-8a7f-11eb-8dcd-0242ac130003/accept -XPOST
+curl localhost:9090/internal/matches -XPOST -H "X-Internal-Api-Key: $INTERNAL_API_KEY" -d '{"match_id":"match-42", "user_ids":["a2181017-5c53-422b-b6bc-036b27c04fc8", "e2382ea2-b5fa-4506-aa9d-d338aa52af44"]}'
+curl "localhost:9090/recent/a2181017-5c53-422b-b6bc-036b27c04fc8?limit=20"
+curl "localhost:9090/suggestions/a2181017-5c53-422b-b6bc-036b27c04fc8?limit=10"
+curl localhost:9090/internal/chat-events -XPOST -H "X-Internal-Api-Key: $INTERNAL_API_KEY" -d '{"id":"7b8c9d0e-8a80-11eb-8dcd-0242ac130003", "type":"MessageSent", "sender_id":"a2181017-5c53-422b-b6bc-036b27c04fc8", "recipient_ids":["e2382ea2-b5fa-4506-aa9d-d338aa52af44"]}'
+curl "localhost:9090/friends/a2181017-5c53-422b-b6bc-036b27c04fc8?sort=affinity"